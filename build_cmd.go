@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acsellers/dr/parse"
+	"github.com/codegangsta/cli"
+)
+
+func runBuild(c *cli.Context) {
+	if c.Bool("watch") {
+		watchBuild(c, c.Duration("interval"))
+		return
+	}
+
+	pkg, err := parsePackage(c)
+	if err != nil {
+		scanner.PrintError(os.Stderr, err)
+		log.Fatal("Couldn't parse files")
+	}
+	generated, err := pkg.Generate()
+	if err != nil {
+		log.Fatal("Couldn't generate files got error:", err)
+	}
+
+	if c.Bool("check") {
+		current, err := parse.CheckFiles(os.Stdout, generated)
+		if err != nil {
+			log.Fatal("Couldn't check files got error:", err)
+		}
+		if !current {
+			log.Fatal("Generated files are out of date, run dr build")
+		}
+		return
+	}
+
+	_, err = parse.WriteFiles(generated)
+	if err != nil {
+		log.Fatal("Couldn't write files got error:", err)
+	}
+	err = pkg.WriteConfig()
+	if err != nil {
+		log.Fatal("Couldn't write db_config.go got error:", err)
+	}
+}
+
+// parsePackage reads every .gp file in the current directory
+func parsePackage(c *cli.Context) (*parse.Package, error) {
+	cfg, err := configFor(c)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &parse.Package{
+		Funcs:  make(map[string][]parse.Func),
+		Config: cfg,
+	}
+	names, _ := filepath.Glob("*.gp")
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		defer f.Close()
+	}
+	return pkg, pkg.Parse(files...)
+}
+
+// watchBuild polls the .gp files and rebuilds when any of them change,
+// only rewriting generated files whose contents changed. Errors are
+// printed and the watch carries on.
+func watchBuild(c *cli.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	fmt.Println("Watching *.gp and templates for changes, press Ctrl-C to stop")
+
+	last := ""
+	for ; ; time.Sleep(interval) {
+		patterns := []string{"*.gp"}
+		if cfg, err := configFor(c); err == nil && cfg.Templates != "" {
+			patterns = append(patterns, filepath.Join(cfg.Templates, "*"+parse.TemplateExt))
+		}
+		current := sourceState(patterns...)
+		if current == last {
+			continue
+		}
+		last = current
+
+		pkg, err := parsePackage(c)
+		if err != nil {
+			scanner.PrintError(os.Stderr, err)
+			continue
+		}
+		generated, err := pkg.Generate()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't generate files got error:", err)
+			continue
+		}
+		written, err := parse.WriteFiles(generated)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't write files got error:", err)
+			continue
+		}
+		err = pkg.WriteConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't write db_config.go got error:", err)
+		}
+		if len(written) > 0 {
+			fmt.Printf("%s Updated %s\n", time.Now().Format("15:04:05"), strings.Join(written, ", "))
+		} else {
+			fmt.Printf("%s Generated files are up to date\n", time.Now().Format("15:04:05"))
+		}
+	}
+}
+
+// sourceState summarizes the names, sizes and modification times of the
+// files matching patterns so that any change can be noticed by comparing
+// summaries.
+func sourceState(patterns ...string) string {
+	state := []string{}
+	for _, pattern := range patterns {
+		names, _ := filepath.Glob(pattern)
+		for _, name := range names {
+			info, err := os.Stat(name)
+			if err != nil {
+				continue
+			}
+			state = append(state, fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	return strings.Join(state, "\n")
+}
+
+// configFlags override the settings from dr.toml or dr.json
+var configFlags = []cli.Flag{
+	cli.StringFlag{Name: "config", Usage: "config file to use instead of dr.toml or dr.json"},
+	cli.StringFlag{Name: "out", Usage: "directory to write generated code to"},
+	cli.StringFlag{Name: "def-file", Value: parse.DefaultDefFile, Usage: "name pattern for the Go file written for each .gp file"},
+	cli.StringFlag{Name: "package-file", Value: parse.DefaultPackageFile, Usage: "name pattern for the gen, schema and lib files"},
+	cli.StringFlag{Name: "naming", Usage: "naming convention for a new db_config.go: plain, lower or rails"},
+	cli.IntFlag{Name: "string-length", Usage: "varchar length for strings without a length tag"},
+	cli.StringFlag{Name: "dialects", Usage: "comma separated drivers the generated Open accepts"},
+	cli.BoolFlag{Name: "timestamps", Usage: "add and maintain CreatedAt and UpdatedAt columns"},
+	cli.BoolFlag{Name: "soft-delete", Usage: "add a DeletedAt column and soft delete records"},
+	cli.StringFlag{Name: "templates", Usage: "directory of .tmpl files overriding or adding to the generator templates"},
+}
+
+// configFor loads the project config and applies the flags set over it
+func configFor(c *cli.Context) (parse.Config, error) {
+	var cfg parse.Config
+	var err error
+	if c.String("config") != "" {
+		cfg, err = parse.ReadConfig(c.String("config"))
+	} else {
+		cfg, err = parse.LoadConfig(".")
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if c.IsSet("out") {
+		cfg.Output.Dir = c.String("out")
+	}
+	if c.IsSet("def-file") {
+		cfg.Output.DefFile = c.String("def-file")
+	}
+	if c.IsSet("package-file") {
+		cfg.Output.PackageFile = c.String("package-file")
+	}
+	if c.IsSet("naming") {
+		cfg.Naming = c.String("naming")
+	}
+	if c.IsSet("string-length") {
+		cfg.StringLength = c.Int("string-length")
+	}
+	if c.IsSet("dialects") {
+		cfg.Dialects = strings.Split(c.String("dialects"), ",")
+	}
+	if c.IsSet("timestamps") {
+		cfg.Timestamps = c.Bool("timestamps")
+	}
+	if c.IsSet("soft-delete") {
+		cfg.SoftDelete = c.Bool("soft-delete")
+	}
+	if c.IsSet("templates") {
+		cfg.Templates = c.String("templates")
+	}
+	return cfg, cfg.Check()
+}
@@ -0,0 +1,9 @@
+package main
+
+// Drivers for the commands that connect to a database
+import (
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
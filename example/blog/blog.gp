@@ -1,27 +1,40 @@
 package blog
 
+import dr "github.com/acsellers/dr/runtime"
+
 type User table {
   ID                  int
   Name                string
   Email               string
-  PermissionLevel     int
-  ArticleCompensation float32
+  PermissionLevel     PermissionLevel
+  ArticleCompensation dr.Decimal `precision:"12" scale:"2"`
   TotalCompensation   float64
   Inactive            bool
   CreatedAt           time.Time
+  BannedUntil         *time.Time
+  Website             sql.NullString
+  Settings            map[string]string `type:"json"`
 
   SecurePassword
 
   relation {
     []Post
-    Sponsor []Post
+    Sponsor []Post `dependent:"restrict"`
+    Comments []Comment `as:"Commentable" dependent:"delete"`
   }
 
   index {
-    Email
+    unique lower(Email)
   }
 }
 
+type PermissionLevel enum {
+  Reader
+  Author
+  Editor
+  Admin "Administrator"
+}
+
 type SecurePassword mixin {
   CryptPassword []byte
 }
@@ -43,17 +56,66 @@ func (sp SecurePassword) ComparePassword(password string) bool {
 
 type Post table {
   ID int
-  Title string
+  Title string `check:"Title <> ''" comment:"shown above the body"`
   Body string `type:"text"`
   UserID int
   SponsorID int
+  Tags Tags `type:"text"`
+  Views int64
+  Rating uint8
+  ParentID *int
 
   relation {
-    User
+    User `onDelete:"cascade"`
     Sponsor User
+    Parent Post
+    Children []Post `column:"ParentID" dependent:"nullify"`
+    Comments []Comment `as:"Commentable" dependent:"delete"`
   }
 
   index {
     UserID
+    UserID, Views desc where Rating > 0 `name:"idx_post_rated"`
   }
 }
+
+// Announcements are posts shown at the top of the front page
+type Announcement table Post
+
+// Comments can be left on a Post or on a User's profile
+type Comment table {
+  ID   int64
+  Body string `type:"text"`
+
+  relation {
+    Commentable polymorphic
+  }
+}
+
+// Tags are stored in a single column, separated by commas
+type Tags []string
+
+func (t *Tags) Scan(v interface{}) error {
+  var s string
+  switch v := v.(type) {
+  case string:
+    s = v
+  case []byte:
+    s = string(v)
+  case nil:
+    *t = nil
+    return nil
+  default:
+    return fmt.Errorf("Can't scan %T into Tags", v)
+  }
+  if s == "" {
+    *t = nil
+  } else {
+    *t = strings.Split(s, ",")
+  }
+  return nil
+}
+
+func (t Tags) Value() (driver.Value, error) {
+  return strings.Join(t, ","), nil
+}
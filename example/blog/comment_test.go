@@ -0,0 +1,70 @@
+package blog
+
+import "testing"
+
+func TestPolymorphicComments(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+	p, err := createSinglePost(c, users[0])
+	if err != nil {
+		t.Fatal("Post Save", err)
+	}
+
+	comments := []Comment{{Body: "On the post"}, {Body: "On the profile"}, {Body: "Also on the post"}}
+	for i, record := range []interface{}{p, &users[1], p} {
+		if err = comments[i].SetCommentable(record); err != nil {
+			t.Fatal("SetCommentable", err)
+		}
+		if err = comments[i].Save(c); err != nil {
+			t.Fatal("Comment Save", err)
+		}
+	}
+	if comments[2].ID != comments[1].ID+1 {
+		t.Error("Unexpected int64 primary keys", comments[1].ID, comments[2].ID)
+	}
+	if found, err := c.Comment.Find(comments[2].ID); err != nil || found.Body != "Also on the post" {
+		t.Error("Comment.Find", found, err)
+	}
+	if err = comments[0].SetCommentable(comments[1]); err == nil {
+		t.Error("A Comment was made to belong to a Comment")
+	}
+
+	onPost, err := p.Comments(c)
+	if err != nil || len(onPost) != 2 {
+		t.Fatal("Post.Comments", onPost, err)
+	}
+	onUser, err := users[1].Comments(c)
+	if err != nil || len(onUser) != 1 || onUser[0].Body != "On the profile" {
+		t.Fatal("User.Comments", onUser, err)
+	}
+	if users[0].Scope().CommentsScope().Count() != 0 {
+		t.Error(users[0].Scope().CommentsScope().QuerySQL())
+	}
+
+	commentable, err := comments[1].Commentable(c)
+	if user, ok := commentable.(User); err != nil || !ok || user.ID != users[1].ID {
+		t.Fatal("Comment.Commentable", commentable, err)
+	}
+	if c.Comment.CommentableIsPost().Count() != 2 {
+		t.Error(c.Comment.CommentableIsPost().QuerySQL())
+	}
+	titles, err := c.Comment.Body().Eq("On the post").CommentablePostScope().Title().PluckString()
+	if err != nil || len(titles) != 1 || titles[0] != p.Title {
+		t.Error("CommentablePostScope", titles, err)
+	}
+
+	loaded, err := LoadCommentCommentable(c, comments)
+	if err != nil {
+		t.Fatal("LoadCommentCommentable", err)
+	}
+	if post, ok := loaded[2].(Post); !ok || post.ID != p.ID {
+		t.Error("Loaded the wrong Post", loaded[2])
+	}
+	if user, ok := loaded[1].(User); !ok || user.ID != users[1].ID {
+		t.Error("Loaded the wrong User", loaded[1])
+	}
+}
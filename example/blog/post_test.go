@@ -1,9 +1,13 @@
 package blog
 
 import (
+	"bytes"
+	"log"
 	"strings"
 	"testing"
 
+	"github.com/acsellers/dr/migrate"
+	dr "github.com/acsellers/dr/runtime"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -119,6 +123,352 @@ func TestPostSave(t *testing.T) {
 	c.Close()
 }
 
+func TestPostTags(t *testing.T) {
+	c := openTestConn()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	p := Post{Title: "Tagged", UserID: users[0].ID, Tags: Tags{"go", "sql"}}
+	err = p.Save(c)
+	if err != nil {
+		t.Fatal("Post Save", err)
+	}
+
+	p2, err := c.Post.Find(p.ID)
+	if err != nil {
+		t.Fatal("Post Find", err)
+	}
+	if len(p2.Tags) != 2 || p2.Tags[0] != "go" || p2.Tags[1] != "sql" {
+		t.Fatal("Tags weren't scanned", p2.Tags)
+	}
+	if c.Post.Tags().Eq(Tags{"go", "sql"}).Count() != 1 {
+		t.Log(c.Post.Tags().Eq(Tags{"go", "sql"}).QuerySQL())
+		t.Fatal("Couldn't filter by Tags")
+	}
+
+	c.Close()
+}
+
+func TestPostCounters(t *testing.T) {
+	c := openTestConn()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	p := Post{Title: "Popular", UserID: users[0].ID, Views: 1 << 40, Rating: 255}
+	err = p.Save(c)
+	if err != nil {
+		t.Fatal("Post Save", err)
+	}
+
+	p2, err := c.Post.Find(p.ID)
+	if err != nil {
+		t.Fatal("Post Find", err)
+	}
+	if p2.Views != 1<<40 || p2.Rating != 255 {
+		t.Fatal("Counters weren't scanned", p2.Views, p2.Rating)
+	}
+
+	ratings, err := dr.Pluck[uint8](c.Post.Views().Gt(1 << 32).Rating())
+	if err != nil || len(ratings) != 1 || ratings[0] != 255 {
+		t.Fatal("Pluck Rating", ratings, err)
+	}
+	if _, err = dr.Pluck[int8](c.Post.Rating()); err == nil {
+		t.Fatal("Plucked 255 into an int8")
+	}
+
+	c.Close()
+}
+
+func TestPostConstraints(t *testing.T) {
+	c := openTestConn()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	p := Post{UserID: users[0].ID}
+	if err = p.Save(c); err == nil {
+		t.Fatal("Saved a Post without a Title")
+	}
+
+	db := migrate.Database{
+		DB:         c.DB,
+		Schema:     Schema,
+		Translator: NewAppConfig("sqlite3"),
+		DBMS:       migrate.Sqlite,
+		Log:        log.New(&bytes.Buffer{}, "Migrate: ", 0),
+	}
+	db.SetAlterer()
+	current, err := db.UpToDate()
+	if err != nil || !current {
+		t.Fatal("Constraints read back from the database didn't match", err, db.ModifiedTables)
+	}
+
+	c.Close()
+}
+
+func TestPostTree(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	root := Post{Title: "Root", UserID: users[0].ID}
+	if err = root.Save(c); err != nil {
+		t.Fatal("Post Save", err)
+	}
+	for _, title := range []string{"First", "Second"} {
+		reply := Post{Title: title, UserID: users[1].ID, ParentID: &root.ID}
+		if err = reply.Save(c); err != nil {
+			t.Fatal("Post Save", err)
+		}
+	}
+
+	children, err := root.Children(c)
+	if err != nil {
+		t.Fatal("Post.Children", err)
+	}
+	if len(children) != 2 || children[0].Title != "First" {
+		t.Fatal("Unexpected children", children)
+	}
+	parent, err := children[1].Parent(c)
+	if err != nil || parent.ID != root.ID {
+		t.Fatal("Post.Parent", parent, err)
+	}
+	if _, err = root.Parent(c); err == nil {
+		t.Error("Found a Parent for the root Post")
+	}
+
+	if root.Scope().ChildrenScope().Title().Eq("Second").Count() != 1 {
+		t.Error(root.Scope().ChildrenScope().Title().Eq("Second").QuerySQL())
+	}
+	parents, err := c.Post.Title().Eq("First").ParentScope().RetrieveAll()
+	if err != nil || len(parents) != 1 || parents[0].Title != "Root" {
+		t.Error("ParentScope", parents, err)
+	}
+}
+
+func TestPostCascade(t *testing.T) {
+	c, err := Open("sqlite3", "file:cascade?mode=memory&cache=shared&_foreign_keys=1")
+	if err != nil {
+		t.Fatal("Open", err)
+	}
+	defer c.Close()
+	db := migrate.Database{
+		DB:         c.DB,
+		Schema:     Schema,
+		Translator: NewAppConfig("sqlite3"),
+		DBMS:       migrate.Sqlite,
+		Log:        log.New(&bytes.Buffer{}, "Migrate: ", 0),
+	}
+	if err = db.Migrate(); err != nil {
+		t.Fatal("Migrate", err)
+	}
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	p := Post{Title: "Cascade", UserID: users[0].ID, SponsorID: users[1].ID}
+	if err = p.Save(c); err != nil {
+		t.Fatal("Post Save", err)
+	}
+	if err = users[0].Delete(c); err != nil {
+		t.Fatal("User Delete", err)
+	}
+	if c.Post.ID().Eq(p.ID).Count() != 0 {
+		t.Error("Deleting the User didn't delete their Post")
+	}
+
+	p = Post{Title: "Unsponsored", UserID: users[1].ID, SponsorID: users[0].ID}
+	if err = p.Save(c); err == nil {
+		t.Error("Saved a Post sponsored by a deleted User")
+	}
+}
+
+func TestPostVariants(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+	if _, err = createSinglePost(c, users[0]); err != nil {
+		t.Fatal("Post Save", err)
+	}
+
+	a := Announcement{Title: "Downtime", UserID: users[1].ID}
+	if err = a.Save(c); err != nil {
+		t.Fatal("Announcement Save", err)
+	}
+	if a.Type != "Announcement" {
+		t.Error("Save didn't set Type", a.Type)
+	}
+	if c.Announcement.Count() != 1 || c.Post.Count() != 2 {
+		t.Error("Unexpected counts", c.Announcement.Count(), c.Post.Count())
+	}
+	if c.Announcement.UserID().Eq(users[0].ID).Count() != 0 {
+		t.Error(c.Announcement.UserID().Eq(users[0].ID).QuerySQL())
+	}
+	found, err := c.Announcement.Find(a.ID)
+	if err != nil || found.Title != "Downtime" {
+		t.Fatal("Announcement Find", found, err)
+	}
+
+	records, err := c.Post.ID().Asc().RetrieveVariants()
+	if err != nil || len(records) != 2 {
+		t.Fatal("RetrieveVariants", records, err)
+	}
+	if _, ok := records[0].(Post); !ok {
+		t.Error("Plain post loaded as", records[0])
+	}
+	if announcement, ok := records[1].(Announcement); !ok || announcement.ID != a.ID {
+		t.Error("Announcement loaded as", records[1])
+	}
+
+	if err = found.Delete(c); err != nil {
+		t.Fatal("Announcement Delete", err)
+	}
+	if c.Post.Count() != 1 {
+		t.Error("Announcement wasn't deleted")
+	}
+}
+
+func TestPostSaveRelated(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	author := User{Name: "Nyarlathotep", Email: "nyarlathotep@example.com", PermissionLevel: PermissionLevelAuthor}
+	p := Post{Title: "Root"}
+	p.Related.User = &author
+	p.Related.Sponsor = &users[0]
+	p.Related.Children = []Post{{Title: "First"}, {Title: "Second"}}
+	p.Related.Children[1].Related.User = &users[1]
+	p.Related.Comments = []Comment{{Body: "Saved with the post"}}
+	if err = p.Save(c); err != nil {
+		t.Fatal("Post Save", err)
+	}
+
+	if author.ID == 0 || p.UserID != author.ID || p.SponsorID != users[0].ID {
+		t.Error("Unexpected foreign keys", author.ID, p.UserID, p.SponsorID)
+	}
+	children, err := p.Children(c)
+	if err != nil || len(children) != 2 || children[1].UserID != users[1].ID {
+		t.Fatal("Post.Children", children, err)
+	}
+	if id := p.Related.Children[0].ParentID; id == nil || *id != p.ID {
+		t.Error("Child's ParentID wasn't set", id)
+	}
+	comments, err := p.Comments(c)
+	if err != nil || len(comments) != 1 || comments[0].CommentableType != "Post" {
+		t.Error("Post.Comments", comments, err)
+	}
+	if posts, err := author.Post(c); err != nil || len(posts) != 1 {
+		t.Error("User.Post", posts, err)
+	}
+
+	doomed := Post{Title: "Doomed"}
+	doomed.Related.User = &User{Name: "Azathoth", Email: "azathoth@example.com", PermissionLevel: PermissionLevelAuthor}
+	doomed.Related.Children = []Post{{Title: ""}}
+	if err = doomed.Save(c); err == nil {
+		t.Fatal("Saved a child Post without a Title")
+	}
+	if c.Post.Title().Eq("Doomed").Count() != 0 || c.User.Name().Eq("Azathoth").Count() != 0 {
+		t.Error("The failed save wasn't rolled back")
+	}
+	if doomed.ID != 0 || doomed.UserID != 0 || doomed.Related.User.ID != 0 || doomed.Related.Children[0].ParentID != nil {
+		t.Error("Keys from the failed save were left set", doomed.ID, doomed.UserID, doomed.Related.User.ID, doomed.Related.Children[0].ParentID)
+	}
+	doomed.Related.Children[0].Title = "Spared"
+	if err = doomed.Save(c); err != nil {
+		t.Fatal("Post Save after the rollback", err)
+	}
+	if c.Post.Title().Eq("Spared").Count() != 1 || c.User.Name().Eq("Azathoth").Count() != 1 {
+		t.Error("The second save didn't save the related records")
+	}
+
+	// records remember the Conn they were saved with
+	p.Related.Children = []Post{{Title: "Third"}}
+	if err = p.Save(nil); err != nil {
+		t.Fatal("Post Save without a Conn", err)
+	}
+	if children, _ = p.Children(c); len(children) != 3 {
+		t.Error("Post.Children after saving without a Conn", children)
+	}
+	orphan := Post{Title: "Orphan"}
+	orphan.Related.User = &users[0]
+	if err = orphan.Save(nil); err == nil {
+		t.Error("Saved a new Post without a Conn")
+	}
+}
+
+func TestPostDependents(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	users, err := createTestUsers(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	root := Post{Title: "Root", UserID: users[0].ID, SponsorID: users[1].ID}
+	root.Related.Children = []Post{{Title: "Reply", UserID: users[2].ID}}
+	root.Related.Comments = []Comment{{Body: "On the root"}, {Body: "Also on the root"}}
+	if err = root.Save(c); err != nil {
+		t.Fatal("Post Save", err)
+	}
+	reply := root.Related.Children[0]
+	onReply := Comment{Body: "On the reply"}
+	onProfile := Comment{Body: "On the profile"}
+	onReply.SetCommentable(&reply)
+	onProfile.SetCommentable(&users[0])
+	if err = c.Comment.SaveAll([]Comment{onReply, onProfile}); err != nil {
+		t.Fatal("Comment Save", err)
+	}
+
+	if err = users[1].Delete(c); err == nil || !strings.Contains(err.Error(), "1 Post records depend on it") {
+		t.Error("Deleted a User sponsoring a Post", err)
+	}
+	if c.User.ID().Eq(users[1].ID).Count() != 1 {
+		t.Error("The restricted User was deleted")
+	}
+
+	if err = root.Delete(c); err != nil {
+		t.Fatal("Post Delete", err)
+	}
+	reply, err = c.Post.Find(reply.ID)
+	if err != nil || reply.ParentID != nil {
+		t.Error("The reply's ParentID wasn't nullified", reply.ParentID, err)
+	}
+	if c.Comment.Count() != 2 {
+		t.Error("The root's comments weren't deleted", c.Comment.Count())
+	}
+
+	replies := c.Post.Title().Eq("Reply")
+	if err = replies.Delete(); err != nil {
+		t.Fatal("PostScope Delete", err)
+	}
+	// the scope keeps its own Conn rather than the finished transaction's
+	if ids, err := replies.PluckInt(); err != nil || len(ids) != 0 || replies.Count() != 0 {
+		t.Error("The scope couldn't be used after Delete", ids, err)
+	}
+	if bodies, _ := c.Comment.Body().PluckString(); len(bodies) != 1 || bodies[0] != "On the profile" {
+		t.Error("Unexpected comments left", bodies)
+	}
+	if err = users[1].Delete(c); err != nil {
+		t.Error("User Delete", err)
+	}
+}
+
 func createSinglePost(c *Conn, u User) (Post, error) {
 	p := Post{
 		Title:  u.Name,
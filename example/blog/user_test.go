@@ -2,11 +2,14 @@ package blog
 
 import (
 	"bytes"
+	"database/sql"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/acsellers/dr/migrate"
+	dr "github.com/acsellers/dr/runtime"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -39,7 +42,7 @@ func TestUserSave(t *testing.T) {
 	if u2.PermissionLevel != u.PermissionLevel {
 		t.Fatal("PermissionLevel Compare", u.PermissionLevel, u2.PermissionLevel)
 	}
-	if u2.ArticleCompensation != u.ArticleCompensation {
+	if u2.ArticleCompensation.Cmp(u.ArticleCompensation) != 0 {
 		t.Fatal("ArticleCompensation Compare", u.ArticleCompensation, u2.ArticleCompensation)
 	}
 	if u2.TotalCompensation != u.TotalCompensation {
@@ -62,7 +65,7 @@ func TestUserSave(t *testing.T) {
 	if u2.PermissionLevel != u.PermissionLevel {
 		t.Fatal("PermissionLevel Compare", u.PermissionLevel, u2.PermissionLevel)
 	}
-	if u2.ArticleCompensation != u.ArticleCompensation {
+	if u2.ArticleCompensation.Cmp(u.ArticleCompensation) != 0 {
 		t.Fatal("ArticleCompensation Compare", u.ArticleCompensation, u2.ArticleCompensation)
 	}
 	if u2.TotalCompensation != u.TotalCompensation {
@@ -82,7 +85,7 @@ func TestUserSave(t *testing.T) {
 	if u2.PermissionLevel != u.PermissionLevel {
 		t.Fatal("PermissionLevel Compare", u.PermissionLevel, u2.PermissionLevel)
 	}
-	if u2.ArticleCompensation != u.ArticleCompensation {
+	if u2.ArticleCompensation.Cmp(u.ArticleCompensation) != 0 {
 		t.Fatal("ArticleCompensation Compare", u.ArticleCompensation, u2.ArticleCompensation)
 	}
 	if u2.TotalCompensation != u.TotalCompensation {
@@ -166,7 +169,7 @@ func TestUserScopes(t *testing.T) {
 		t.Fatal("Could not retrieve by email")
 	}
 
-	if c.User.PermissionLevel().Gt(1).Count() != 3 {
+	if c.User.PermissionLevelNamed("Editor", "Administrator").Count() != 3 {
 		t.Fatal("Could not find higher level users")
 	}
 
@@ -231,6 +234,189 @@ func TestConnActions(t *testing.T) {
 	c.Close()
 }
 
+func TestUserNullColumns(t *testing.T) {
+	c := openTestConn()
+	u, err := createSingleUser(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u2, err := c.User.Find(u.ID)
+	if err != nil {
+		t.Fatal("User Find", err)
+	}
+	if u2.BannedUntil != nil || u2.Website.Valid {
+		t.Fatal("Expected NULL columns", u2.BannedUntil, u2.Website)
+	}
+	if c.User.BannedUntil().Eq(nil).Count() != 1 {
+		t.Fatal("BannedUntil wasn't written as NULL")
+	}
+
+	banned := time.Now().Add(time.Hour)
+	u2.BannedUntil = &banned
+	u2.Website = sql.NullString{String: "http://example.com", Valid: true}
+	err = u2.Save(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u3, err := c.User.Find(u.ID)
+	if err != nil {
+		t.Fatal("User Find", err)
+	}
+	if u3.BannedUntil == nil || !u3.BannedUntil.Equal(banned) || u3.Website.String != "http://example.com" {
+		t.Fatal("Nullable columns weren't saved", u3.BannedUntil, u3.Website)
+	}
+
+	u3.BannedUntil = nil
+	u3.Website = sql.NullString{}
+	err = u3.Save(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+	if c.User.BannedUntil().Eq(u3.BannedUntil).Website().Eq(u3.Website).Count() != 1 {
+		t.Fatal("Columns weren't set back to NULL")
+	}
+
+	c.Close()
+}
+
+func TestUserSettings(t *testing.T) {
+	c := openTestConn()
+	u, err := createSingleUser(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u.Settings = map[string]string{"theme": "dark", "lang": "en"}
+	err = u.Save(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u2, err := c.User.Find(u.ID)
+	if err != nil {
+		t.Fatal("User Find", err)
+	}
+	if len(u2.Settings) != 2 || u2.Settings["theme"] != "dark" {
+		t.Fatal("Settings weren't loaded", u2.Settings)
+	}
+
+	// SQLite only has json_extract when built with the json1 extension
+	c.dialect = dr.DialectFor("postgres")
+	query, _ := c.User.Settings().JSONPath("theme").Eq("dark").QuerySQL()
+	if c.FormatQuery(query) != "SELECT User.* FROM User WHERE User.Settings #>> '{theme}' = $1" {
+		t.Fatal("JSON path", c.FormatQuery(query))
+	}
+
+	c.Close()
+}
+
+func TestUserPermissionLevel(t *testing.T) {
+	c := openTestConn()
+	u, err := createSingleUser(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u.PermissionLevel = PermissionLevelAdmin
+	err = u.Save(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+	if c.User.PermissionLevel().Eq(PermissionLevelAdmin).Count() != 1 {
+		t.Fatal("PermissionLevel wasn't stored by name")
+	}
+	if c.User.PermissionLevelNamed("Administrator").Count() != 1 {
+		t.Fatal("PermissionLevel wasn't stored as Administrator")
+	}
+
+	level, err := ParsePermissionLevel("Editor")
+	if err != nil || level != PermissionLevelEditor || level.String() != "Editor" {
+		t.Fatal("ParsePermissionLevel", level, err)
+	}
+	if _, err = ParsePermissionLevel("Owner"); err == nil {
+		t.Fatal("Parsed an unknown PermissionLevel")
+	}
+
+	u.PermissionLevel = PermissionLevel(7)
+	if u.Save(c) == nil {
+		t.Fatal("Saved an invalid PermissionLevel")
+	}
+
+	c.Close()
+}
+
+func TestUserDecimal(t *testing.T) {
+	c := openTestConn()
+	u, err := createSingleUser(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	u.ArticleCompensation = dr.MustDecimal("1234.56")
+	err = u.Save(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+	u2, err := c.User.Find(u.ID)
+	if err != nil {
+		t.Fatal("User Find", err)
+	}
+	if u2.ArticleCompensation != "1234.56" {
+		t.Fatal("ArticleCompensation wasn't loaded exactly", u2.ArticleCompensation)
+	}
+	if c.User.ArticleCompensation().Eq(u.ArticleCompensation).Count() != 1 {
+		t.Fatal("Decimal didn't bind as a number")
+	}
+	if c.User.ArticleCompensation().Gt(dr.Decimal("1234.5")).Count() != 1 {
+		t.Fatal("Decimal comparison")
+	}
+
+	u.ArticleCompensation = "12,5"
+	if u.Save(c) == nil {
+		t.Fatal("Saved an invalid decimal")
+	}
+
+	c.Close()
+}
+
+func TestUserUniqueEmail(t *testing.T) {
+	c := openTestConn()
+	u, err := createSingleUser(c)
+	if err != nil {
+		t.Fatal("User Save", err)
+	}
+
+	dup := User{Name: "Copy", Email: strings.ToUpper(u.Email)}
+	if err = dup.Save(c); err == nil {
+		t.Fatal("Saved a second user with the email", u.Email)
+	}
+
+	c.Close()
+}
+
+func TestMssqlSQL(t *testing.T) {
+	c := openTestConn()
+	defer c.Close()
+	c.dialect = dr.DialectFor("mssql")
+
+	sql, _ := c.User.Limit(5).QuerySQL()
+	if sql != "SELECT TOP 5 User.* FROM User" {
+		t.Fatal("TOP", sql)
+	}
+
+	sql, _ = c.User.Name().Eq("Cthulhu").Limit(5).Offset(10).QuerySQL()
+	if c.FormatQuery(sql) != "SELECT User.* FROM User WHERE User.Name = @p1 ORDER BY (SELECT NULL) OFFSET 10 ROWS FETCH NEXT 5 ROWS ONLY" {
+		t.Fatal("OFFSET FETCH", c.FormatQuery(sql))
+	}
+
+	sql, _ = c.User.Name().Desc().Offset(10).QuerySQL()
+	if sql != "SELECT User.* FROM User ORDER BY User.Name DESC OFFSET 10 ROWS" {
+		t.Fatal("OFFSET", sql)
+	}
+}
+
 func openTestConn() *Conn {
 	c, err := Open("sqlite3", ":memory:")
 	if err != nil {
@@ -254,8 +440,8 @@ func createSingleUser(c *Conn) (User, error) {
 	u := User{
 		Name:                "Andrew",
 		Email:               "andrew@example.com",
-		PermissionLevel:     2,
-		ArticleCompensation: 4.5,
+		PermissionLevel:     PermissionLevelEditor,
+		ArticleCompensation: "4.5",
 		TotalCompensation:   1234.45,
 		Inactive:            true,
 		CreatedAt:           time.Now(),
@@ -270,38 +456,38 @@ func createTestUsers(c *Conn) ([]User, error) {
 		User{
 			Name:                "Hastur",
 			Email:               "hastur@example.com",
-			PermissionLevel:     1,
-			ArticleCompensation: 1.2,
+			PermissionLevel:     PermissionLevelAuthor,
+			ArticleCompensation: "1.2",
 			TotalCompensation:   2.4,
 		},
 		User{
 			Name:                "Cthulhu",
 			Email:               "cthulhu@example.com",
-			PermissionLevel:     3,
-			ArticleCompensation: 1.8,
+			PermissionLevel:     PermissionLevelAdmin,
+			ArticleCompensation: "1.8",
 			TotalCompensation:   2.8,
 		},
 		User{
 			Name:                "Yog-Sothoth",
 			Email:               "yog-sothoth@example.com",
-			PermissionLevel:     2,
-			ArticleCompensation: 1.8,
+			PermissionLevel:     PermissionLevelEditor,
+			ArticleCompensation: "1.8",
 			TotalCompensation:   2.8,
 			Inactive:            true,
 		},
 		User{
 			Name:                "Tsathoggua",
 			Email:               "tsathoggua@example.com",
-			PermissionLevel:     2,
-			ArticleCompensation: 1.0,
+			PermissionLevel:     PermissionLevelEditor,
+			ArticleCompensation: "1.0",
 			TotalCompensation:   2.0,
 			Inactive:            true,
 		},
 		User{
 			Name:                "Cthugha",
 			Email:               "cthugha@example.com",
-			PermissionLevel:     1,
-			ArticleCompensation: 1.2,
+			PermissionLevel:     PermissionLevelAuthor,
+			ArticleCompensation: "1.2",
 			TotalCompensation:   2.4,
 		},
 	}
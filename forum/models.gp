@@ -7,12 +7,14 @@ type User table {
   Email, password string
 	BanExpiration *time.Time
 
-  Posts []Post `column:"AuthorID"`
-  Threads []Thread `column:"AuthorID"`
-  postLikes []postLike
-  LikedPosts []Post `through:"postLikes"`
-  Blather Blather
   Timestamps
+
+  relation {
+    Posts []Post `column:"AuthorID"`
+    Threads []Thread `column:"AuthorID"`
+    []postLike
+    Blather
+  }
 }
 
 type Blather table {
@@ -28,6 +30,10 @@ type Blather table {
   AnswerB string
   AnswerC string
   AnswerD string
+
+  relation {
+    User
+  }
 }
 
 type Forum table {
@@ -36,10 +42,10 @@ type Forum table {
 
   ForumBlather
 
-	mods []forumMod
-	Moderators []User `through:"mods"`
-	pinneds []pinnedThread
-	PinnedThreads []Thread `through:"pinneds"`
+  relation {
+    []forumMod
+    []pinnedThread
+  }
 }
 
 type ForumBlather subrecord {
@@ -53,43 +59,62 @@ type ForumBlather subrecord {
 type forumMod table {
 	ID int
 	ForumID int
-	UserID int `child:"User"`
+	UserID int
+
+  relation {
+    Forum
+    User
+  }
 }
 
 type pinnedThread table {
 	ID int
 	ForumID int
-	ThreadID int `child:"Thread"`
+	ThreadID int
+
+  relation {
+    Forum
+    Thread
+  }
 }
 
 type Thread table {
 	ID int
 	Title string
 	AuthorID int
-	Author User
-  Posts []Post
 	Locked bool
+
+  relation {
+    Author User
+    []Post
+  }
 }
 
 type Post table {
 	ID int
 	ThreadID int
-	Thread Thread
 	Number int
 	AuthorID int
-	Author User
 	ParentID *int
-	Parent *Post
-
 	Body string `type:"text"`
-	likes []postLike
-	Likers []User `through:"likes"`
+
+  relation {
+    Thread
+    Author User
+    Parent Post
+    []postLike
+  }
 }
 
 type postLike table {
 	ID int
 	PostID int
 	UserID int
+
+  relation {
+    Post
+    User
+  }
 }
 
 type Timestamps mixin {
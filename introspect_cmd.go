@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/acsellers/dr/migrate"
+	"github.com/acsellers/dr/parse"
+	"github.com/codegangsta/cli"
+)
+
+// runIntrospect reads the tables of an existing database and writes them
+// out as a .gp file. The names are converted from the Rails conventions,
+// so the package's db_config.go has to use rails naming to find them again.
+func runIntrospect(c *cli.Context) {
+	if c.String("driver") == "" || c.String("dsn") == "" {
+		log.Fatal("Both --driver and --dsn are required")
+	}
+	if _, err := os.Stat(c.String("out")); err == nil {
+		log.Fatal("Refusing to overwrite existing file:", c.String("out"))
+	}
+
+	db, err := sql.Open(c.String("driver"), c.String("dsn"))
+	if err != nil {
+		log.Fatal("Couldn't open database got error:", err)
+	}
+	defer db.Close()
+
+	d := migrate.Database{
+		DB:         db,
+		Translator: migrate.Untranslated{},
+		DBMS:       migrate.SystemFor(c.String("driver")),
+	}
+	tables, err := d.Introspect()
+	if err != nil {
+		log.Fatal("Couldn't read database got error:", err)
+	}
+
+	pkgName := c.String("package")
+	if pkgName == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			log.Fatal(err)
+		}
+		pkgName = filepath.Base(wd)
+	}
+
+	f, err := os.Create(c.String("out"))
+	if err != nil {
+		log.Fatal("Couldn't create file got error:", err)
+	}
+	defer f.Close()
+	err = parse.WriteIntrospected(f, pkgName, c.String("driver"), tables)
+	if err != nil {
+		log.Fatal("Couldn't write file got error:", err)
+	}
+	log.Printf("Wrote %s, set naming = \"rails\" in dr.toml before the first dr build", c.String("out"))
+}
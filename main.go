@@ -3,7 +3,7 @@ package main
 import (
 	"log"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/acsellers/dr/parse"
 	"github.com/codegangsta/cli"
@@ -18,8 +18,13 @@ func main() {
 			Name:      "init",
 			ShortName: "i",
 			Usage:     "Create base code and go generate task",
+			Flags:     configFlags,
 			Action: func(c *cli.Context) {
-				pkg := parse.Package{}
+				cfg, err := configFor(c)
+				if err != nil {
+					log.Fatal("Couldn't read config got error:", err)
+				}
+				pkg := parse.Package{Config: cfg}
 				pkg.SetName(c.Args().First())
 				pkg.WriteLibraryFiles()
 				pkg.WriteStarterFile()
@@ -29,24 +34,43 @@ func main() {
 			Name:      "build",
 			ShortName: "b",
 			Usage:     "Create the access library",
-			Action: func(c *cli.Context) {
-				pkg := parse.Package{Funcs: make(map[string][]parse.Func)}
-				names, _ := filepath.Glob("*.gp")
-				files := make([]*os.File, 0, len(names))
-				for _, name := range names {
-					f, err := os.Open(name)
-					if err != nil {
-						log.Fatal("Couldn't open file:", name, "got error:", err)
-					}
-					files = append(files, f)
-					defer f.Close()
-				}
-				err := pkg.ParseSrc(files...)
-				if err != nil {
-					log.Fatal("Couldn't parse files got error:", err)
-				}
-				pkg.WriteLibraryFiles()
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{Name: "check", Usage: "report generated files that are out of date instead of writing them"},
+				cli.BoolFlag{Name: "watch", Usage: "rebuild whenever a .gp file changes"},
+				cli.DurationFlag{Name: "interval", Value: time.Second, Usage: "how often --watch checks the .gp files"},
+			}, configFlags...),
+			Action: runBuild,
+		},
+		{
+			Name:      "migrate",
+			ShortName: "m",
+			Usage:     "Migrate a database to match the package's Schema",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "driver", Usage: "database/sql driver name"},
+				cli.StringFlag{Name: "dsn", Usage: "data source name for the driver"},
+				cli.StringFlag{Name: "driver-import", Usage: "import path of the driver package, for unknown drivers"},
+				cli.StringFlag{Name: "config", Usage: "config file to use instead of dr.toml or dr.json"},
+				cli.StringFlag{Name: "out", Usage: "directory holding the generated code"},
+				cli.BoolFlag{Name: "dry-run", Usage: "print the statements instead of running them"},
+				cli.BoolFlag{Name: "prune", Usage: "remove tables and columns not in the schema"},
+				cli.BoolFlag{Name: "status", Usage: "report the tables that need migrating"},
+				cli.BoolFlag{Name: "verbose", Usage: "log migration progress"},
+			},
+			Action: runMigrate,
+		},
+		{
+			Name:  "introspect",
+			Usage: "Write table definitions for an existing database",
+			Description: "Table and column names are converted from the Rails conventions, set\n" +
+				"   naming = \"rails\" in dr.toml before the package is first built, so its\n" +
+				"   db_config.go maps them back onto the existing database.",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "driver", Usage: "database/sql driver name"},
+				cli.StringFlag{Name: "dsn", Usage: "data source name for the driver"},
+				cli.StringFlag{Name: "out", Value: "introspected.gp", Usage: "gp file to write"},
+				cli.StringFlag{Name: "package", Usage: "package name, defaults to the directory name"},
 			},
+			Action: runIntrospect,
 		},
 	}
 	app.Run(os.Args)
@@ -0,0 +1,535 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/acsellers/dr/schema"
+)
+
+// Introspector is implemented by the RDBMS specific Alterers that can read
+// an existing database back into schema form. Table and column names are
+// returned exactly as the database reports them.
+type Introspector interface {
+	ListTables() ([]string, error)
+	TableColumns(table string) ([]IntrospectedColumn, error)
+	TableIndexes(table string) ([]schema.Index, error)
+	TableForeignKeys(table string) ([]ForeignKey, error)
+}
+
+type IntrospectedColumn struct {
+	schema.Column
+	PrimaryKey bool
+}
+
+type ForeignKey struct {
+	Column       string
+	ParentTable  string
+	ParentColumn string
+	// Name is the name of the constraint, SQLite doesn't name them
+	Name string
+	// OnDelete and OnUpdate are the actions of the key, as CASCADE, SET
+	// NULL, SET DEFAULT, RESTRICT or NO ACTION
+	OnDelete, OnUpdate string
+	Deferrable         bool
+}
+
+// IntrospectedTable is a table read from the database, the Table's Columns
+// always start with the primary key to match what Doctor expects.
+type IntrospectedTable struct {
+	schema.Table
+	ForeignKeys []ForeignKey
+	// Unique holds the indexes that were unique in the database
+	Unique []schema.Index
+}
+
+// Introspect reads every table in the database along with its columns,
+// indexes and foreign keys.
+func (d *Database) Introspect() ([]IntrospectedTable, error) {
+	if d.Alterer == nil {
+		d.SetAlterer()
+	}
+	in, ok := d.Alterer.(Introspector)
+	if !ok {
+		return nil, fmt.Errorf("Introspection is not supported for %v", d.Alterer)
+	}
+
+	names, err := in.ListTables()
+	if err != nil {
+		return nil, fmt.Errorf("Listing tables: %v", err)
+	}
+
+	tables := []IntrospectedTable{}
+	for _, name := range names {
+		table := IntrospectedTable{Table: schema.Table{Name: name}}
+		cols, err := in.TableColumns(name)
+		if err != nil {
+			return nil, fmt.Errorf("Reading columns for %s: %v", name, err)
+		}
+		for _, col := range cols {
+			if col.PrimaryKey {
+				table.Columns = append([]schema.Column{col.Column}, table.Columns...)
+			} else {
+				table.Columns = append(table.Columns, col.Column)
+			}
+		}
+
+		indexes, err := in.TableIndexes(name)
+		if err != nil {
+			return nil, fmt.Errorf("Reading indexes for %s: %v", name, err)
+		}
+		for _, index := range indexes {
+			if index.Unique {
+				table.Unique = append(table.Unique, index)
+			} else {
+				table.Index = append(table.Index, index)
+			}
+		}
+
+		table.ForeignKeys, err = in.TableForeignKeys(name)
+		if err != nil {
+			return nil, fmt.Errorf("Reading foreign keys for %s: %v", name, err)
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+var typeLength = regexp.MustCompile(`^([a-z ]+?)\s*\((\d+)(,\s*\d+)?\)(.*)$`)
+
+// NormalizeType turns a column type as reported by a database into the
+// type names used by schema.Column, along with any length it declared.
+func NormalizeType(dbType string) (string, int) {
+	t := strings.ToLower(strings.TrimSpace(dbType))
+	length := 0
+	if strings.HasSuffix(t, "(max)") {
+		if strings.Contains(t, "binary") {
+			return "blob", 0
+		}
+		return "text", 0
+	}
+	if m := typeLength.FindStringSubmatch(t); m != nil {
+		l, _ := strconv.Atoi(m[2])
+		length = l
+		t = strings.TrimSpace(m[1] + m[4])
+	}
+	t = strings.TrimSuffix(t, " unsigned")
+
+	switch t {
+	case "int", "int4", "integer", "serial", "mediumint":
+		return "integer", length
+	case "smallint", "int2", "smallserial":
+		return "smallint", 0
+	case "bigint", "int8", "bigserial":
+		return "bigint", 0
+	case "tinyint":
+		if length == 1 {
+			return "boolean", 0
+		}
+		return "smallint", 0
+	case "varchar", "character varying", "nvarchar", "char", "nchar", "character":
+		if length == 0 {
+			return "text", 0
+		}
+		return "varchar", length
+	case "text", "clob", "ntext", "mediumtext", "longtext", "tinytext":
+		return "text", 0
+	case "real", "float4":
+		return "real", 0
+	case "double", "double precision", "float", "float8":
+		return "double precision", 0
+	case "bool", "boolean", "bit":
+		return "boolean", 0
+	case "blob", "bytea", "varbinary", "binary", "image", "longblob", "mediumblob":
+		return "blob", 0
+	case "timestamp", "timestamp with time zone", "timestamp without time zone", "timestamptz", "datetime", "datetime2", "date":
+		return "timestamp", 0
+	case "json", "jsonb":
+		return "json", 0
+	case "numeric", "decimal":
+		return "decimal", 0
+	}
+	return t, length
+}
+
+// decimalSize reads the precision and scale from a type like
+// NUMERIC(12,2), which SQLite and MySQL report in the column type
+func decimalSize(dbType string) (int, int) {
+	m := typeLength.FindStringSubmatch(strings.ToLower(strings.TrimSpace(dbType)))
+	if m == nil {
+		return 0, 0
+	}
+	precision, _ := strconv.Atoi(m[2])
+	scale, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(m[3], ",")))
+	return precision, scale
+}
+
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []string{}
+	for rows.Next() {
+		var s string
+		err = rows.Scan(&s)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
+
+// namedIndex is how the catalog queries report indexes before they are
+// matched against a schema.Index or turned into one.
+type namedIndex struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// groupIndexColumns collects rows of (index name, unique, column name)
+// ordered by index and column position into namedIndexes.
+func groupIndexColumns(rows *sql.Rows) ([]namedIndex, error) {
+	defer rows.Close()
+	indexes := []namedIndex{}
+	var indexName, columnName string
+	var unique bool
+	for rows.Next() {
+		err := rows.Scan(&indexName, &unique, &columnName)
+		if err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != indexName {
+			indexes = append(indexes, namedIndex{Name: indexName, Unique: unique})
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, columnName)
+	}
+	return indexes, rows.Err()
+}
+
+// hasExpression is whether any of the index's columns is an expression
+func (n namedIndex) hasExpression() bool {
+	for _, column := range n.Columns {
+		if column == "" || strings.ContainsAny(column, "( ") {
+			return true
+		}
+	}
+	return false
+}
+
+// findIndex finds the name of the index in the database matching index.
+// Indexes are found by name, and plain indexes by their columns as well so
+// indexes made under other names are found.
+func (g *GenericDB) findIndex(indexes []namedIndex, table *schema.Table, index schema.Index) string {
+	name := g.indexName(table, index)
+	columns := make([]string, len(index.Columns))
+	for i, col := range index.Columns {
+		columns[i] = g.Convert.SQLColumn(table.Name, col)
+	}
+	search := strings.Join(columns, ",")
+
+	for _, dbindex := range indexes {
+		if dbindex.Unique != index.Unique {
+			continue
+		}
+		if dbindex.Name == name || index.Plain() && strings.Join(dbindex.Columns, ",") == search {
+			return dbindex.Name
+		}
+	}
+	return ""
+}
+
+func schemaIndexes(indexes []namedIndex) []schema.Index {
+	results := make([]schema.Index, len(indexes))
+	for i, index := range indexes {
+		results[i] = schema.Index{Columns: index.Columns, Unique: index.Unique}
+	}
+	return results
+}
+
+func scanForeignKeys(rows *sql.Rows) ([]ForeignKey, error) {
+	defer rows.Close()
+	keys := []ForeignKey{}
+	for rows.Next() {
+		var fk ForeignKey
+		err := rows.Scan(&fk.Column, &fk.ParentTable, &fk.ParentColumn, &fk.Name, &fk.OnDelete, &fk.OnUpdate, &fk.Deferrable)
+		if err != nil {
+			return nil, err
+		}
+		fk.OnDelete = strings.Replace(fk.OnDelete, "_", " ", -1)
+		fk.OnUpdate = strings.Replace(fk.OnUpdate, "_", " ", -1)
+		keys = append(keys, fk)
+	}
+	return keys, rows.Err()
+}
+
+// changedForeignKeys finds the foreign keys of a table that are missing
+// from the database, or that have other actions there. Keys replacing one
+// in the database have the Name of the one they replace.
+func (g *GenericDB) changedForeignKeys(table *schema.Table) ([]ForeignKey, error) {
+	in, ok := g.Specific.(Introspector)
+	if !ok {
+		return nil, nil
+	}
+	have, err := in.TableForeignKeys(g.Convert.SQLTable(table.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []ForeignKey{}
+WantLoop:
+	for _, want := range g.foreignKeys(table) {
+		for _, fk := range have {
+			if fk.Column != want.Column || fk.ParentTable != want.ParentTable {
+				continue
+			}
+			if keyAction(fk.OnDelete) != keyAction(want.OnDelete) || keyAction(fk.OnUpdate) != keyAction(want.OnUpdate) || fk.Deferrable != want.Deferrable {
+				want.Name = fk.Name
+				changed = append(changed, want)
+			}
+			continue WantLoop
+		}
+		changed = append(changed, want)
+	}
+	return changed, nil
+}
+
+// keyAction is a foreign key action for comparison, RESTRICT only differs
+// from NO ACTION in when it's checked, and NO ACTION is what every
+// database does by default
+func keyAction(action string) string {
+	action = strings.ToUpper(action)
+	if action == "" || action == "RESTRICT" {
+		return "NO ACTION"
+	}
+	return action
+}
+
+// changedColumns finds the columns of an existing table whose constraints
+// differ from the ones in the database. Columns that aren't in the database
+// yet and the primary key are left out, and nothing is found for
+// databases that can't be introspected.
+func (g *GenericDB) changedColumns(table *schema.Table) ([]*schema.Column, error) {
+	in, ok := g.Specific.(Introspector)
+	if !ok {
+		return nil, nil
+	}
+	name := g.Convert.SQLTable(table.Name)
+	cols, err := in.TableColumns(name)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := in.TableIndexes(name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]*IntrospectedColumn{}
+	for i := range cols {
+		current[cols[i].Name] = &cols[i]
+	}
+	for _, index := range indexes {
+		if !index.Unique || len(index.Columns) != 1 {
+			continue
+		}
+		if col := current[index.Columns[0]]; col != nil {
+			col.Unique = true
+		}
+	}
+
+	_, comments := g.Specific.(commenter)
+	changed := []*schema.Column{}
+	for i := range table.Columns {
+		col := &table.Columns[i]
+		have := current[g.Convert.SQLColumn(table.Name, col.Name)]
+		if have == nil || have.PrimaryKey {
+			continue
+		}
+		if g.constraintsDiffer(table, col, have.Column, comments) {
+			changed = append(changed, col)
+		}
+	}
+	return changed, nil
+}
+
+// constraintsDiffer compares the constraints of a column to those read
+// from the database. Expressions are compared loosely as databases rewrite
+// them, and the zero defaults given to added columns count as no default.
+func (g *GenericDB) constraintsDiffer(table *schema.Table, want *schema.Column, have schema.Column, comments bool) bool {
+	if want.Null != have.Null || uniqueColumn(table, want) != have.Unique {
+		return true
+	}
+	if comments && want.Comment != have.Comment {
+		return true
+	}
+	if normalizeExpr(strings.Join(g.columnChecks(table, want), " AND ")) != normalizeExpr(have.Check) {
+		return true
+	}
+
+	zeros := g.ZeroDefaults
+	if zeros == nil {
+		zeros = zeroDefaults
+	}
+	haveDefault := normalizeExpr(have.Default)
+	if zero := normalizeExpr(zeros[want.Type]); want.Default == "" && zero != "" {
+		// timestamps come back with the time zone they were stored in
+		if haveDefault == zero || want.Type == "timestamp" && strings.HasPrefix(haveDefault, zero) {
+			return false
+		}
+	}
+	if want.Default == "" && want.Type == "enum" && len(want.Values) > 0 && haveDefault == normalizeExpr(quoteValues(want.Values[:1])) {
+		return false
+	}
+	return normalizeExpr(want.Default) != haveDefault
+}
+
+// uniqueColumn is whether a column is unique, either from its own tag or
+// an index on the table covering only that column
+func uniqueColumn(table *schema.Table, col *schema.Column) bool {
+	if col.Unique {
+		return true
+	}
+	for _, index := range table.Index {
+		if index.Unique && len(index.Columns) == 1 && index.Columns[0] == col.Name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	exprCasts = regexp.MustCompile(`::(timestamp with(out)? time zone|character varying|double precision|[a-z_]+)`)
+	exprIn    = regexp.MustCompile(`(\S+) in \(([^()]*)\)`)
+	exprNoise = strings.NewReplacer(" ", "", "\t", "", "\n", "", "(", "", ")", "", "`", "", "[", "", "]", "", `"`, "", "'", "")
+)
+
+// normalizeExpr reduces a default or check expression to a form that
+// survives the rewriting databases do when storing them: no whitespace,
+// parentheses, quoting or casts, IN lists as sorted comparisons joined by
+// OR, and booleans as 0 and 1.
+func normalizeExpr(expr string) string {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	expr = strings.TrimPrefix(expr, "check")
+	expr = exprCasts.ReplaceAllString(expr, "")
+	expr = exprIn.ReplaceAllStringFunc(expr, func(in string) string {
+		m := exprIn.FindStringSubmatch(in)
+		terms := []string{}
+		for _, value := range strings.Split(m[2], ",") {
+			terms = append(terms, m[1]+" = "+strings.TrimSpace(value))
+		}
+		return strings.Join(terms, " or ")
+	})
+	terms := strings.Split(expr, " or ")
+	for i, term := range terms {
+		terms[i] = exprNoise.Replace(term)
+	}
+	sort.Strings(terms)
+	expr = strings.Join(terms, " or ")
+	switch expr {
+	case "false":
+		return "0"
+	case "true":
+		return "1"
+	}
+	return expr
+}
+
+// sqliteChecks reads the CHECK constraints of each column out of the
+// CREATE TABLE statement SQLite keeps for a table, joined with AND as the
+// other databases report them.
+func sqliteChecks(create string) map[string]string {
+	checks := map[string]string{}
+	start, end := strings.Index(create, "("), strings.LastIndex(create, ")")
+	if start < 0 || end < start {
+		return checks
+	}
+
+	for _, def := range splitSQL(create[start+1:end], ',') {
+		fields := strings.Fields(def)
+		if len(fields) < 2 {
+			continue
+		}
+		exprs := []string{}
+		parts := splitSQL(def, ' ')
+		for i, part := range parts {
+			if !strings.EqualFold(part, "check") || i+1 == len(parts) {
+				continue
+			}
+			expr := parts[i+1]
+			if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+				exprs = append(exprs, expr[1:len(expr)-1])
+			}
+		}
+		if len(exprs) > 0 {
+			checks[strings.Trim(fields[0], "`\"[]")] = strings.Join(exprs, " AND ")
+		}
+	}
+	return checks
+}
+
+// sqliteDeferred finds the columns with deferred foreign keys in the
+// CREATE TABLE statement SQLite keeps for a table
+func sqliteDeferred(create string) map[string]bool {
+	deferred := map[string]bool{}
+	start, end := strings.Index(create, "("), strings.LastIndex(create, ")")
+	if start < 0 || end < start {
+		return deferred
+	}
+	for _, def := range splitSQL(create[start+1:end], ',') {
+		parts := splitSQL(def, ' ')
+		if len(parts) < 3 || !strings.EqualFold(parts[0]+" "+parts[1], "foreign key") {
+			continue
+		}
+		if strings.Contains(strings.ToUpper(def), "DEFERRABLE INITIALLY DEFERRED") {
+			column := strings.Trim(parts[2], "()`\"[] ")
+			deferred[column] = true
+		}
+	}
+	return deferred
+}
+
+// splitSQL splits SQL on sep outside of parentheses and quotes, dropping
+// empty parts. A parenthesized part following a word is split from it.
+func splitSQL(sql string, sep byte) []string {
+	parts := []string{}
+	depth, quote, start := 0, byte(0), 0
+	cut := func(end int) {
+		if part := strings.TrimSpace(sql[start:end]); part != "" {
+			parts = append(parts, part)
+		}
+		start = end
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			if depth == 0 && sep == ' ' {
+				cut(i)
+			}
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			cut(i)
+			start = i + 1
+		}
+	}
+	cut(len(sql))
+	return parts
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"sort"
 
 	"github.com/acsellers/dr/schema"
 )
@@ -18,13 +19,41 @@ const (
 	MySQL
 	Sqlite
 	Postgres
+	MSSQL
 )
 
+// SystemFor picks the System that matches a database/sql driver name
+func SystemFor(driverName string) System {
+	switch driverName {
+	case "sqlite3", "sqlite":
+		return Sqlite
+	case "postgres", "pgx":
+		return Postgres
+	case "mysql":
+		return MySQL
+	case "mssql", "sqlserver":
+		return MSSQL
+	}
+	return Generic
+}
+
 type Translator interface {
 	SQLTable(string) string
 	SQLColumn(string, string) string
 }
 
+// Untranslated passes names through unchanged, for when the names being
+// dealt with are already the ones in the database
+type Untranslated struct{}
+
+func (Untranslated) SQLTable(table string) string {
+	return table
+}
+
+func (Untranslated) SQLColumn(table, column string) string {
+	return column
+}
+
 type Alterer interface {
 	HasTable(*schema.Table) (bool, error)
 	CreateTable(*schema.Table) error
@@ -42,6 +71,10 @@ type Alterer interface {
 	getIndexName(*schema.Table, schema.Index) (string, error)
 	CreateIndex(*schema.Table, schema.Index) error
 	RemoveIndex(*schema.Table, schema.Index) error
+
+	setup()
+	changedColumns(*schema.Table) ([]*schema.Column, error)
+	changedForeignKeys(*schema.Table) ([]ForeignKey, error)
 }
 
 type Database struct {
@@ -53,6 +86,15 @@ type Database struct {
 	ModifiedTables []*schema.Table
 	DBMS           System
 	Log            *log.Logger
+
+	// DryRun plans the migration, collecting the statements in Planned
+	// rather than running them
+	DryRun  bool
+	Planned []string
+	// Prune removes tables and columns that aren't in the Schema
+	Prune bool
+
+	created map[string]bool
 }
 
 func (d *Database) ForeignKeysSatisfied(table *schema.Table) bool {
@@ -61,14 +103,17 @@ func (d *Database) ForeignKeysSatisfied(table *schema.Table) bool {
 	}
 
 	for _, child := range table.ChildOf {
+		if child.TypeColumn != nil {
+			continue
+		}
 		ok, _ := d.HasTable(child.Parent)
-		if !ok && child.Parent.Name != table.Name {
+		if !ok && !d.created[child.Parent.Name] && child.Parent.Name != table.Name {
 			return false
 		}
 	}
 	for _, belong := range table.BelongsTo {
 		ok, _ := d.HasTable(belong.Parent)
-		if !ok && belong.Parent.Name != table.Name {
+		if !ok && !d.created[belong.Parent.Name] && belong.Parent.Name != table.Name {
 			return false
 		}
 	}
@@ -78,8 +123,16 @@ func (d *Database) ForeignKeysSatisfied(table *schema.Table) bool {
 
 func (d *Database) UpToDate() (bool, error) {
 	needUpdate := true
+	// check tables in a stable order so plans come out the same every time
+	names := make([]string, 0, len(d.Schema.Tables))
+	for name := range d.Schema.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 TableIter:
-	for _, table := range d.Schema.Tables {
+	for _, name := range names {
+		table := d.Schema.Tables[name]
 		d.Log.Println("Checking For Table:", table.Name)
 		exists, err := d.HasTable(table)
 		if err != nil {
@@ -110,6 +163,26 @@ TableIter:
 				continue TableIter
 			}
 		}
+		changed, err := d.changedColumns(table)
+		if err != nil {
+			return false, err
+		}
+		if len(changed) > 0 {
+			d.Log.Println("Changed Constraints on", len(changed), "Column(s) for Table", table.Name)
+			d.ModifiedTables = append(d.ModifiedTables, table)
+			needUpdate = false
+			continue TableIter
+		}
+		keys, err := d.changedForeignKeys(table)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) > 0 {
+			d.Log.Println("Changed", len(keys), "Foreign Key(s) for Table", table.Name)
+			d.ModifiedTables = append(d.ModifiedTables, table)
+			needUpdate = false
+			continue TableIter
+		}
 		for _, index := range table.Index {
 			ok, err := d.HasIndex(table, index)
 			if err != nil {
@@ -142,57 +215,151 @@ func (d *Database) Migrate() error {
 
 	d.Log.Println("Beginning Check for Tables that need Migrations")
 	current, err := d.UpToDate()
-	if err != nil || current {
+	if err != nil {
 		return err
 	}
 
+	if !current {
+		err = d.createTables()
+		if err != nil {
+			return err
+		}
+
+		d.Log.Printf("Modifying Existing Tables (%d)\n", len(d.ModifiedTables))
+		for _, table := range d.ModifiedTables {
+			err = d.UpdateTable(table)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.Prune {
+		d.Log.Println("Removing Tables and Columns not in the Schema")
+		err = d.PareFields()
+		if err != nil {
+			return err
+		}
+	}
+
+	d.Log.Println("Completed migration")
+	return nil
+}
+
+// Plan works out the statements Migrate would run without running them
+func (d *Database) Plan() ([]string, error) {
+	d.DryRun = true
+	d.Planned = nil
+	err := d.Migrate()
+	return d.Planned, err
+}
+
+func (d *Database) createTables() error {
 	d.Log.Printf("Creating New Tables (%d)\n", len(d.NewTables))
+	d.created = map[string]bool{}
 	wait := []*schema.Table{}
 	for _, table := range d.NewTables {
 		if d.ForeignKeysSatisfied(table) {
-			fmt.Println("Maybe", table.Name)
-			err = d.CreateTable(table)
+			err := d.CreateTable(table)
 			if err != nil {
 				return err
 			}
+			d.created[table.Name] = true
 		} else {
-			fmt.Println("Not", table.Name)
+			d.Log.Println("Waiting on foreign keys for", table.Name)
 			wait = append(wait, table)
 		}
 	}
 
 	for len(wait) > 0 {
 		if d.ForeignKeysSatisfied(wait[0]) {
-			err = d.CreateTable(wait[0])
+			err := d.CreateTable(wait[0])
 			if err != nil {
 				return err
 			}
+			d.created[wait[0].Name] = true
 			wait = wait[1:]
 		} else {
 			wait = append(wait[1:], wait[0])
 		}
 	}
-
-	d.Log.Printf("Modifying Existing Tables (%d)\n", len(d.ModifiedTables))
-	for _, table := range d.ModifiedTables {
-		d.UpdateTable(table)
-	}
-
-	d.Log.Println("Completed migration")
 	return nil
 }
 
+type pruner interface {
+	dropTable(string) error
+	dropColumn(string, string) error
+}
+
+// PareFields removes the tables and columns in the database that aren't
+// mentioned in the Schema, which needs an Alterer that can introspect.
 func (d *Database) PareFields() error {
+	in, ok := d.Alterer.(Introspector)
+	if !ok {
+		return fmt.Errorf("Pruning is not supported for %v", d.Alterer)
+	}
+	pr, ok := d.Alterer.(pruner)
+	if !ok {
+		return fmt.Errorf("Pruning is not supported for %v", d.Alterer)
+	}
+
+	known := map[string]*schema.Table{}
+	for _, table := range d.Schema.Tables {
+		known[d.SQLTable(table.Name)] = table
+	}
+
+	names, err := in.ListTables()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		table, ok := known[name]
+		if !ok {
+			d.Log.Println("Removing Table", name)
+			err = pr.dropTable(name)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		columns := map[string]bool{}
+		for _, col := range table.Columns {
+			columns[d.SQLColumn(table.Name, col.Name)] = true
+		}
+		cols, err := in.TableColumns(name)
+		if err != nil {
+			return err
+		}
+		for _, col := range cols {
+			if !columns[col.Name] {
+				d.Log.Println("Removing Column", col.Name, "from", name)
+				err = pr.dropColumn(name, col.Name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
 
 func (d *Database) SetAlterer() {
+	g := GenericDB{DB: d.DB, Convert: d.Translator, Log: d.Log}
+	if d.DryRun {
+		g.Planned = &d.Planned
+	}
 	switch d.DBMS {
 	case Sqlite:
-		d.Alterer = &SqliteDB{GenericDB{DB: d.DB, Convert: d.Translator, Log: d.Log}}
+		d.Alterer = &SqliteDB{g}
 	case Postgres:
-		d.Alterer = &PostgresDB{GenericDB{DB: d.DB, Convert: d.Translator, Log: d.Log}}
+		d.Alterer = &PostgresDB{g}
 	case MySQL:
-		d.Alterer = &MysqlDB{GenericDB{DB: d.DB, Convert: d.Translator, Log: d.Log}}
+		d.Alterer = &MysqlDB{g}
+	case MSSQL:
+		d.Alterer = &MssqlDB{g}
+	}
+	if d.Alterer != nil {
+		d.Alterer.setup()
 	}
 }
@@ -1,22 +1,269 @@
 package migrate
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/acsellers/dr/schema"
 )
 
 type GenericDB struct {
-	DB                *sql.DB
-	Specific          Alterer
-	Convert           Translator
-	AlternateNames    map[string]string
-	Log               *log.Logger
-	PrimaryKeyDef     string
+	DB             *sql.DB
+	Specific       Alterer
+	Convert        Translator
+	AlternateNames map[string]string
+	Log            *log.Logger
+	PrimaryKeyDef  string
+	// BigPrimaryKeyDef is used for primary keys wider than an integer,
+	// PrimaryKeyDef is used when it isn't set
+	BigPrimaryKeyDef  string
 	LengthableColumns map[string]bool
+	// ZeroDefaults fill existing rows when a NOT NULL column is added,
+	// zeroDefaults is used when it isn't set
+	ZeroDefaults map[string]string
+	// UnsignedColumns is set for databases with UNSIGNED integer columns
+	UnsignedColumns bool
+	// InlineComments is set for databases with a COMMENT clause in column
+	// definitions, other databases are sent their comments through a
+	// commenter
+	InlineComments bool
+	// EnumType gives the native type for an enum column, enums are a
+	// VARCHAR with a CHECK constraint when it isn't set
+	EnumType func(col *schema.Column) (string, error)
+	// KeyActions replaces foreign key actions the database doesn't have,
+	// an action replaced with nothing isn't supported
+	KeyActions map[string]string
+	// DeferrableKeys is set for databases that can defer checking foreign
+	// keys to the end of the transaction
+	DeferrableKeys bool
+	// DropForeignKey is the clause of an ALTER TABLE dropping the foreign
+	// key named by %s, DROP CONSTRAINT is used when it's empty
+	DropForeignKey string
+	// Planned collects statements instead of running them when set
+	Planned *[]string
+}
+
+// exec runs a statement that changes the database, unless the migration
+// is only being planned, in which case the statement is recorded instead.
+func (g *GenericDB) exec(query string, args ...interface{}) error {
+	if g.Log != nil {
+		g.Log.Println(query, args)
+	}
+	if g.Planned != nil {
+		*g.Planned = append(*g.Planned, query)
+		return nil
+	}
+	_, err := g.DB.Exec(query, args...)
+	return err
+}
+
+// zeroDefaults are the Go zero values for each column type, they are the
+// defaults given to NOT NULL columns added to tables that may have rows
+var zeroDefaults = map[string]string{
+	"smallint":         "0",
+	"integer":          "0",
+	"bigint":           "0",
+	"real":             "0",
+	"double precision": "0",
+	"varchar":          "''",
+	"text":             "''",
+	"boolean":          "FALSE",
+	"bool":             "FALSE",
+	"timestamp":        "'0001-01-01 00:00:00'",
+	"decimal":          "0",
+	"json":             "'null'",
+	"blob":             "''",
+}
+
+// nullDef is the NULL constraint and default for a column definition. A
+// column being added to an existing table without a default only gets NOT
+// NULL when its type has a zero value to fill in the existing rows with.
+func nullDef(col *schema.Column, adding bool, zeros map[string]string) string {
+	def := ""
+	if col.Default != "" {
+		def = " DEFAULT " + col.Default
+	}
+	if col.Null {
+		return def
+	}
+	if !adding || def != "" {
+		return " NOT NULL" + def
+	}
+	if col.Type == "enum" && len(col.Values) > 0 {
+		return " NOT NULL DEFAULT " + quoteValues(col.Values[:1])
+	}
+	if zeros == nil {
+		zeros = zeroDefaults
+	}
+	if zero, ok := zeros[col.Type]; ok {
+		return " NOT NULL DEFAULT " + zero
+	}
+	return ""
+}
+
+// integerColumns are the column types an auto incrementing primary key can
+// be declared with
+var integerColumns = map[string]bool{
+	"smallint": true,
+	"integer":  true,
+	"bigint":   true,
+}
+
+// widerIntegers hold every value of an unsigned integer column in
+// databases without unsigned columns
+var widerIntegers = map[string]string{
+	"smallint": "integer",
+	"integer":  "bigint",
+}
+
+// quoteValues lists values as SQL strings
+func quoteValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.Replace(v, "'", "''", -1) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// typeName is the name the database uses for a column type
+func (g *GenericDB) typeName(colType string) string {
+	ct := strings.ToUpper(colType)
+	if g.AlternateNames != nil && g.AlternateNames[ct] != "" {
+		return g.AlternateNames[ct]
+	}
+	return ct
+}
+
+// columnDef is the definition of a column in a CREATE TABLE, or in an ALTER
+// TABLE when adding is set
+func (g *GenericDB) columnDef(table *schema.Table, col *schema.Column, adding bool) (string, error) {
+	coldef, err := g.columnType(table, col)
+	if err != nil {
+		return "", err
+	}
+	coldef += nullDef(col, adding, g.ZeroDefaults)
+	if col.Unique {
+		coldef += " UNIQUE"
+	}
+	coldef += g.inlineComment(col)
+	for _, check := range g.columnChecks(table, col) {
+		coldef += " CHECK (" + check + ")"
+	}
+	return coldef, nil
+}
+
+// columnType is the name and type of a column, without any constraints
+func (g *GenericDB) columnType(table *schema.Table, col *schema.Column) (string, error) {
+	ct := g.typeName(col.Type)
+	if col.Type == "enum" {
+		if g.EnumType != nil {
+			var err error
+			ct, err = g.EnumType(col)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			ct = fmt.Sprintf("%s(%d)", g.typeName("varchar"), col.Length)
+		}
+	}
+
+	if col.Type == "decimal" {
+		ct = fmt.Sprintf("NUMERIC(%d,%d)", col.Precision, col.Scale)
+	}
+	colType, unsigned := col.Type, ""
+	if col.Unsigned {
+		if g.UnsignedColumns {
+			unsigned = " UNSIGNED"
+		} else if wider := widerIntegers[col.Type]; wider != "" {
+			colType, ct = wider, g.typeName(wider)
+		}
+	}
+
+	coldef := fmt.Sprintf("%s %s", g.Convert.SQLColumn(table.Name, col.Name), ct)
+	if col.Length != 0 && g.LengthableColumns[colType] {
+		coldef += fmt.Sprintf(
+			"(%d)", col.Length,
+		)
+	}
+	return coldef + unsigned, nil
+}
+
+// columnChecks are the CHECK constraints of a column, enums without a
+// native type are limited to their values by one
+func (g *GenericDB) columnChecks(table *schema.Table, col *schema.Column) []string {
+	checks := []string{}
+	if col.Type == "enum" && g.EnumType == nil {
+		checks = append(checks, fmt.Sprintf(
+			"%s IN (%s)",
+			g.Convert.SQLColumn(table.Name, col.Name),
+			quoteValues(col.Values),
+		))
+	}
+	if col.Check != "" {
+		checks = append(checks, col.Check)
+	}
+	return checks
+}
+
+// inlineComment is the COMMENT clause for databases that keep comments in
+// the column definition
+func (g *GenericDB) inlineComment(col *schema.Column) string {
+	if !g.InlineComments || col.Comment == "" {
+		return ""
+	}
+	return " COMMENT " + quoteValues([]string{col.Comment})
+}
+
+// commentColumns sets the comments on columns for databases that set them
+// with a statement of their own
+func (g *GenericDB) commentColumns(table *schema.Table, cols ...*schema.Column) error {
+	c, ok := g.Specific.(commenter)
+	if !ok {
+		return nil
+	}
+	for _, col := range cols {
+		if col.Comment == "" {
+			continue
+		}
+		if sql := c.columnComment(table, col); sql != "" {
+			err := g.exec(sql)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commenter is implemented by the Alterers for databases that keep column
+// comments. columnComment is the statement setting a comment, or nothing
+// when comments are part of the column definition.
+type commenter interface {
+	columnComment(table *schema.Table, col *schema.Column) string
+}
+
+// rebuilder is implemented by the Alterers for databases that can't alter
+// the constraints of a column, and instead copy the table into a new one
+type rebuilder interface {
+	rebuildTable(table *schema.Table) error
+}
+
+// setup sets the fields of the GenericDB for a specific database, it's
+// overridden by each of them
+func (*GenericDB) setup() {}
+
+func (g *GenericDB) dropTable(name string) error {
+	return g.exec("DROP TABLE " + name)
+}
+
+func (g *GenericDB) dropColumn(table, column string) error {
+	return g.exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
 }
 
 func (g *GenericDB) HasIndex(table *schema.Table, index schema.Index) (bool, error) {
@@ -26,6 +273,111 @@ func (g *GenericDB) CreateIndex(table *schema.Table, index schema.Index) error {
 	return g.Specific.CreateIndex(table, index)
 }
 
+// createIndexes creates the indexes of a table that aren't in the database
+func (g *GenericDB) createIndexes(table *schema.Table) error {
+	for _, index := range table.Index {
+		ok, err := g.Specific.HasIndex(table, index)
+		if err != nil {
+			return fmt.Errorf("Error checking index: %v", err)
+		}
+		if !ok {
+			err = g.Specific.CreateIndex(table, index)
+			if err != nil {
+				return fmt.Errorf("Error creating index %s: %v", g.indexName(table, index), err)
+			}
+		}
+	}
+	return nil
+}
+
+var indexNameChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// indexName is the name of an index, indexes without one are named after
+// the table and their columns
+func (g *GenericDB) indexName(table *schema.Table, index schema.Index) string {
+	if index.Name != "" {
+		return index.Name
+	}
+	name := strings.Join(append([]string{"idx", g.Convert.SQLTable(table.Name)}, index.Columns...), "_")
+	return strings.Trim(indexNameChars.ReplaceAllString(name, "_"), "_")
+}
+
+// indexColumns are the columns of an index as SQL, expressions are in
+// parentheses and descending columns are marked DESC
+func (g *GenericDB) indexColumns(table *schema.Table, index schema.Index) []string {
+	columns := make([]string, len(index.Columns))
+	for n, col := range index.Columns {
+		if index.Expression(n) {
+			columns[n] = "(" + g.sqlExpr(table, col) + ")"
+		} else {
+			columns[n] = g.Convert.SQLColumn(table.Name, col)
+		}
+		if n < len(index.Desc) && index.Desc[n] {
+			columns[n] += " DESC"
+		}
+	}
+	return columns
+}
+
+// indexSQL is the CREATE INDEX statement for an index, partial indexes
+// have their condition in a WHERE clause
+func (g *GenericDB) indexSQL(table *schema.Table, index schema.Index) string {
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	sql := fmt.Sprintf(
+		"CREATE %sINDEX %s ON %s (%s)",
+		unique,
+		g.indexName(table, index),
+		g.Convert.SQLTable(table.Name),
+		strings.Join(g.indexColumns(table, index), ", "),
+	)
+	if index.Where != "" {
+		sql += " WHERE " + g.sqlExpr(table, index.Where)
+	}
+	return sql
+}
+
+// sqlExpr replaces the column names in an SQL expression with the names
+// the columns have in the database, leaving strings and other words alone
+func (g *GenericDB) sqlExpr(table *schema.Table, expr string) string {
+	columns := map[string]bool{}
+	for _, col := range table.Columns {
+		columns[col.Name] = true
+	}
+
+	out := &bytes.Buffer{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				out.WriteString(expr[i:])
+				return out.String()
+			}
+			out.WriteString(expr[i : i+end+2])
+			i += end + 2
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(expr) && (expr[end] == '_' || unicode.IsLetter(rune(expr[end])) || unicode.IsDigit(rune(expr[end]))) {
+				end++
+			}
+			word := expr[i:end]
+			if columns[word] {
+				word = g.Convert.SQLColumn(table.Name, word)
+			}
+			out.WriteString(word)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
 func (*GenericDB) getIndexName(*schema.Table, schema.Index) (string, error) {
 	return "", fmt.Errorf("Must use RDBMS specific version for this feature")
 }
@@ -38,8 +390,7 @@ func (g *GenericDB) RemoveIndex(table *schema.Table, index schema.Index) error {
 	if name == "" {
 		return nil
 	}
-	_, err = g.DB.Exec("DROP INDEX " + name)
-	return err
+	return g.exec("DROP INDEX " + name)
 }
 
 func (g *GenericDB) HasTable(table *schema.Table) (bool, error) {
@@ -47,83 +398,137 @@ func (g *GenericDB) HasTable(table *schema.Table) (bool, error) {
 }
 
 func (g *GenericDB) CreateTable(table *schema.Table) error {
-	vals := []interface{}{}
-	sql := fmt.Sprintf(
-		"CREATE TABLE %s(",
-		g.Convert.SQLTable(table.Name),
-	)
+	sql, err := g.createTableSQL(table, g.Convert.SQLTable(table.Name))
+	if err != nil {
+		return err
+	}
+	err = g.exec(sql)
+	if err != nil {
+		return fmt.Errorf("Error Creating Table\nSQL: %s\nError: %s", sql, err.Error())
+	}
+	cols := make([]*schema.Column, len(table.Columns))
+	for i := range table.Columns {
+		cols[i] = &table.Columns[i]
+	}
+	err = g.commentColumns(table, cols...)
+	if err != nil {
+		return err
+	}
+	return g.createIndexes(table)
+}
+
+// createTableSQL is the CREATE TABLE statement for a table, created under
+// name
+func (g *GenericDB) createTableSQL(table *schema.Table, name string) (string, error) {
+	sql := fmt.Sprintf("CREATE TABLE %s(", name)
 
 	defs := []string{}
 	for i, column := range table.Columns {
 		switch {
-		case i == 0 && column.Type == "integer":
+		case i == 0 && integerColumns[column.Type]:
+			// unsigned keys take the wider type where there's no UNSIGNED
+			wide := column.Type == "bigint" || column.Unsigned && !g.UnsignedColumns && widerIntegers[column.Type] == "bigint"
+			def := g.PrimaryKeyDef
+			if wide && g.BigPrimaryKeyDef != "" {
+				def = g.BigPrimaryKeyDef
+			}
 			defs = append(
 				defs,
 				fmt.Sprintf(
-					g.PrimaryKeyDef,
+					def,
 					g.Convert.SQLColumn(table.Name, column.Name),
 				),
 			)
 		default:
-			ct := strings.ToUpper(column.Type)
-			if g.AlternateNames != nil && g.AlternateNames[ct] != "" {
-				ct = g.AlternateNames[ct]
-			}
-			coldef := fmt.Sprintf(
-				"%s %s",
-				g.Convert.SQLColumn(table.Name, column.Name),
-				ct,
-			)
-			if column.Length != 0 && g.LengthableColumns[column.Type] {
-				coldef += fmt.Sprintf(
-					"(%d)", column.Length,
-				)
+			coldef, err := g.columnDef(table, &column, false)
+			if err != nil {
+				return "", err
 			}
 			defs = append(defs, coldef)
 		}
 	}
-	for _, child := range table.ChildOf {
-		defs = append(
-			defs,
-			fmt.Sprintf(
-				"FOREIGN KEY(%s) REFERENCES %s(%s)",
-				g.Convert.SQLColumn(table.Name, child.ChildColumn.Name),
-				g.Convert.SQLTable(child.Parent.Name),
-				g.Convert.SQLColumn(child.Parent.Name, child.Parent.PrimaryKeyColumn().Name),
-			),
-		)
+	for _, fk := range g.foreignKeys(table) {
+		def, err := g.foreignKeyDef(fk)
+		if err != nil {
+			return "", err
+		}
+		defs = append(defs, def)
 	}
 
+	return sql + strings.Join(defs, ", ") + ")", nil
+}
+
+// foreignKeys are the foreign keys a table should have, one for each
+// relation it's the child of
+func (g *GenericDB) foreignKeys(table *schema.Table) []ForeignKey {
+	keys := []ForeignKey{}
+	add := func(parent *schema.Table, col *schema.Column, onDelete, onUpdate string, deferrable bool) {
+		keys = append(keys, ForeignKey{
+			Column:       g.Convert.SQLColumn(table.Name, col.Name),
+			ParentTable:  g.Convert.SQLTable(parent.Name),
+			ParentColumn: g.Convert.SQLColumn(parent.Name, parent.PrimaryKeyColumn().Name),
+			OnDelete:     onDelete,
+			OnUpdate:     onUpdate,
+			Deferrable:   deferrable,
+		})
+	}
+	for _, child := range table.ChildOf {
+		if child.TypeColumn != nil {
+			// polymorphic relations refer to more than one table
+			continue
+		}
+		add(child.Parent, child.ChildColumn, child.OnDelete, child.OnUpdate, child.Deferrable)
+	}
 	for _, belonging := range table.BelongsTo {
-		defs = append(
-			defs,
-			fmt.Sprintf(
-				"FOREIGN KEY(%s) REFERENCES %s(%s)",
-				g.Convert.SQLColumn(table.Name, belonging.ChildColumn.Name),
-				g.Convert.SQLTable(belonging.Parent.Name),
-				g.Convert.SQLColumn(belonging.Parent.Name, belonging.Parent.PrimaryKeyColumn().Name),
-			),
-		)
+		add(belonging.Parent, belonging.ChildColumn, belonging.OnDelete, belonging.OnUpdate, belonging.Deferrable)
 	}
+	return keys
+}
 
-	sql += strings.Join(defs, ", ") + ")"
-	fmt.Println(sql, vals)
-	_, err := g.DB.Exec(sql, vals...)
-	if err != nil {
-		return fmt.Errorf("Error Creating Table\nSQL: %s\nError: %s", sql, err.Error())
+// foreignKeyDef is the FOREIGN KEY constraint for a key, with its actions
+func (g *GenericDB) foreignKeyDef(fk ForeignKey) (string, error) {
+	def := fmt.Sprintf("FOREIGN KEY(%s) REFERENCES %s(%s)", fk.Column, fk.ParentTable, fk.ParentColumn)
+	for _, action := range [][2]string{{"ON DELETE", fk.OnDelete}, {"ON UPDATE", fk.OnUpdate}} {
+		if action[1] == "" {
+			continue
+		}
+		sql := action[1]
+		if replaced, ok := g.KeyActions[sql]; ok {
+			if replaced == "" {
+				return "", fmt.Errorf("%s %s isn't supported by this database, on %s", action[0], sql, fk.Column)
+			}
+			sql = replaced
+		}
+		def += " " + action[0] + " " + sql
+	}
+	if fk.Deferrable {
+		if !g.DeferrableKeys {
+			return "", fmt.Errorf("Deferrable foreign keys aren't supported by this database, on %s", fk.Column)
+		}
+		def += " DEFERRABLE INITIALLY DEFERRED"
 	}
+	return def, nil
+}
 
-	fmt.Println("Created Table")
-	for _, index := range table.Index {
-		ok, err := g.HasIndex(table, index)
-		if err != nil {
-			return fmt.Errorf("Error checking index: %v", err)
+// addForeignKey adds a foreign key to an existing table, dropping the key
+// it replaces first
+func (g *GenericDB) addForeignKey(table *schema.Table, fk ForeignKey) error {
+	def, err := g.foreignKeyDef(fk)
+	if err != nil {
+		return err
+	}
+	name := g.Convert.SQLTable(table.Name)
+	if fk.Name != "" {
+		drop := g.DropForeignKey
+		if drop == "" {
+			drop = "DROP CONSTRAINT %s"
 		}
-		if !ok {
-			g.CreateIndex(table, index)
+		err = g.exec(fmt.Sprintf("ALTER TABLE %s "+drop, name, fk.Name))
+		if err != nil {
+			return err
 		}
 	}
-	return nil
+	return g.exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT fk_%s_%s %s", name, name, fk.Column, def))
 }
 
 func (g *GenericDB) UpdateTable(table *schema.Table) error {
@@ -143,29 +548,44 @@ func (g *GenericDB) UpdateTable(table *schema.Table) error {
 		}
 	}
 
-	for _, index := range table.Index {
-		ok, err := g.HasIndex(table, index)
+	changed, err := g.changedColumns(table)
+	if err != nil {
+		return err
+	}
+	keys, err := g.changedForeignKeys(table)
+	if err != nil {
+		return err
+	}
+	if r, ok := g.Specific.(rebuilder); ok && len(changed)+len(keys) > 0 {
+		g.Log.Println("Rebuilding", table.Name, "to change column constraints and foreign keys")
+		return r.rebuildTable(table)
+	}
+	for _, col := range changed {
+		g.Log.Println("Changing constraints of column", col.Name, "on", table.Name)
+		err = g.Specific.ModifyColumn(table, col)
 		if err != nil {
-			return fmt.Errorf("Error checking index: %v", err)
+			return err
 		}
-		if !ok {
-			g.CreateIndex(table, index)
+	}
+	for _, fk := range keys {
+		g.Log.Println("Adding foreign key on", fk.Column, "to", table.Name)
+		err = g.addForeignKey(table, fk)
+		if err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return g.createIndexes(table)
 }
 
 func (g *GenericDB) RemoveTable(table *schema.Table) error {
-	_, err := g.DB.Exec(fmt.Sprint("DROP TABLE ", g.Convert.SQLTable(table.Name)))
-	return err
+	return g.dropTable(g.Convert.SQLTable(table.Name))
 }
 
 func (g *GenericDB) RenameTable(table *schema.Table, oldName string) error {
-	_, err := g.DB.Exec(
+	return g.exec(
 		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", oldName, g.Convert.SQLTable(table.Name)),
 	)
-	return err
 }
 
 func (g *GenericDB) HasColumn(table *schema.Table, col *schema.Column) (bool, error) {
@@ -173,21 +593,16 @@ func (g *GenericDB) HasColumn(table *schema.Table, col *schema.Column) (bool, er
 }
 
 func (g *GenericDB) CreateColumn(table *schema.Table, col *schema.Column) error {
-	coldef := fmt.Sprintf(
-		"%s %s",
-		g.Convert.SQLColumn(table.Name, col.Name),
-		strings.ToUpper(col.Type),
-	)
-	if col.Length != 0 && g.LengthableColumns[col.Type] {
-		coldef += fmt.Sprintf(
-			"(%d)",
-			col.Length,
-		)
+	coldef, err := g.columnDef(table, col, true)
+	if err != nil {
+		return err
 	}
 	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", g.Convert.SQLTable(table.Name), coldef)
-	_, err := g.DB.Exec(sql)
-
-	return err
+	err = g.exec(sql)
+	if err != nil {
+		return err
+	}
+	return g.commentColumns(table, col)
 }
 
 func (g *GenericDB) RenameColumn(table *schema.Table, col *schema.Column) error {
@@ -195,7 +610,7 @@ func (g *GenericDB) RenameColumn(table *schema.Table, col *schema.Column) error
 }
 
 func (g *GenericDB) RemoveColumn(table *schema.Table, col *schema.Column) error {
-	return nil
+	return g.dropColumn(g.Convert.SQLTable(table.Name), g.Convert.SQLColumn(table.Name, col.Name))
 }
 
 func (g *GenericDB) ModifyColumn(table *schema.Table, col *schema.Column) error {
@@ -207,20 +622,109 @@ type SqliteDB struct {
 	GenericDB
 }
 
-func (s *SqliteDB) CreateTable(table *schema.Table) error {
+func (s *SqliteDB) setup() {
 	s.GenericDB.Specific = s
+	s.GenericDB.AlternateNames = s.AlternateNames()
 	s.GenericDB.PrimaryKeyDef = "%s INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT"
+	s.GenericDB.DeferrableKeys = true
 	s.GenericDB.LengthableColumns = s.LengthableColumns()
+}
+
+func (s *SqliteDB) CreateTable(table *schema.Table) error {
+	s.setup()
 	return s.GenericDB.CreateTable(table)
 }
 
+// rebuildTable copies a table into a new one with the current constraints,
+// as SQLite's ALTER TABLE can only add, rename and drop columns. The copy
+// runs in a transaction so a row the new constraints refuse leaves the table
+// as it was, foreign keys are switched off around it as SQLite only allows
+// that outside of a transaction.
+func (s *SqliteDB) rebuildTable(table *schema.Table) error {
+	name := s.Convert.SQLTable(table.Name)
+	temp := name + "_rebuild"
+	create, err := s.createTableSQL(table, temp)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		columns[i] = s.Convert.SQLColumn(table.Name, col.Name)
+	}
+	list := strings.Join(columns, ", ")
+
+	statements := []string{
+		create,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", temp, list, list, name),
+		"DROP TABLE " + name,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", temp, name),
+	}
+	for _, index := range table.Index {
+		statements = append(statements, s.indexSQL(table, index))
+	}
+	if s.Planned != nil {
+		for _, sql := range statements {
+			s.exec(sql)
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var keys bool
+	err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&keys)
+	if err != nil {
+		return err
+	}
+	if keys {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, sql := range statements {
+		if s.Log != nil {
+			s.Log.Println(sql)
+		}
+		_, err = tx.Exec(sql)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *SqliteDB) UpdateTable(table *schema.Table) error {
-	s.GenericDB.Specific = s
-	s.GenericDB.PrimaryKeyDef = "%s INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT"
-	s.GenericDB.LengthableColumns = s.LengthableColumns()
+	s.setup()
 	return s.GenericDB.UpdateTable(table)
 }
 
+// CreateColumn adds a unique column without its UNIQUE constraint, which
+// SQLite's ALTER TABLE refuses, and enforces it with a unique index instead
+func (s *SqliteDB) CreateColumn(table *schema.Table, col *schema.Column) error {
+	if !col.Unique {
+		return s.GenericDB.CreateColumn(table, col)
+	}
+	plain := *col
+	plain.Unique = false
+	err := s.GenericDB.CreateColumn(table, &plain)
+	if err != nil {
+		return err
+	}
+	return s.CreateIndex(table, schema.Index{Columns: []string{col.Name}, Unique: true})
+}
+
 func (s *SqliteDB) HasTable(table *schema.Table) (bool, error) {
 	var cnt int64
 	err := s.DB.QueryRow(
@@ -234,37 +738,51 @@ func (s *SqliteDB) HasTable(table *schema.Table) (bool, error) {
 }
 
 func (s *SqliteDB) HasColumn(table *schema.Table, col *schema.Column) (bool, error) {
-	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", s.Convert.SQLTable(table.Name)))
+	cols, err := s.tableInfo(s.Convert.SQLTable(table.Name))
 	if err != nil {
 		return false, err
 	}
-	var tinfo struct {
-		CID        int
-		Name       string
-		Type       string
-		NotNull    bool
-		Default    interface{}
-		PrimaryKey bool
+	for _, tinfo := range cols {
+		if tinfo.Name == s.Convert.SQLColumn(table.Name, col.Name) {
+			return true, nil
+		}
 	}
-	for rows.Next() {
-		err = rows.Scan(
-			&tinfo.CID,
-			&tinfo.Name,
-			&tinfo.Type,
-			&tinfo.NotNull,
-			&tinfo.Default,
+	return false, nil
+}
+
+type sqliteColumn struct {
+	CID        int
+	Name       string
+	Type       string
+	NotNull    bool
+	Default    interface{}
+	PrimaryKey int
+}
+
+func (s *SqliteDB) tableInfo(table string) ([]sqliteColumn, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []sqliteColumn{}
+	for rows.Next() {
+		var tinfo sqliteColumn
+		err = rows.Scan(
+			&tinfo.CID,
+			&tinfo.Name,
+			&tinfo.Type,
+			&tinfo.NotNull,
+			&tinfo.Default,
 			&tinfo.PrimaryKey,
 		)
 		if err != nil {
-			rows.Close()
-			return false, err
-		}
-		if tinfo.Name == s.Convert.SQLColumn(table.Name, col.Name) {
-			rows.Close()
-			return true, nil
+			return nil, err
 		}
+		cols = append(cols, tinfo)
 	}
-	return false, rows.Err()
+	return cols, rows.Err()
 }
 
 func (s *SqliteDB) HasIndex(table *schema.Table, index schema.Index) (bool, error) {
@@ -279,84 +797,189 @@ func (s *SqliteDB) HasIndex(table *schema.Table, index schema.Index) (bool, erro
 }
 
 func (s *SqliteDB) getIndexName(table *schema.Table, index schema.Index) (string, error) {
-	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA index_list(%s)", s.Convert.SQLTable(table.Name)))
+	indexes, err := s.indexList(s.Convert.SQLTable(table.Name))
 	if err != nil {
 		return "", err
 	}
-	indexes := []string{}
-	var temp1 interface{}
-	var indexName string
-	var unique bool
+	return s.findIndex(indexes, table, index), nil
+}
+
+// indexList reads PRAGMA index_list, which has grown extra columns in
+// newer versions of sqlite, so only the leading name and unique columns
+// are relied upon.
+func (s *SqliteDB) indexList(table string) ([]namedIndex, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA index_list(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	names, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	indexes := []namedIndex{}
 	for rows.Next() {
-		err = rows.Scan(&temp1, &indexName, &unique)
-		if err != nil {
-			return "", fmt.Errorf("Enumerate Sqlite indexes: %v", err)
+		var index namedIndex
+		dest := make([]interface{}, len(names))
+		dest[0] = new(interface{})
+		dest[1] = &index.Name
+		dest[2] = &index.Unique
+		for i := 3; i < len(dest); i++ {
+			dest[i] = new(interface{})
 		}
-		if index.Unique == unique {
-			indexes = append(indexes, indexName)
+		err = rows.Scan(dest...)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("Enumerate Sqlite indexes: %v", err)
 		}
+		indexes = append(indexes, index)
 	}
 	rows.Close()
 
-	for _, dbindex := range indexes {
-		rows, err := s.DB.Query(fmt.Sprintf("PRAGMA index_info(%s)", dbindex))
+	for i, index := range indexes {
+		rows, err := s.DB.Query(fmt.Sprintf("PRAGMA index_info(%s)", index.Name))
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		columns := []string{}
 		var temp1, temp2 interface{}
-		var columnName string
+		// expressions have no column name
+		var columnName sql.NullString
 		for rows.Next() {
 			err = rows.Scan(&temp1, &temp2, &columnName)
 			if err != nil {
-				return "", fmt.Errorf("Interrogate Sqlite index: %v", err)
+				rows.Close()
+				return nil, fmt.Errorf("Interrogate Sqlite index: %v", err)
 			}
-			columns = append(columns, columnName)
+			indexes[i].Columns = append(indexes[i].Columns, columnName.String)
 		}
 		rows.Close()
-		if len(columns) != len(index.Columns) {
-			continue
-		}
-		for i, column := range columns {
-			if column != s.Convert.SQLColumn(table.Name, index.Columns[i]) {
-				continue
-			}
+	}
+
+	return indexes, nil
+}
+
+func (s *SqliteDB) ListTables() ([]string, error) {
+	return queryStrings(
+		s.DB,
+		`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+	)
+}
+
+func (s *SqliteDB) TableColumns(table string) ([]IntrospectedColumn, error) {
+	cols, err := s.tableInfo(table)
+	if err != nil {
+		return nil, err
+	}
+	create, err := queryStrings(s.DB, `SELECT sql FROM sqlite_master WHERE type='table' AND name=$1`, table)
+	if err != nil {
+		return nil, err
+	}
+	checks := map[string]string{}
+	if len(create) > 0 {
+		checks = sqliteChecks(create[0])
+	}
+	// UNIQUE columns are backed by automatic indexes
+	indexes, err := s.indexList(table)
+	if err != nil {
+		return nil, err
+	}
+	unique := map[string]bool{}
+	for _, index := range indexes {
+		if strings.HasPrefix(index.Name, "sqlite_autoindex_") && index.Unique && len(index.Columns) == 1 {
+			unique[index.Columns[0]] = true
 		}
-		return dbindex, nil
 	}
 
-	return "", nil
+	results := make([]IntrospectedColumn, len(cols))
+	for i, tinfo := range cols {
+		results[i].Name = tinfo.Name
+		results[i].Type, results[i].Length = NormalizeType(tinfo.Type)
+		if results[i].Type == "decimal" {
+			results[i].Precision, results[i].Scale = decimalSize(tinfo.Type)
+		}
+		results[i].PrimaryKey = tinfo.PrimaryKey > 0
+		results[i].Null = !tinfo.NotNull && !results[i].PrimaryKey
+		if tinfo.Default != nil {
+			results[i].Default = fmt.Sprintf("%s", tinfo.Default)
+		}
+		results[i].Check = checks[tinfo.Name]
+		results[i].Unique = unique[tinfo.Name]
+	}
+	return results, nil
 }
 
-func (s *SqliteDB) CreateIndex(table *schema.Table, index schema.Index) error {
-	unique := ""
-	if index.Unique {
-		unique = "UNIQUE"
+func (s *SqliteDB) TableIndexes(table string) ([]schema.Index, error) {
+	indexes, err := s.indexList(table)
+	if err != nil {
+		return nil, err
+	}
+	results := []schema.Index{}
+	for _, index := range indexes {
+		// skip the automatic indexes backing primary keys and UNIQUE
+		// constraints, and expression indexes as SQLite doesn't report
+		// their expressions
+		if strings.HasPrefix(index.Name, "sqlite_autoindex_") || index.hasExpression() {
+			continue
+		}
+		results = append(results, schema.Index{Columns: index.Columns, Unique: index.Unique})
 	}
+	return results, nil
+}
 
-	indexName := strings.Join(append([]string{"idx", s.Convert.SQLTable(table.Name)}, index.Columns...), "_")
+func (s *SqliteDB) TableForeignKeys(table string) ([]ForeignKey, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	columns := make([]string, len(index.Columns))
-	for i, col := range index.Columns {
-		columns[i] = s.Convert.SQLColumn(table.Name, col)
+	keys := []ForeignKey{}
+	for rows.Next() {
+		var fk ForeignKey
+		var id, seq, match interface{}
+		var to *string
+		err = rows.Scan(&id, &seq, &fk.ParentTable, &fk.Column, &to, &fk.OnUpdate, &fk.OnDelete, &match)
+		if err != nil {
+			return nil, err
+		}
+		if to != nil {
+			fk.ParentColumn = *to
+		}
+		keys = append(keys, fk)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
 	}
 
-	sql := fmt.Sprintf(
-		"CREATE %s INDEX %s ON %s (%s)",
-		unique,
-		indexName,
-		s.Convert.SQLTable(table.Name),
-		strings.Join(columns, ", "),
-	)
-	_, err := s.DB.Exec(sql)
+	// PRAGMA foreign_key_list leaves out whether keys are deferred
+	create, err := queryStrings(s.DB, `SELECT sql FROM sqlite_master WHERE type='table' AND name=$1`, table)
+	if err != nil || len(create) == 0 {
+		return keys, err
+	}
+	deferred := sqliteDeferred(create[0])
+	for i := range keys {
+		keys[i].Deferrable = deferred[keys[i].Column]
+	}
+	return keys, nil
+}
 
-	return err
+func (s *SqliteDB) CreateIndex(table *schema.Table, index schema.Index) error {
+	return s.exec(s.indexSQL(table, index))
 }
 
 func (*SqliteDB) String() string {
 	return "sqlite"
 }
 
+// AlternateNames stores JSON as TEXT, SQLite would give a JSON column
+// numeric affinity
+func (*SqliteDB) AlternateNames() map[string]string {
+	return map[string]string{
+		"JSON": "TEXT",
+	}
+}
+
 func (*SqliteDB) LengthableColumns() map[string]bool {
 	return map[string]bool{
 		"varchar": true,
@@ -398,21 +1021,52 @@ func (p *PostgresDB) HasColumn(table *schema.Table, col *schema.Column) (bool, e
 	return cnt == 1, nil
 }
 
-func (p *PostgresDB) CreateTable(table *schema.Table) error {
-	p.GenericDB.Specific = p
-	p.GenericDB.AlternateNames = map[string]string{
+func (*PostgresDB) AlternateNames() map[string]string {
+	return map[string]string{
 		"BLOB":      "BYTEA",
 		"TIMESTAMP": "TIMESTAMP WITH TIME ZONE",
+		"JSON":      "JSONB",
+	}
+}
+
+// enumType creates the type for an enum the first time it's used, as
+// Postgres enums are types of their own
+func (p *PostgresDB) enumType(col *schema.Column) (string, error) {
+	name := strings.ToLower(col.Enum)
+	create := fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", name, quoteValues(col.Values))
+	if p.Planned != nil {
+		for _, planned := range *p.Planned {
+			if planned == create {
+				return name, nil
+			}
+		}
+	}
+
+	var cnt int64
+	err := p.DB.QueryRow("SELECT COUNT(*) FROM pg_type WHERE typname = $1", name).Scan(&cnt)
+	if err != nil || cnt > 0 {
+		return name, err
 	}
+	return name, p.exec(create)
+}
+
+func (p *PostgresDB) setup() {
+	p.GenericDB.Specific = p
+	p.GenericDB.AlternateNames = p.AlternateNames()
+	p.GenericDB.EnumType = p.enumType
 	p.GenericDB.PrimaryKeyDef = "%s SERIAL PRIMARY KEY"
+	p.GenericDB.BigPrimaryKeyDef = "%s BIGSERIAL PRIMARY KEY"
+	p.GenericDB.DeferrableKeys = true
 	p.GenericDB.LengthableColumns = p.LengthableColumns()
+}
+
+func (p *PostgresDB) CreateTable(table *schema.Table) error {
+	p.setup()
 	return p.GenericDB.CreateTable(table)
 }
 
 func (p *PostgresDB) UpdateTable(table *schema.Table) error {
-	p.GenericDB.Specific = p
-	p.GenericDB.PrimaryKeyDef = "%s SERIAL PRIMARY KEY"
-	p.GenericDB.LengthableColumns = p.LengthableColumns()
+	p.setup()
 	return p.GenericDB.UpdateTable(table)
 }
 
@@ -421,51 +1075,168 @@ func (p *PostgresDB) HasIndex(table *schema.Table, index schema.Index) (bool, er
 	return name != "", err
 }
 func (p *PostgresDB) getIndexName(table *schema.Table, index schema.Index) (string, error) {
-	sql := `select i.relname as index_name, array_to_string(array_agg(a.attname), ',') as column_names
-from pg_class t, pg_class i, pg_index ix, pg_attribute a
-where t.oid = ix.indrelid and i.oid = ix.indexrelid
-and a.attrelid = t.oid and a.attnum = ANY(ix.indkey)
-and t.relkind = 'r' and t.relname like $1
-group by t.relname, i.relname
-order by t.relname, i.relname`
-	rows, err := p.DB.Query(sql, p.Convert.SQLTable(table.Name))
+	indexes, err := p.indexList(p.Convert.SQLTable(table.Name))
 	if err != nil {
 		return "", err
 	}
-	lookColumns := strings.Join(index.Columns, ",")
-	var indexName, columns string
+	return p.findIndex(indexes, table, index), nil
+}
+
+// indexList reads the indexes of a table, pg_get_indexdef gives the name of
+// each column or the expression in its place
+func (p *PostgresDB) indexList(table string) ([]namedIndex, error) {
+	sql := `SELECT i.relname, ix.indisunique, pg_get_indexdef(ix.indexrelid, k.n, true)
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+CROSS JOIN LATERAL generate_series(1, ix.indnatts) AS k(n)
+WHERE t.relkind = 'r' AND t.relname = $1 AND NOT ix.indisprimary
+ORDER BY i.relname, k.n`
+	rows, err := p.DB.Query(sql, table)
+	if err != nil {
+		return nil, err
+	}
+	return groupIndexColumns(rows)
+}
+
+func (p *PostgresDB) ListTables() ([]string, error) {
+	return queryStrings(
+		p.DB,
+		`SELECT table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema = 'public' ORDER BY table_name`,
+	)
+}
+
+func (p *PostgresDB) TableColumns(table string) ([]IntrospectedColumn, error) {
+	rows, err := p.DB.Query(
+		`SELECT c.column_name, c.data_type, COALESCE(c.character_maximum_length, 0),
+COALESCE(c.numeric_precision, 0), COALESCE(c.numeric_scale, 0),
+EXISTS (
+	SELECT 1 FROM information_schema.table_constraints tc
+	JOIN information_schema.key_column_usage kcu ON kcu.constraint_name = tc.constraint_name AND kcu.table_name = tc.table_name
+	WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_name = c.table_name AND kcu.column_name = c.column_name
+),
+c.is_nullable = 'YES', COALESCE(c.column_default, ''),
+COALESCE(col_description(to_regclass(quote_ident(c.table_name)), a.attnum), ''),
+COALESCE((
+	SELECT string_agg(regexp_replace(pg_get_constraintdef(con.oid), '^CHECK ', ''), ' AND ')
+	FROM pg_constraint con
+	WHERE con.contype = 'c' AND con.conrelid = a.attrelid AND con.conkey = ARRAY[a.attnum]
+), '')
+FROM information_schema.columns c
+JOIN pg_attribute a ON a.attrelid = to_regclass(quote_ident(c.table_name)) AND a.attname = c.column_name
+WHERE c.table_schema = 'public' AND c.table_name = $1 ORDER BY c.ordinal_position`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []IntrospectedColumn{}
 	for rows.Next() {
-		err = rows.Scan(&indexName, &columns)
+		var col IntrospectedColumn
+		var dataType string
+		var length, precision, scale int
+		err = rows.Scan(
+			&col.Name, &dataType, &length, &precision, &scale, &col.PrimaryKey, &col.Null,
+			&col.Default, &col.Comment, &col.Check,
+		)
 		if err != nil {
-			rows.Close()
-			return "", err
+			return nil, err
 		}
-		if columns == lookColumns {
-			rows.Close()
-			return indexName, nil
+		col.Type, col.Length = NormalizeType(dataType)
+		if col.Type == "decimal" {
+			col.Precision, col.Scale = precision, scale
+		}
+		if length != 0 {
+			col.Length = length
 		}
+		cols = append(cols, col)
 	}
-	rows.Close()
-	return "", nil
+	return cols, rows.Err()
 }
-func (p *PostgresDB) CreateIndex(table *schema.Table, index schema.Index) error {
-	indexName := strings.Join(append([]string{"idx", p.Convert.SQLTable(table.Name)}, index.Columns...), "_")
 
-	columns := make([]string, len(index.Columns))
-	for i, col := range index.Columns {
-		columns[i] = p.Convert.SQLColumn(table.Name, col)
+func (p *PostgresDB) TableIndexes(table string) ([]schema.Index, error) {
+	indexes, err := p.indexList(table)
+	if err != nil {
+		return nil, err
+	}
+	return schemaIndexes(indexes), nil
+}
+
+func (p *PostgresDB) TableForeignKeys(table string) ([]ForeignKey, error) {
+	rows, err := p.DB.Query(
+		`SELECT kcu.column_name, ccu.table_name, ccu.column_name,
+tc.constraint_name, rc.delete_rule, rc.update_rule, tc.is_deferrable = 'YES'
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema
+JOIN information_schema.referential_constraints rc ON rc.constraint_name = tc.constraint_name AND rc.constraint_schema = tc.table_schema
+WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1`,
+		table,
+	)
+	if err != nil {
+		return nil, err
 	}
+	return scanForeignKeys(rows)
+}
 
-	sql := fmt.Sprintf(
-		"CREATE INDEX %s ON %s (%s)",
-		indexName,
+func (p *PostgresDB) CreateIndex(table *schema.Table, index schema.Index) error {
+	return p.exec(p.indexSQL(table, index))
+}
+func (p *PostgresDB) columnComment(table *schema.Table, col *schema.Column) string {
+	comment := "NULL"
+	if col.Comment != "" {
+		comment = quoteValues([]string{col.Comment})
+	}
+	return fmt.Sprintf(
+		"COMMENT ON COLUMN %s.%s IS %s",
 		p.Convert.SQLTable(table.Name),
-		strings.Join(columns, ", "),
+		p.Convert.SQLColumn(table.Name, col.Name),
+		comment,
 	)
-	_, err := p.DB.Exec(sql)
+}
 
-	return err
+// ModifyColumn changes the constraints of a column in place. Postgres names
+// the constraints in a column definition <table>_<column>_key for UNIQUE
+// and <table>_<column>_check for CHECK, so they're replaced by name.
+func (p *PostgresDB) ModifyColumn(table *schema.Table, col *schema.Column) error {
+	name, column := p.Convert.SQLTable(table.Name), p.Convert.SQLColumn(table.Name, col.Name)
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", name, column)
+	sqls := []string{}
+	if col.Null {
+		sqls = append(sqls, alter+"DROP NOT NULL")
+	} else {
+		sqls = append(sqls, alter+"SET NOT NULL")
+	}
+	if col.Default != "" {
+		sqls = append(sqls, alter+"SET DEFAULT "+col.Default)
+	} else {
+		sqls = append(sqls, alter+"DROP DEFAULT")
+	}
+
+	constraint := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s_%s_", name, name, column)
+	sqls = append(sqls, constraint+"key", constraint+"check")
+	if col.Unique {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s_%s_key UNIQUE (%s)", name, name, column, column))
+	}
+	if checks := p.columnChecks(table, col); len(checks) > 0 {
+		sqls = append(sqls, fmt.Sprintf(
+			"ALTER TABLE %s ADD CONSTRAINT %s_%s_check CHECK (%s)",
+			name, name, column, strings.Join(checks, " AND "),
+		))
+	}
+	sqls = append(sqls, p.columnComment(table, col))
+
+	for _, sql := range sqls {
+		err := p.exec(sql)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
+
 func (p *PostgresDB) LengthableColumns() map[string]bool {
 	return map[string]bool{
 		"varchar": true,
@@ -488,42 +1259,537 @@ func (*MysqlDB) LengthableColumns() map[string]bool {
 	}
 }
 
-func (m *MysqlDB) CreateTable(table *schema.Table) error {
+// ZeroDefaults leaves out text and json, as MySQL doesn't allow literal
+// defaults on TEXT or JSON columns
+func (*MysqlDB) ZeroDefaults() map[string]string {
+	zeros := map[string]string{}
+	for t, zero := range zeroDefaults {
+		if t != "text" && t != "json" && t != "blob" {
+			zeros[t] = zero
+		}
+	}
+	return zeros
+}
+
+func (*MysqlDB) enumType(col *schema.Column) (string, error) {
+	return "ENUM(" + quoteValues(col.Values) + ")", nil
+}
+
+func (m *MysqlDB) setup() {
 	m.GenericDB.Specific = m
+	m.GenericDB.UnsignedColumns = true
+	m.GenericDB.InlineComments = true
+	m.GenericDB.EnumType = m.enumType
 	m.GenericDB.PrimaryKeyDef = "%s SERIAL PRIMARY KEY"
+	m.GenericDB.KeyActions = map[string]string{"SET DEFAULT": ""}
+	m.GenericDB.DropForeignKey = "DROP FOREIGN KEY %s"
 	m.GenericDB.LengthableColumns = m.LengthableColumns()
+	m.GenericDB.ZeroDefaults = m.ZeroDefaults()
+}
+
+func (m *MysqlDB) CreateTable(table *schema.Table) error {
+	m.setup()
 	return m.GenericDB.CreateTable(table)
 }
 
 func (m *MysqlDB) UpdateTable(table *schema.Table) error {
-	m.GenericDB.Specific = m
-	m.GenericDB.PrimaryKeyDef = "%s SERIAL PRIMARY KEY"
-	m.GenericDB.LengthableColumns = m.LengthableColumns()
+	m.setup()
 	return m.GenericDB.UpdateTable(table)
 }
 
 func (m *MysqlDB) getIndexName(table *schema.Table, index schema.Index) (string, error) {
-	sql := `SELECT INDEX_NAME, GROUP_CONCAT(DISTINCT COLUMN_NAME ORDER BY SEQ_IN_INDEX) FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY' GROUP BY INDEX_NAME`
-	rows, err := m.DB.Query(sql, m.Convert.SQLTable(table.Name))
+	indexes, err := m.indexList(m.Convert.SQLTable(table.Name))
 	if err != nil {
 		return "", err
 	}
-	search := strings.Join(index.Columns, ",")
-	var indexName, columns string
+	return m.findIndex(indexes, table, index), nil
+}
+
+func (m *MysqlDB) HasIndex(table *schema.Table, index schema.Index) (bool, error) {
+	name, err := m.getIndexName(table, index)
+	return name != "", err
+}
+
+// indexList reads the indexes of a table, functional key parts have their
+// expression in place of a column name
+func (m *MysqlDB) indexList(table string) ([]namedIndex, error) {
+	sql := `SELECT INDEX_NAME, NON_UNIQUE = 0, COALESCE(COLUMN_NAME, EXPRESSION) FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX`
+	rows, err := m.DB.Query(sql, table)
+	if err != nil {
+		return nil, err
+	}
+	return groupIndexColumns(rows)
+}
+
+func (m *MysqlDB) ListTables() ([]string, error) {
+	return queryStrings(
+		m.DB,
+		`SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME`,
+	)
+}
+
+func (m *MysqlDB) TableColumns(table string) ([]IntrospectedColumn, error) {
+	rows, err := m.DB.Query(
+		`SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY = 'PRI', IS_NULLABLE = 'YES', COALESCE(COLUMN_DEFAULT, ''), COLUMN_COMMENT FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	checks, err := m.checkList(table)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := []IntrospectedColumn{}
 	for rows.Next() {
-		err = rows.Scan(&indexName, &columns)
+		var col IntrospectedColumn
+		var columnType string
+		err = rows.Scan(&col.Name, &columnType, &col.PrimaryKey, &col.Null, &col.Default, &col.Comment)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		if columns == search {
-			rows.Close()
-			return indexName, nil
+		clauses := []string{}
+		for _, check := range checks {
+			if check.mentions(col.Name) {
+				clauses = append(clauses, check.Clause)
+			}
 		}
+		col.Check = strings.Join(clauses, " AND ")
+		col.Type, col.Length = NormalizeType(columnType)
+		col.Unsigned = strings.HasSuffix(strings.ToLower(columnType), " unsigned")
+		if col.Type == "decimal" {
+			col.Precision, col.Scale = decimalSize(columnType)
+		}
+		cols = append(cols, col)
 	}
-	rows.Close()
-	return "", nil
+	return cols, rows.Err()
+}
+
+// mysqlCheck is a CHECK constraint, MySQL keeps them on the table rather
+// than the column so a check belongs to each column it mentions
+type mysqlCheck struct {
+	Name, Clause string
+}
+
+func (c mysqlCheck) mentions(column string) bool {
+	return strings.Contains(c.Clause, "`"+column+"`")
+}
+
+func (m *MysqlDB) checkList(table string) ([]mysqlCheck, error) {
+	rows, err := m.DB.Query(
+		`SELECT cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE FROM INFORMATION_SCHEMA.CHECK_CONSTRAINTS cc
+JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc ON tc.CONSTRAINT_SCHEMA = cc.CONSTRAINT_SCHEMA AND tc.CONSTRAINT_NAME = cc.CONSTRAINT_NAME
+WHERE tc.TABLE_SCHEMA = DATABASE() AND tc.TABLE_NAME = ? AND tc.CONSTRAINT_TYPE = 'CHECK' ORDER BY cc.CONSTRAINT_NAME`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []mysqlCheck{}
+	for rows.Next() {
+		var check mysqlCheck
+		err = rows.Scan(&check.Name, &check.Clause)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// columnComment is empty as comments are part of MySQL column definitions
+func (*MysqlDB) columnComment(*schema.Table, *schema.Column) string {
+	return ""
+}
+
+// ModifyColumn redefines a column with MODIFY COLUMN, which sets its type,
+// nullability, default and comment. UNIQUE and CHECK constraints are kept
+// apart from the column, so they're dropped and added on their own.
+func (m *MysqlDB) ModifyColumn(table *schema.Table, col *schema.Column) error {
+	name, column := m.Convert.SQLTable(table.Name), m.Convert.SQLColumn(table.Name, col.Name)
+	coldef, err := m.columnType(table, col)
+	if err != nil {
+		return err
+	}
+	sqls := []string{fmt.Sprintf(
+		"ALTER TABLE %s MODIFY COLUMN %s%s%s",
+		name, coldef, nullDef(col, false, m.GenericDB.ZeroDefaults), m.inlineComment(col),
+	)}
+
+	index, err := m.getIndexName(table, schema.Index{Columns: []string{col.Name}, Unique: true})
+	if err != nil {
+		return err
+	}
+	if index != "" && !uniqueColumn(table, col) {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", name, index))
+	}
+	if index == "" && col.Unique {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s)", name, column))
+	}
+
+	checks, err := m.checkList(name)
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		if check.mentions(column) {
+			sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s DROP CHECK %s", name, check.Name))
+		}
+	}
+	for _, check := range m.columnChecks(table, col) {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD CHECK (%s)", name, check))
+	}
+
+	for _, sql := range sqls {
+		err = m.exec(sql)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+func (m *MysqlDB) TableIndexes(table string) ([]schema.Index, error) {
+	indexes, err := m.indexList(table)
+	if err != nil {
+		return nil, err
+	}
+	return schemaIndexes(indexes), nil
+}
+
+func (m *MysqlDB) TableForeignKeys(table string) ([]ForeignKey, error) {
+	rows, err := m.DB.Query(
+		`SELECT kcu.COLUMN_NAME, kcu.REFERENCED_TABLE_NAME, kcu.REFERENCED_COLUMN_NAME,
+kcu.CONSTRAINT_NAME, rc.DELETE_RULE, rc.UPDATE_RULE, FALSE
+FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
+JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS rc ON rc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA AND rc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME
+WHERE kcu.TABLE_SCHEMA = DATABASE() AND kcu.TABLE_NAME = ? AND kcu.REFERENCED_TABLE_NAME IS NOT NULL`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(rows)
+}
+
+// CreateIndex creates an index, MySQL has no partial indexes
 func (m *MysqlDB) CreateIndex(table *schema.Table, index schema.Index) error {
+	if index.Where != "" {
+		return fmt.Errorf("MySQL doesn't support partial indexes, %s has a where condition", m.indexName(table, index))
+	}
+	return m.exec(m.indexSQL(table, index))
+}
+
+// MssqlDB speaks T-SQL, which has IDENTITY columns instead of sequences
+// and needs the sys catalog views to find indexes
+type MssqlDB struct {
+	GenericDB
+}
+
+func (*MssqlDB) String() string {
+	return "mssql"
+}
+
+func (*MssqlDB) LengthableColumns() map[string]bool {
+	return map[string]bool{
+		"varchar": true,
+	}
+}
+
+func (*MssqlDB) AlternateNames() map[string]string {
+	return map[string]string{
+		"TEXT":             "NVARCHAR(MAX)",
+		"VARCHAR":          "NVARCHAR",
+		"BLOB":             "VARBINARY(MAX)",
+		"BOOLEAN":          "BIT",
+		"BOOL":             "BIT",
+		"TIMESTAMP":        "DATETIME2",
+		"DOUBLE PRECISION": "FLOAT",
+		"JSON":             "NVARCHAR(MAX)",
+	}
+}
+
+// ZeroDefaults uses 0 for false, as BIT columns don't accept FALSE
+func (*MssqlDB) ZeroDefaults() map[string]string {
+	zeros := map[string]string{}
+	for t, zero := range zeroDefaults {
+		zeros[t] = zero
+	}
+	zeros["boolean"] = "0"
+	zeros["bool"] = "0"
+	zeros["blob"] = "0x"
+	return zeros
+}
+
+func (m *MssqlDB) setup() {
+	m.GenericDB.Specific = m
+	m.GenericDB.AlternateNames = m.AlternateNames()
+	m.GenericDB.PrimaryKeyDef = "%s INT IDENTITY(1,1) PRIMARY KEY"
+	m.GenericDB.BigPrimaryKeyDef = "%s BIGINT IDENTITY(1,1) PRIMARY KEY"
+	m.GenericDB.KeyActions = map[string]string{"RESTRICT": "NO ACTION"}
+	m.GenericDB.LengthableColumns = m.LengthableColumns()
+	m.GenericDB.ZeroDefaults = m.ZeroDefaults()
+}
+
+func (m *MssqlDB) CreateTable(table *schema.Table) error {
+	m.setup()
+	return m.GenericDB.CreateTable(table)
+}
+
+func (m *MssqlDB) UpdateTable(table *schema.Table) error {
+	m.setup()
+	return m.GenericDB.UpdateTable(table)
+}
+
+func (m *MssqlDB) HasTable(table *schema.Table) (bool, error) {
+	var cnt int64
+	err := m.DB.QueryRow(
+		`SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_NAME = @p1`,
+		m.Convert.SQLTable(table.Name),
+	).Scan(&cnt)
+	if err != nil {
+		return false, err
+	}
+	return cnt == 1, nil
+}
+
+func (m *MssqlDB) HasColumn(table *schema.Table, col *schema.Column) (bool, error) {
+	var cnt int64
+	err := m.DB.QueryRow(
+		`SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND COLUMN_NAME = @p2`,
+		m.Convert.SQLTable(table.Name),
+		m.Convert.SQLColumn(table.Name, col.Name),
+	).Scan(&cnt)
+	if err != nil {
+		return false, err
+	}
+	return cnt == 1, nil
+}
+
+// CreateColumn differs from the generic version as T-SQL doesn't accept
+// the COLUMN keyword in ALTER TABLE ... ADD
+func (m *MssqlDB) CreateColumn(table *schema.Table, col *schema.Column) error {
+	m.setup()
+	coldef, err := m.columnDef(table, col, true)
+	if err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("ALTER TABLE %s ADD %s", m.Convert.SQLTable(table.Name), coldef)
+	err = m.exec(sql)
+	if err != nil {
+		return err
+	}
+	return m.commentColumns(table, col)
+}
+
+// columnComment sets the MS_Description property, which is where SQL
+// Server tools look for column comments
+func (m *MssqlDB) columnComment(table *schema.Table, col *schema.Column) string {
+	return fmt.Sprintf(
+		"EXEC sp_addextendedproperty @name = N'MS_Description', @value = N%s, @level0type = N'SCHEMA', @level0name = N'dbo', @level1type = N'TABLE', @level1name = N%s, @level2type = N'COLUMN', @level2name = N%s",
+		quoteValues([]string{col.Comment}),
+		quoteValues([]string{m.Convert.SQLTable(table.Name)}),
+		quoteValues([]string{m.Convert.SQLColumn(table.Name, col.Name)}),
+	)
+}
+
+// mssqlDropConstraints drops the DEFAULT, CHECK and single column UNIQUE
+// constraints and the comment of a column, which SQL Server gives
+// generated names, so they're found in the sys catalog views.
+const mssqlDropConstraints = `DECLARE @sql NVARCHAR(MAX) = N'';
+DECLARE @table INT = OBJECT_ID(N'%[1]s');
+DECLARE @column INT = COLUMNPROPERTY(@table, N'%[2]s', 'ColumnId');
+SELECT @sql += N'ALTER TABLE %[1]s DROP CONSTRAINT ' + QUOTENAME(name) + N';' FROM sys.default_constraints WHERE parent_object_id = @table AND parent_column_id = @column;
+SELECT @sql += N'ALTER TABLE %[1]s DROP CONSTRAINT ' + QUOTENAME(name) + N';' FROM sys.check_constraints WHERE parent_object_id = @table AND parent_column_id = @column;
+SELECT @sql += N'ALTER TABLE %[1]s DROP CONSTRAINT ' + QUOTENAME(kc.name) + N';' FROM sys.key_constraints kc
+	JOIN sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+	WHERE kc.type = 'UQ' AND kc.parent_object_id = @table
+	GROUP BY kc.name HAVING COUNT(*) = 1 AND MIN(ic.column_id) = @column;
+IF EXISTS (SELECT 1 FROM sys.extended_properties WHERE major_id = @table AND minor_id = @column AND name = N'MS_Description')
+	SET @sql += N'EXEC sp_dropextendedproperty @name = N''MS_Description'', @level0type = N''SCHEMA'', @level0name = N''dbo'', @level1type = N''TABLE'', @level1name = N''%[1]s'', @level2type = N''COLUMN'', @level2name = N''%[2]s'';';
+EXEC sp_executesql @sql`
+
+// ModifyColumn drops the constraints on a column, changes its nullability
+// with ALTER COLUMN, and then adds the constraints back
+func (m *MssqlDB) ModifyColumn(table *schema.Table, col *schema.Column) error {
+	name, column := m.Convert.SQLTable(table.Name), m.Convert.SQLColumn(table.Name, col.Name)
+	coldef, err := m.columnType(table, col)
+	if err != nil {
+		return err
+	}
+	null := " NULL"
+	if !col.Null {
+		null = " NOT NULL"
+	}
+	sqls := []string{
+		fmt.Sprintf(mssqlDropConstraints, name, column),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s%s", name, coldef, null),
+	}
+	if col.Default != "" {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD DEFAULT %s FOR %s", name, col.Default, column))
+	}
+	if col.Unique {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s)", name, column))
+	}
+	for _, check := range m.columnChecks(table, col) {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD CHECK (%s)", name, check))
+	}
+	if col.Comment != "" {
+		sqls = append(sqls, m.columnComment(table, col))
+	}
+
+	for _, sql := range sqls {
+		err = m.exec(sql)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (m *MssqlDB) HasIndex(table *schema.Table, index schema.Index) (bool, error) {
+	name, err := m.getIndexName(table, index)
+	return name != "", err
+}
+
+func (m *MssqlDB) getIndexName(table *schema.Table, index schema.Index) (string, error) {
+	indexes, err := m.indexList(m.Convert.SQLTable(table.Name))
+	if err != nil {
+		return "", err
+	}
+	return m.findIndex(indexes, table, index), nil
+}
+
+func (m *MssqlDB) indexList(table string) ([]namedIndex, error) {
+	sql := `SELECT i.name, i.is_unique, c.name FROM sys.indexes i
+INNER JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+INNER JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE i.object_id = OBJECT_ID(@p1) AND i.is_primary_key = 0
+ORDER BY i.name, ic.key_ordinal`
+	rows, err := m.DB.Query(sql, table)
+	if err != nil {
+		return nil, fmt.Errorf("Enumerate SQL Server indexes: %v", err)
+	}
+	return groupIndexColumns(rows)
+}
+
+func (m *MssqlDB) ListTables() ([]string, error) {
+	return queryStrings(
+		m.DB,
+		`SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME`,
+	)
+}
+
+func (m *MssqlDB) TableColumns(table string) ([]IntrospectedColumn, error) {
+	rows, err := m.DB.Query(
+		`SELECT c.COLUMN_NAME, c.DATA_TYPE, COALESCE(c.CHARACTER_MAXIMUM_LENGTH, 0),
+COALESCE(c.NUMERIC_PRECISION, 0), COALESCE(c.NUMERIC_SCALE, 0),
+CASE WHEN EXISTS (
+	SELECT 1 FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+	JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu ON kcu.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+	WHERE tc.CONSTRAINT_TYPE = 'PRIMARY KEY' AND tc.TABLE_NAME = c.TABLE_NAME AND kcu.COLUMN_NAME = c.COLUMN_NAME
+) THEN 1 ELSE 0 END,
+CASE WHEN c.IS_NULLABLE = 'YES' THEN 1 ELSE 0 END,
+COALESCE(c.COLUMN_DEFAULT, ''),
+COALESCE((
+	SELECT CAST(ep.value AS NVARCHAR(4000)) FROM sys.extended_properties ep
+	WHERE ep.major_id = OBJECT_ID(c.TABLE_NAME) AND ep.minor_id = COLUMNPROPERTY(OBJECT_ID(c.TABLE_NAME), c.COLUMN_NAME, 'ColumnId') AND ep.name = 'MS_Description'
+), ''),
+COALESCE((
+	SELECT STRING_AGG(cc.definition, ' AND ') FROM sys.check_constraints cc
+	WHERE cc.parent_object_id = OBJECT_ID(c.TABLE_NAME) AND cc.parent_column_id = COLUMNPROPERTY(OBJECT_ID(c.TABLE_NAME), c.COLUMN_NAME, 'ColumnId')
+), '')
+FROM INFORMATION_SCHEMA.COLUMNS c WHERE c.TABLE_NAME = @p1 ORDER BY c.ORDINAL_POSITION`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []IntrospectedColumn{}
+	for rows.Next() {
+		var col IntrospectedColumn
+		var dataType string
+		var length, precision, scale int
+		err = rows.Scan(
+			&col.Name, &dataType, &length, &precision, &scale, &col.PrimaryKey, &col.Null,
+			&col.Default, &col.Comment, &col.Check,
+		)
+		if err != nil {
+			return nil, err
+		}
+		col.Type, col.Length = NormalizeType(dataType)
+		if col.Type == "decimal" {
+			col.Precision, col.Scale = precision, scale
+		}
+		// -1 is how SQL Server reports (MAX)
+		if length == -1 {
+			col.Type, col.Length = NormalizeType(dataType + "(max)")
+		} else if length != 0 {
+			col.Length = length
+		}
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+func (m *MssqlDB) TableIndexes(table string) ([]schema.Index, error) {
+	indexes, err := m.indexList(table)
+	if err != nil {
+		return nil, err
+	}
+	return schemaIndexes(indexes), nil
+}
+
+func (m *MssqlDB) TableForeignKeys(table string) ([]ForeignKey, error) {
+	rows, err := m.DB.Query(
+		`SELECT pc.name, rt.name, rc.name,
+fk.name, fk.delete_referential_action_desc, fk.update_referential_action_desc, CAST(0 AS BIT)
+FROM sys.foreign_key_columns fkc
+INNER JOIN sys.foreign_keys fk ON fk.object_id = fkc.constraint_object_id
+INNER JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+INNER JOIN sys.tables rt ON rt.object_id = fkc.referenced_object_id
+INNER JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+WHERE fkc.parent_object_id = OBJECT_ID(@p1)`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(rows)
+}
+
+// CreateIndex creates an index, partial indexes are what SQL Server calls
+// filtered indexes. Expressions have to be indexed through a computed
+// column, which isn't done for them.
+func (m *MssqlDB) CreateIndex(table *schema.Table, index schema.Index) error {
+	for n, col := range index.Columns {
+		if index.Expression(n) {
+			return fmt.Errorf("SQL Server can't index the expression %s, index a computed column instead", col)
+		}
+	}
+	return m.exec(m.indexSQL(table, index))
+}
+
+// RemoveIndex needs the table name in T-SQL's DROP INDEX
+func (m *MssqlDB) RemoveIndex(table *schema.Table, index schema.Index) error {
+	name, err := m.getIndexName(table, index)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	return m.exec(fmt.Sprintf("DROP INDEX %s ON %s", name, m.Convert.SQLTable(table.Name)))
+}
+
+// RenameTable quotes the names rather than binding them, so a planned
+// rename can be run as it's printed
+func (m *MssqlDB) RenameTable(table *schema.Table, oldName string) error {
+	return m.exec("EXEC sp_rename " + quoteValues([]string{oldName, m.Convert.SQLTable(table.Name)}))
+}
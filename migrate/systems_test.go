@@ -0,0 +1,785 @@
+package migrate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/acsellers/dr/schema"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// recorder is a database/sql driver that remembers every statement it is
+// given, letting the Alterers be checked against golden SQL without a server.
+// Queries come back as a single zero, which reads as "doesn't exist" for
+// every HasTable/HasColumn style check.
+type recorder struct {
+	sync.Mutex
+	statements []string
+}
+
+var recorders = map[string]*recorder{}
+
+func init() {
+	sql.Register("recorder", &recorderDriver{})
+}
+
+type recorderDriver struct{}
+
+func (recorderDriver) Open(name string) (driver.Conn, error) {
+	return &recorderConn{recorders[name]}, nil
+}
+
+type recorderConn struct {
+	r *recorder
+}
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &recorderStmt{c.r, query}, nil
+}
+func (*recorderConn) Close() error              { return nil }
+func (*recorderConn) Begin() (driver.Tx, error) { return recorderTx{}, nil }
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error   { return nil }
+func (recorderTx) Rollback() error { return nil }
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (*recorderStmt) Close() error  { return nil }
+func (*recorderStmt) NumInput() int { return -1 }
+
+func (s *recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.Lock()
+	defer s.r.Unlock()
+	s.r.statements = append(s.r.statements, s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "SELECT COUNT") {
+		return &recorderRows{remaining: 1}, nil
+	}
+	return &recorderRows{}, nil
+}
+
+type recorderRows struct {
+	remaining int
+}
+
+func (*recorderRows) Columns() []string { return []string{"count"} }
+func (*recorderRows) Close() error      { return nil }
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.remaining == 0 {
+		return io.EOF
+	}
+	r.remaining--
+	dest[0] = int64(0)
+	return nil
+}
+
+func openRecorder(t *testing.T) (*sql.DB, *recorder) {
+	name := fmt.Sprintf("%s-%d", t.Name(), len(recorders))
+	r := &recorder{}
+	recorders[name] = r
+	db, err := sql.Open("recorder", name)
+	if err != nil {
+		t.Fatal("Open recorder:", err)
+	}
+	return db, r
+}
+
+type plainNames struct{}
+
+func (plainNames) SQLTable(table string) string          { return strings.ToLower(table) }
+func (plainNames) SQLColumn(table, column string) string { return strings.ToLower(column) }
+
+func testSchema() schema.Schema {
+	s := schema.Schema{
+		Tables: map[string]*schema.Table{
+			"User": &schema.Table{
+				Name: "User",
+				Columns: []schema.Column{
+					schema.Column{Name: "ID", Type: "integer", Length: 10},
+					schema.Column{Name: "Name", Type: "varchar", Length: 255},
+					schema.Column{Name: "Bio", Type: "text", Null: true},
+					schema.Column{Name: "Admin", Type: "boolean"},
+					schema.Column{Name: "CreatedAt", Type: "timestamp"},
+				},
+				Index: []schema.Index{
+					schema.Index{Columns: []string{"Name"}},
+				},
+			},
+		},
+	}
+	return s
+}
+
+func TestMssqlCreateTable(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+
+	d := Database{
+		DB:         db,
+		Schema:     testSchema(),
+		Translator: plainNames{},
+		DBMS:       MSSQL,
+	}
+	err := d.Migrate()
+	if err != nil {
+		t.Fatal("Migrate:", err)
+	}
+
+	expected := []string{
+		"CREATE TABLE user(id INT IDENTITY(1,1) PRIMARY KEY, name NVARCHAR(255) NOT NULL, bio NVARCHAR(MAX), admin BIT NOT NULL, createdat DATETIME2 NOT NULL)",
+		"CREATE INDEX idx_user_Name ON user (name)",
+	}
+	if strings.Join(r.statements, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", strings.Join(expected, "\n"), strings.Join(r.statements, "\n"))
+	}
+}
+
+func TestMssqlCreateColumn(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+
+	m := &MssqlDB{GenericDB{DB: db, Convert: plainNames{}}}
+	s := testSchema()
+	table := s.Tables["User"]
+	for _, col := range table.Columns[1:] {
+		err := m.CreateColumn(table, &col)
+		if err != nil {
+			t.Fatal("CreateColumn:", err)
+		}
+	}
+
+	expected := []string{
+		"ALTER TABLE user ADD name NVARCHAR(255) NOT NULL DEFAULT ''",
+		"ALTER TABLE user ADD bio NVARCHAR(MAX)",
+		"ALTER TABLE user ADD admin BIT NOT NULL DEFAULT 0",
+		"ALTER TABLE user ADD createdat DATETIME2 NOT NULL DEFAULT '0001-01-01 00:00:00'",
+	}
+	if strings.Join(r.statements, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", strings.Join(expected, "\n"), strings.Join(r.statements, "\n"))
+	}
+}
+
+func TestMssqlRenameTable(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+
+	m := &MssqlDB{GenericDB{DB: db, Convert: plainNames{}}}
+	err := m.RenameTable(&schema.Table{Name: "User"}, "o'user")
+	if err != nil {
+		t.Fatal("RenameTable:", err)
+	}
+	expected := "EXEC sp_rename 'o''user', 'user'"
+	if strings.Join(r.statements, "\n") != expected {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", expected, strings.Join(r.statements, "\n"))
+	}
+}
+
+func TestPlanDoesNotExecute(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+
+	d := Database{
+		DB:         db,
+		Schema:     testSchema(),
+		Translator: plainNames{},
+		DBMS:       MSSQL,
+	}
+	plan, err := d.Plan()
+	if err != nil {
+		t.Fatal("Plan:", err)
+	}
+	if len(r.statements) != 0 {
+		t.Fatalf("Plan executed statements:\n%s", strings.Join(r.statements, "\n"))
+	}
+
+	expected := []string{
+		"CREATE TABLE user(id INT IDENTITY(1,1) PRIMARY KEY, name NVARCHAR(255) NOT NULL, bio NVARCHAR(MAX), admin BIT NOT NULL, createdat DATETIME2 NOT NULL)",
+		"CREATE INDEX idx_user_Name ON user (name)",
+	}
+	if strings.Join(plan, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", strings.Join(expected, "\n"), strings.Join(plan, "\n"))
+	}
+}
+
+func TestSqliteNullColumns(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+
+	s := &SqliteDB{GenericDB{DB: db, Convert: plainNames{}}}
+	table := testSchema().Tables["User"]
+	err := s.CreateTable(table)
+	if err != nil {
+		t.Fatal("CreateTable:", err)
+	}
+	for _, col := range table.Columns[1:3] {
+		err = s.CreateColumn(table, &col)
+		if err != nil {
+			t.Fatal("CreateColumn:", err)
+		}
+	}
+
+	expected := []string{
+		"CREATE TABLE user(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL, bio TEXT, admin BOOLEAN NOT NULL, createdat TIMESTAMP NOT NULL)",
+		"CREATE INDEX idx_user_Name ON user (name)",
+		"ALTER TABLE user ADD COLUMN name VARCHAR(255) NOT NULL DEFAULT ''",
+		"ALTER TABLE user ADD COLUMN bio TEXT",
+	}
+	if strings.Join(r.statements, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", strings.Join(expected, "\n"), strings.Join(r.statements, "\n"))
+	}
+}
+
+func TestSqliteUniqueColumn(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE user(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL)")
+	if err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	s := testSchema()
+	user := s.Tables["User"]
+	user.Columns = append(user.Columns[:2], schema.Column{Name: "Email", Type: "varchar", Length: 255, Null: true, Unique: true})
+	user.Index = nil
+	d := Database{DB: db, Schema: s, Translator: plainNames{}, DBMS: Sqlite}
+	err = d.Migrate()
+	if err != nil {
+		t.Fatal("Migrate:", err)
+	}
+
+	_, err = db.Exec("INSERT INTO user (name, email) VALUES ('a', 'a@example.com')")
+	if err != nil {
+		t.Fatal("Insert:", err)
+	}
+	_, err = db.Exec("INSERT INTO user (name, email) VALUES ('b', 'a@example.com')")
+	if err == nil {
+		t.Error("Duplicate email was inserted into the unique column")
+	}
+	current, err := d.UpToDate()
+	if err != nil || !current {
+		t.Error("Table wasn't up to date after adding the unique column", err)
+	}
+}
+
+func TestSqliteRebuildRollback(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{
+		"PRAGMA foreign_keys = ON",
+		"CREATE TABLE user(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name VARCHAR(255))",
+		"INSERT INTO user (name) VALUES (NULL)",
+	} {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatal(statement, err)
+		}
+	}
+
+	// name becomes NOT NULL while a row still holds NULL
+	s := testSchema()
+	s.Tables["User"].Columns = s.Tables["User"].Columns[:2]
+	s.Tables["User"].Index = nil
+	d := Database{DB: db, Schema: s, Translator: plainNames{}, DBMS: Sqlite}
+	if d.Migrate() == nil {
+		t.Fatal("Rebuilt the table around a NULL name")
+	}
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'user_rebuild'").Scan(&tables)
+	if tables != 0 {
+		t.Error("The rebuild's table was left behind")
+	}
+
+	if _, err = db.Exec("UPDATE user SET name = 'fixed'"); err != nil {
+		t.Fatal("Update:", err)
+	}
+	d = Database{DB: db, Schema: s, Translator: plainNames{}, DBMS: Sqlite}
+	if err = d.Migrate(); err != nil {
+		t.Fatal("Migrate after fixing the rows:", err)
+	}
+	var keys bool
+	db.QueryRow("PRAGMA foreign_keys").Scan(&keys)
+	if !keys {
+		t.Error("Foreign keys were left off after the rebuild")
+	}
+}
+
+func TestMigrateUpdateError(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE user(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL)")
+	if err != nil {
+		t.Fatal("Create:", err)
+	}
+	_, err = db.Exec("INSERT INTO user (name) VALUES ('a'), ('b')")
+	if err != nil {
+		t.Fatal("Insert:", err)
+	}
+
+	// both rows get an empty email, so the unique index can't be built
+	s := testSchema()
+	user := s.Tables["User"]
+	user.Columns = append(user.Columns[:2], schema.Column{Name: "Email", Type: "varchar", Length: 255, Unique: true})
+	user.Index = nil
+	d := Database{DB: db, Schema: s, Translator: plainNames{}, DBMS: Sqlite}
+	if d.Migrate() == nil {
+		t.Error("Migrate didn't return the error from updating the table")
+	}
+}
+
+func TestJSONColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "Forum",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Settings", Type: "json"},
+		},
+	}
+	expected := map[System]string{
+		Postgres: "CREATE TABLE forum(id SERIAL PRIMARY KEY, settings JSONB NOT NULL)",
+		MySQL:    "CREATE TABLE forum(id SERIAL PRIMARY KEY, settings JSON NOT NULL)",
+		Sqlite:   "CREATE TABLE forum(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, settings TEXT NOT NULL)",
+		MSSQL:    "CREATE TABLE forum(id INT IDENTITY(1,1) PRIMARY KEY, settings NVARCHAR(MAX) NOT NULL)",
+	}
+	for dbms, create := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: dbms}
+		d.SetAlterer()
+		err := d.Alterer.CreateTable(table)
+		if err != nil {
+			t.Fatal("CreateTable:", err)
+		}
+		if len(r.statements) != 1 || r.statements[0] != create {
+			t.Errorf("Expected %s, got %v", create, r.statements)
+		}
+		db.Close()
+	}
+}
+
+func TestEnumColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "User",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Level", Type: "enum", Length: 13, Enum: "PermissionLevel", Values: []string{"Reader", "Administrator"}},
+		},
+	}
+	expected := map[System][]string{
+		Postgres: []string{
+			"CREATE TYPE permissionlevel AS ENUM ('Reader', 'Administrator')",
+			"CREATE TABLE user(id SERIAL PRIMARY KEY, level permissionlevel NOT NULL)",
+		},
+		MySQL: []string{
+			"CREATE TABLE user(id SERIAL PRIMARY KEY, level ENUM('Reader', 'Administrator') NOT NULL)",
+		},
+		Sqlite: []string{
+			"CREATE TABLE user(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, level VARCHAR(13) NOT NULL CHECK (level IN ('Reader', 'Administrator')))",
+		},
+		MSSQL: []string{
+			"CREATE TABLE user(id INT IDENTITY(1,1) PRIMARY KEY, level NVARCHAR(13) NOT NULL CHECK (level IN ('Reader', 'Administrator')))",
+		},
+	}
+	for dbms, statements := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: dbms}
+		d.SetAlterer()
+		err := d.Alterer.CreateTable(table)
+		if err != nil {
+			t.Fatal("CreateTable:", err)
+		}
+		if fmt.Sprint(r.statements) != fmt.Sprint(statements) {
+			t.Errorf("Expected %v, got %v", statements, r.statements)
+		}
+		db.Close()
+	}
+
+	db, r := openRecorder(t)
+	defer db.Close()
+	d := Database{DB: db, Translator: plainNames{}, DBMS: Sqlite}
+	d.SetAlterer()
+	err := d.Alterer.CreateColumn(table, &table.Columns[1])
+	if err != nil {
+		t.Fatal("CreateColumn:", err)
+	}
+	add := "ALTER TABLE user ADD COLUMN level VARCHAR(13) NOT NULL DEFAULT 'Reader' CHECK (level IN ('Reader', 'Administrator'))"
+	if len(r.statements) != 1 || r.statements[0] != add {
+		t.Errorf("Expected %s, got %v", add, r.statements)
+	}
+}
+
+func TestDecimalColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "User",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Pay", Type: "decimal", Precision: 12, Scale: 2},
+		},
+	}
+	db, r := openRecorder(t)
+	defer db.Close()
+	d := Database{DB: db, Translator: plainNames{}, DBMS: Postgres}
+	d.SetAlterer()
+	err := d.Alterer.CreateColumn(table, &table.Columns[1])
+	if err != nil {
+		t.Fatal("CreateColumn:", err)
+	}
+	add := "ALTER TABLE user ADD COLUMN pay NUMERIC(12,2) NOT NULL DEFAULT 0"
+	if len(r.statements) != 1 || r.statements[0] != add {
+		t.Errorf("Expected %s, got %v", add, r.statements)
+	}
+
+	if typ, _ := NormalizeType("NUMERIC(12, 2)"); typ != "decimal" {
+		t.Error("NUMERIC normalized to", typ)
+	}
+	if p, s := decimalSize("decimal(12,2)"); p != 12 || s != 2 {
+		t.Error("Unexpected decimal size", p, s)
+	}
+}
+
+func TestIntegerColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "Post",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Views", Type: "bigint"},
+			schema.Column{Name: "Rating", Type: "smallint", Unsigned: true},
+			schema.Column{Name: "Hits", Type: "integer", Length: 10, Unsigned: true},
+		},
+	}
+	expected := map[System]string{
+		MySQL:  "CREATE TABLE post(id SERIAL PRIMARY KEY, views BIGINT NOT NULL, rating SMALLINT UNSIGNED NOT NULL, hits INTEGER(10) UNSIGNED NOT NULL)",
+		Sqlite: "CREATE TABLE post(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, views BIGINT NOT NULL, rating INTEGER NOT NULL, hits BIGINT NOT NULL)",
+	}
+	for dbms, create := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: dbms}
+		d.SetAlterer()
+		err := d.Alterer.CreateTable(table)
+		if err != nil {
+			t.Fatal("CreateTable:", err)
+		}
+		if len(r.statements) != 1 || r.statements[0] != create {
+			t.Errorf("Expected %s, got %v", create, r.statements)
+		}
+		db.Close()
+	}
+
+	keys := map[System][]string{
+		Sqlite:   {"id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT", "id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT"},
+		Postgres: {"id BIGSERIAL PRIMARY KEY", "id BIGSERIAL PRIMARY KEY"},
+		MySQL:    {"id SERIAL PRIMARY KEY", "id SERIAL PRIMARY KEY"},
+		MSSQL:    {"id BIGINT IDENTITY(1,1) PRIMARY KEY", "id BIGINT IDENTITY(1,1) PRIMARY KEY"},
+	}
+	for dbms, defs := range keys {
+		for i, key := range []schema.Column{{Name: "ID", Type: "bigint"}, {Name: "ID", Type: "integer", Unsigned: true}} {
+			db, r := openRecorder(t)
+			d := Database{DB: db, Translator: plainNames{}, DBMS: dbms}
+			d.SetAlterer()
+			err := d.Alterer.CreateTable(&schema.Table{Name: "Visit", Columns: []schema.Column{key}})
+			if err != nil {
+				t.Fatal("CreateTable:", err)
+			}
+			if create := "CREATE TABLE visit(" + defs[i] + ")"; len(r.statements) != 1 || r.statements[0] != create {
+				t.Errorf("Expected %s, got %v", create, r.statements)
+			}
+			db.Close()
+		}
+	}
+
+	for dbType, expected := range map[string]string{"int2": "smallint", "tinyint(4)": "smallint", "bigint(20) unsigned": "bigint", "int(11)": "integer"} {
+		if typ, _ := NormalizeType(dbType); typ != expected {
+			t.Errorf("%s normalized to %s, expected %s", dbType, typ, expected)
+		}
+	}
+}
+
+func constraintTable() *schema.Table {
+	return &schema.Table{
+		Name: "Account",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Email", Type: "varchar", Length: 255, Unique: true, Comment: "where receipts are sent"},
+			schema.Column{Name: "Status", Type: "varchar", Length: 20, Default: "'active'", Check: "status <> ''"},
+		},
+	}
+}
+
+func TestConstraintColumns(t *testing.T) {
+	columns := "email VARCHAR(255) NOT NULL UNIQUE, status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status <> '')"
+	expected := map[System][]string{
+		Sqlite: {
+			"CREATE TABLE account(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, " + columns + ")",
+		},
+		Postgres: {
+			"CREATE TABLE account(id SERIAL PRIMARY KEY, " + columns + ")",
+			"COMMENT ON COLUMN account.email IS 'where receipts are sent'",
+		},
+		MySQL: {
+			"CREATE TABLE account(id SERIAL PRIMARY KEY, email VARCHAR(255) NOT NULL UNIQUE COMMENT 'where receipts are sent', status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status <> ''))",
+		},
+		MSSQL: {
+			"CREATE TABLE account(id INT IDENTITY(1,1) PRIMARY KEY, email NVARCHAR(255) NOT NULL UNIQUE, status NVARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status <> ''))",
+			"EXEC sp_addextendedproperty @name = N'MS_Description', @value = N'where receipts are sent', @level0type = N'SCHEMA', @level0name = N'dbo', @level1type = N'TABLE', @level1name = N'account', @level2type = N'COLUMN', @level2name = N'email'",
+		},
+	}
+	for system, statements := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: system}
+		d.SetAlterer()
+		err := d.Alterer.CreateTable(constraintTable())
+		if err != nil {
+			t.Fatal("CreateTable:", err)
+		}
+		if strings.Join(r.statements, "\n") != strings.Join(statements, "\n") {
+			t.Errorf("%v expected:\n%s\n\nRecieved:\n%s", d.Alterer, strings.Join(statements, "\n"), strings.Join(r.statements, "\n"))
+		}
+		db.Close()
+	}
+}
+
+func TestPostgresModifyColumn(t *testing.T) {
+	db, r := openRecorder(t)
+	defer db.Close()
+	d := Database{DB: db, Translator: plainNames{}, DBMS: Postgres}
+	d.SetAlterer()
+	table := constraintTable()
+	err := d.Alterer.ModifyColumn(table, &table.Columns[2])
+	if err != nil {
+		t.Fatal("ModifyColumn:", err)
+	}
+
+	expected := []string{
+		"ALTER TABLE account ALTER COLUMN status SET NOT NULL",
+		"ALTER TABLE account ALTER COLUMN status SET DEFAULT 'active'",
+		"ALTER TABLE account DROP CONSTRAINT IF EXISTS account_status_key",
+		"ALTER TABLE account DROP CONSTRAINT IF EXISTS account_status_check",
+		"ALTER TABLE account ADD CONSTRAINT account_status_check CHECK (status <> '')",
+		"COMMENT ON COLUMN account.status IS NULL",
+	}
+	if strings.Join(r.statements, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\n\nRecieved:\n%s", strings.Join(expected, "\n"), strings.Join(r.statements, "\n"))
+	}
+}
+
+func TestConstraintsDiffer(t *testing.T) {
+	g := &GenericDB{Convert: plainNames{}}
+	table := constraintTable()
+	table.Columns = append(table.Columns, schema.Column{Name: "Level", Type: "enum", Values: []string{"Reader", "Admin"}})
+
+	have := []schema.Column{
+		// as read back from Postgres and SQL Server
+		{Unique: true, Comment: "where receipts are sent"},
+		{Default: "'active'::character varying", Check: "((status)::text <> ''::text)"},
+		{Default: "('Reader')", Check: "([level]='Admin' OR [level]='Reader')"},
+	}
+	for i, col := range table.Columns[1:] {
+		if g.constraintsDiffer(table, &col, have[i], true) {
+			t.Errorf("%s was found to differ from %+v", col.Name, have[i])
+		}
+	}
+
+	changed := table.Columns[2]
+	changed.Check = "status <> 'closed'"
+	if !g.constraintsDiffer(table, &changed, have[1], true) {
+		t.Error("A changed check wasn't found")
+	}
+	changed = table.Columns[1]
+	changed.Null = true
+	if !g.constraintsDiffer(table, &changed, have[0], true) {
+		t.Error("A column changed to NULL wasn't found")
+	}
+	if g.constraintsDiffer(table, &table.Columns[1], schema.Column{Unique: true}, false) {
+		t.Error("Comments were compared for a database without them")
+	}
+}
+
+func TestSqliteChecks(t *testing.T) {
+	checks := sqliteChecks(`CREATE TABLE account(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, email VARCHAR(255) NOT NULL UNIQUE, status VARCHAR(20) NOT NULL DEFAULT 'a, b' CHECK (status <> '') CHECK(length(status) < 20))`)
+	if len(checks) != 1 || checks["status"] != "status <> '' AND length(status) < 20" {
+		t.Errorf("Unexpected checks %q", checks)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	table := &schema.Table{
+		Name: "User",
+		Columns: []schema.Column{
+			schema.Column{Name: "ID", Type: "integer"},
+			schema.Column{Name: "Email", Type: "varchar", Length: 255},
+			schema.Column{Name: "DeletedAt", Type: "timestamp", Null: true},
+		},
+	}
+	expression := schema.Index{Columns: []string{"lower(Email)"}, Unique: true}
+	partial := schema.Index{Columns: []string{"Email", "DeletedAt"}, Desc: []bool{false, true}, Where: "DeletedAt IS NULL AND Email <> 'DeletedAt'"}
+	named := schema.Index{Columns: []string{"Email"}, Name: "user_email"}
+
+	expected := map[System][]string{
+		Postgres: {
+			"CREATE UNIQUE INDEX idx_user_lower_Email ON user ((lower(email)))",
+			"CREATE INDEX idx_user_Email_DeletedAt ON user (email, deletedat DESC) WHERE deletedat IS NULL AND email <> 'DeletedAt'",
+			"CREATE INDEX user_email ON user (email)",
+		},
+		MySQL: {
+			"CREATE UNIQUE INDEX idx_user_lower_Email ON user ((lower(email)))",
+			"MySQL doesn't support partial indexes, idx_user_Email_DeletedAt has a where condition",
+			"CREATE INDEX user_email ON user (email)",
+		},
+		MSSQL: {
+			"SQL Server can't index the expression lower(Email), index a computed column instead",
+			"CREATE INDEX idx_user_Email_DeletedAt ON user (email, deletedat DESC) WHERE deletedat IS NULL AND email <> 'DeletedAt'",
+			"CREATE INDEX user_email ON user (email)",
+		},
+	}
+	for system, statements := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: system}
+		d.SetAlterer()
+		for _, index := range []schema.Index{expression, partial, named} {
+			err := d.Alterer.CreateIndex(table, index)
+			if err != nil {
+				r.statements = append(r.statements, err.Error())
+			}
+		}
+		if strings.Join(r.statements, "\n") != strings.Join(statements, "\n") {
+			t.Errorf("%v expected:\n%s\n\nRecieved:\n%s", d.Alterer, strings.Join(statements, "\n"), strings.Join(r.statements, "\n"))
+		}
+		db.Close()
+	}
+
+	g := &GenericDB{Convert: plainNames{}}
+	indexes := []namedIndex{
+		{Name: "idx_user_lower_Email", Unique: true, Columns: []string{"lower(email)"}},
+		{Name: "legacy", Columns: []string{"email"}},
+	}
+	if g.findIndex(indexes, table, expression) != "idx_user_lower_Email" {
+		t.Error("Expression index wasn't found by name")
+	}
+	if g.findIndex(indexes, table, named) != "legacy" {
+		t.Error("Plain index wasn't found by its columns")
+	}
+	if g.findIndex(indexes, table, partial) != "" {
+		t.Error("Partial index was found by its columns")
+	}
+}
+
+func keyTables() (*schema.Table, *schema.Table) {
+	user := &schema.Table{
+		Name:    "User",
+		Columns: []schema.Column{{Name: "ID", Type: "integer"}},
+	}
+	post := &schema.Table{
+		Name: "Post",
+		Columns: []schema.Column{
+			{Name: "ID", Type: "integer"},
+			{Name: "UserID", Type: "integer", Null: true},
+		},
+	}
+	post.ChildOf = []schema.ManyRelationship{
+		{Parent: user, Child: post, ChildColumn: &post.Columns[1], OnDelete: "SET NULL", OnUpdate: "RESTRICT"},
+	}
+	return user, post
+}
+
+func TestForeignKeyActions(t *testing.T) {
+	key := "FOREIGN KEY(userid) REFERENCES user(id) ON DELETE SET NULL ON UPDATE "
+	expected := map[System]string{
+		Sqlite:   "CREATE TABLE post(id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, userid INTEGER, " + key + "RESTRICT)",
+		Postgres: "CREATE TABLE post(id SERIAL PRIMARY KEY, userid INTEGER, " + key + "RESTRICT)",
+		MySQL:    "CREATE TABLE post(id SERIAL PRIMARY KEY, userid INTEGER, " + key + "RESTRICT)",
+		MSSQL:    "CREATE TABLE post(id INT IDENTITY(1,1) PRIMARY KEY, userid INTEGER, " + key + "NO ACTION)",
+	}
+	for system, statement := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: system}
+		d.SetAlterer()
+		_, post := keyTables()
+		err := d.Alterer.CreateTable(post)
+		if err != nil {
+			t.Fatal("CreateTable:", err)
+		}
+		if len(r.statements) != 1 || r.statements[0] != statement {
+			t.Errorf("%v expected:\n%s\n\nRecieved:\n%s", d.Alterer, statement, strings.Join(r.statements, "\n"))
+		}
+
+		_, post = keyTables()
+		post.ChildOf[0].OnDelete = "SET DEFAULT"
+		post.ChildOf[0].Deferrable = true
+		err = d.Alterer.CreateTable(post)
+		if (err != nil) != (system == MySQL || system == MSSQL) {
+			t.Errorf("%v creating a deferred SET DEFAULT key: %v", d.Alterer, err)
+		}
+		db.Close()
+	}
+}
+
+func TestAddForeignKey(t *testing.T) {
+	expected := map[System][]string{
+		Postgres: {
+			"ALTER TABLE post DROP CONSTRAINT post_userid_fkey",
+			"ALTER TABLE post ADD CONSTRAINT fk_post_userid FOREIGN KEY(userid) REFERENCES user(id) ON DELETE SET NULL ON UPDATE RESTRICT",
+		},
+		MySQL: {
+			"ALTER TABLE post DROP FOREIGN KEY post_userid_fkey",
+			"ALTER TABLE post ADD CONSTRAINT fk_post_userid FOREIGN KEY(userid) REFERENCES user(id) ON DELETE SET NULL ON UPDATE RESTRICT",
+		},
+	}
+	for system, statements := range expected {
+		db, r := openRecorder(t)
+		d := Database{DB: db, Translator: plainNames{}, DBMS: system}
+		d.SetAlterer()
+		_, post := keyTables()
+		var g *GenericDB
+		switch a := d.Alterer.(type) {
+		case *PostgresDB:
+			g = &a.GenericDB
+		case *MysqlDB:
+			g = &a.GenericDB
+		}
+		fk := g.foreignKeys(post)[0]
+		fk.Name = "post_userid_fkey"
+		err := g.addForeignKey(post, fk)
+		if err != nil {
+			t.Fatal("addForeignKey:", err)
+		}
+		if strings.Join(r.statements, "\n") != strings.Join(statements, "\n") {
+			t.Errorf("%v expected:\n%s\n\nRecieved:\n%s", d.Alterer, strings.Join(statements, "\n"), strings.Join(r.statements, "\n"))
+		}
+		db.Close()
+	}
+
+	user, post := keyTables()
+	post.ChildOf[0].TypeColumn, post.ChildOf[0].TypeName = &post.Columns[0], user.Name
+	if keys := (&GenericDB{Convert: plainNames{}}).foreignKeys(post); len(keys) != 0 {
+		t.Error("Polymorphic relations were given foreign keys", keys)
+	}
+	if keyAction("restrict") != keyAction("") || keyAction("SET NULL") == keyAction("CASCADE") {
+		t.Error("Unexpected key action comparison")
+	}
+	deferred := sqliteDeferred(`CREATE TABLE post(id INTEGER, userid INTEGER, FOREIGN KEY(userid) REFERENCES user(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED, FOREIGN KEY("editorid") REFERENCES user(id))`)
+	if len(deferred) != 1 || !deferred["userid"] {
+		t.Errorf("Unexpected deferred keys %v", deferred)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/acsellers/dr/parse"
+	"github.com/codegangsta/cli"
+)
+
+// driverImports are the packages registering each database/sql driver that
+// dr migrate knows how to link into its migration program
+var driverImports = map[string]string{
+	"sqlite3":   "github.com/mattn/go-sqlite3",
+	"postgres":  "github.com/lib/pq",
+	"mysql":     "github.com/go-sql-driver/mysql",
+	"mssql":     "github.com/denisenkom/go-mssqldb",
+	"sqlserver": "github.com/denisenkom/go-mssqldb",
+}
+
+// runMigrate writes a small program into the package directory that
+// imports the package's generated Schema and AppConfig, then runs it
+// with go run so the migration uses the package's own naming rules.
+func runMigrate(c *cli.Context) {
+	cfg, err := configFor(c)
+	if err != nil {
+		log.Fatal("Couldn't read config got error:", err)
+	}
+	driver, dsn := c.String("driver"), c.String("dsn")
+	if driver == "" && len(cfg.Dialects) > 0 {
+		driver = cfg.Dialects[0]
+	}
+	if driver == "" || dsn == "" {
+		log.Fatal("Both --driver and --dsn are required")
+	}
+	driverImport := c.String("driver-import")
+	if driverImport == "" {
+		driverImport = driverImports[driver]
+	}
+	if driverImport == "" {
+		log.Fatal("Unknown driver ", driver, ", use --driver-import to name its package")
+	}
+
+	pkgDir := "."
+	if cfg.Output.Dir != "" {
+		pkgDir = "./" + filepath.ToSlash(filepath.Clean(cfg.Output.Dir))
+	}
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", pkgDir).Output()
+	if err != nil {
+		log.Fatal("Couldn't find the import path for this package got error:", err)
+	}
+
+	dir, err := ioutil.TempDir(".", "_drmigrate")
+	if err != nil {
+		log.Fatal("Couldn't create migration program directory got error:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "main.go"))
+	if err != nil {
+		log.Fatal("Couldn't create migration program got error:", err)
+	}
+	m := parse.Migrator{
+		ImportPath:   strings.TrimSpace(string(out)),
+		DriverImport: driverImport,
+	}
+	err = m.Write(f)
+	f.Close()
+	if err != nil {
+		log.Fatal("Couldn't write migration program got error:", err)
+	}
+
+	args := []string{"run", "./" + filepath.Base(dir), "-driver", driver, "-dsn", dsn}
+	for _, flag := range []string{"dry-run", "prune", "status", "verbose"} {
+		if c.Bool(flag) {
+			args = append(args, "-"+flag)
+		}
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		os.RemoveAll(dir)
+		log.Fatal("Migration failed: ", err)
+	}
+}
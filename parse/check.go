@@ -0,0 +1,164 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CheckFiles compares generated files against the copies on disk, writing a
+// unified diff for each one that differs or is missing. It returns whether
+// every file was up to date.
+func CheckFiles(w io.Writer, files map[string][]byte) (bool, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	current := true
+	for _, name := range names {
+		onDisk, err := ioutil.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if bytes.Equal(onDisk, files[name]) {
+			continue
+		}
+		current = false
+		writeDiff(w, name, onDisk, files[name])
+	}
+	return current, nil
+}
+
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// writeDiff writes the changes from a to b in unified diff format
+func writeDiff(w io.Writer, name string, a, b []byte) {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	fmt.Fprintf(w, "--- %s\n+++ %s (generated)\n", name, name)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// extend the hunk while the changes are close enough to share context
+		end := start
+		for i := start; i < len(lines) && i <= end+2*diffContext; i++ {
+			if lines[i].op != ' ' {
+				end = i
+			}
+		}
+		from, to := start-diffContext, end+diffContext+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		aLine, bLine := 1, 1
+		for _, line := range lines[:from] {
+			if line.op != '+' {
+				aLine++
+			}
+			if line.op != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+		if aCount == 0 {
+			aLine--
+		}
+		if bCount == 0 {
+			bLine--
+		}
+
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, line := range lines[from:to] {
+			fmt.Fprintf(w, "%c%s\n", line.op, line.text)
+		}
+		start = to
+	}
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// diffLines finds the longest common subsequence of the lines left after
+// trimming the common prefix and suffix, and turns it into an edit script.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := []diffLine{}
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			lines = append(lines, diffLine{' ', ma[i]})
+			i++
+			j++
+		case j < len(mb) && (i == len(ma) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, diffLine{'+', mb[j]})
+			j++
+		default:
+			lines = append(lines, diffLine{'-', ma[i]})
+			i++
+		}
+	}
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
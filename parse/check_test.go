@@ -0,0 +1,48 @@
+package parse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	a := "package blog\n\nfunc A() {}\n\nfunc B() {}\n\nfunc C() {}\n\nfunc D() {}\n"
+	b := "package blog\n\nfunc A() {}\n\nfunc B2() {}\n\nfunc C() {}\n\nfunc D() {}\nfunc E() {}\n"
+	expected := "--- blog_gen.go\n+++ blog_gen.go (generated)\n" +
+		"@@ -2,8 +2,9 @@\n" +
+		" \n func A() {}\n \n-func B() {}\n+func B2() {}\n \n func C() {}\n \n func D() {}\n+func E() {}\n"
+
+	w := &bytes.Buffer{}
+	writeDiff(w, "blog_gen.go", []byte(a), []byte(b))
+	if w.String() != expected {
+		t.Fatalf("Expected:\n%s\nRecieved:\n%s", expected, w.String())
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	current := filepath.Join(dir, "current.go")
+	ioutil.WriteFile(current, []byte("package blog\n"), 0666)
+
+	w := &bytes.Buffer{}
+	ok, err := CheckFiles(w, map[string][]byte{current: []byte("package blog\n")})
+	if err != nil || !ok || w.Len() != 0 {
+		t.Fatalf("Expected current file to pass, got %v %v %s", ok, err, w.String())
+	}
+
+	missing := filepath.Join(dir, "missing.go")
+	ok, err = CheckFiles(w, map[string][]byte{missing: []byte("package blog\n")})
+	if err != nil || ok {
+		t.Fatalf("Expected missing file to fail, got %v %v", ok, err)
+	}
+	if !bytes.Contains(w.Bytes(), []byte("@@ -0,0 +1,1 @@\n+package blog\n")) {
+		t.Errorf("Unexpected diff for missing file:\n%s", w.String())
+	}
+}
@@ -0,0 +1,150 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config holds the project settings for the generator, read from a dr.toml
+// or dr.json file next to the .gp files.
+//
+//	naming = "rails"
+//	string_length = 100
+//	dialects = ["postgres", "sqlite3"]
+//	timestamps = true
+//	soft_delete = true
+//	templates = "templates"
+//
+//	[output]
+//	dir = "models"
+type Config struct {
+	// Naming is the SQLConfig a new db_config.go starts with, one of
+	// plain, lower or rails
+	Naming string `toml:"naming" json:"naming"`
+	// StringLength is the varchar length for strings without a length tag
+	StringLength int `toml:"string_length" json:"string_length"`
+	// Dialects are the database/sql driver names Open will accept, when
+	// empty every supported driver is accepted
+	Dialects []string `toml:"dialects" json:"dialects"`
+	// Timestamps adds CreatedAt and UpdatedAt columns to every table and
+	// keeps them current when records are saved
+	Timestamps bool `toml:"timestamps" json:"timestamps"`
+	// SoftDelete adds a DeletedAt column to every table, deleting sets it
+	// and scopes leave out deleted rows unless WithDeleted is used
+	SoftDelete bool `toml:"soft_delete" json:"soft_delete"`
+	// Templates is a directory of .tmpl files that replace built in
+	// templates or add files of their own to the generated code
+	Templates string `toml:"templates" json:"templates"`
+
+	Output Output `toml:"output" json:"output"`
+}
+
+// ConfigFiles are the names LoadConfig looks for, in order
+var ConfigFiles = []string{"dr.toml", "dr.json"}
+
+// LoadConfig reads the first config file found in dir, a missing file is
+// not an error and gives the zero Config.
+func LoadConfig(dir string) (Config, error) {
+	for _, name := range ConfigFiles {
+		filename := filepath.Join(dir, name)
+		if _, err := os.Stat(filename); err == nil {
+			return ReadConfig(filename)
+		}
+	}
+	return Config{}, nil
+}
+
+// ReadConfig reads a config file, the format is picked by its extension
+func ReadConfig(filename string) (Config, error) {
+	cfg := Config{}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch filepath.Ext(filename) {
+	case ".toml":
+		md, err := toml.Decode(string(b), &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %v", filename, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return cfg, fmt.Errorf("%s: unknown setting %s", filename, undecoded[0])
+		}
+	case ".json":
+		d := json.NewDecoder(strings.NewReader(string(b)))
+		d.DisallowUnknownFields()
+		err = d.Decode(&cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %v", filename, err)
+		}
+	default:
+		return cfg, fmt.Errorf("%s: config files must be .toml or .json", filename)
+	}
+
+	return cfg, cfg.Check()
+}
+
+// Check reports settings that have values the generator doesn't know
+func (cfg Config) Check() error {
+	switch cfg.Naming {
+	case "", "plain", "lower", "rails":
+	default:
+		return fmt.Errorf("naming must be one of plain, lower or rails, not %q", cfg.Naming)
+	}
+	if cfg.StringLength < 0 {
+		return fmt.Errorf("string_length must be positive, not %d", cfg.StringLength)
+	}
+	for _, dialect := range cfg.Dialects {
+		if canonicalDialect(dialect) == "" {
+			return fmt.Errorf("unknown dialect %q", dialect)
+		}
+	}
+	return nil
+}
+
+// NamingConfig is the Go expression for the SQLConfig chosen by Naming
+func (cfg Config) NamingConfig() string {
+	switch cfg.Naming {
+	case "lower":
+		return "LowerConfig{}"
+	case "rails":
+		return "RailsConfig{}"
+	}
+	return "nil"
+}
+
+// DriverNames lists every driver name accepted for the configured dialects
+func (cfg Config) DriverNames() []string {
+	names := []string{}
+	for _, dialect := range cfg.Dialects {
+		for _, name := range dialectDrivers[canonicalDialect(dialect)] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var dialectDrivers = map[string][]string{
+	"sqlite3":  {"sqlite3", "sqlite"},
+	"postgres": {"postgres", "pgx"},
+	"mysql":    {"mysql"},
+	"mssql":    {"mssql", "sqlserver"},
+}
+
+func canonicalDialect(name string) string {
+	for dialect, drivers := range dialectDrivers {
+		for _, driver := range drivers {
+			if driver == name {
+				return dialect
+			}
+		}
+	}
+	return ""
+}
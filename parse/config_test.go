@@ -0,0 +1,117 @@
+package parse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "drconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadConfig(t *testing.T) {
+	expected := Config{
+		Naming:       "rails",
+		StringLength: 100,
+		Dialects:     []string{"postgres", "sqlite3"},
+		Timestamps:   true,
+		SoftDelete:   true,
+		Output:       Output{Dir: "models"},
+	}
+
+	dir := writeConfig(t, "dr.toml", `
+naming = "rails"
+string_length = 100
+dialects = ["postgres", "sqlite3"]
+timestamps = true
+soft_delete = true
+
+[output]
+dir = "models"
+`)
+	defer os.RemoveAll(dir)
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Unexpected toml config %+v", cfg)
+	}
+
+	dir = writeConfig(t, "dr.json", `{
+	"naming": "rails",
+	"string_length": 100,
+	"dialects": ["postgres", "sqlite3"],
+	"timestamps": true,
+	"soft_delete": true,
+	"output": {"dir": "models"}
+}`)
+	defer os.RemoveAll(dir)
+	cfg, err = LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Unexpected json config %+v", cfg)
+	}
+
+	cfg, err = LoadConfig(os.TempDir())
+	if err != nil || !reflect.DeepEqual(cfg, Config{}) {
+		t.Errorf("Expected the zero config without a file, got %+v, %v", cfg, err)
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	files := map[string]string{
+		"dr.toml": `nameing = "rails"`,
+		"dr.json": `{"string_length": 10, "dialect": ["mysql"]}`,
+	}
+	for name, content := range files {
+		dir := writeConfig(t, name, content)
+		defer os.RemoveAll(dir)
+		if _, err := LoadConfig(dir); err == nil {
+			t.Errorf("Expected an error for the unknown setting in %s", name)
+		}
+	}
+
+	for _, cfg := range []Config{
+		{Naming: "camel"},
+		{StringLength: -1},
+		{Dialects: []string{"oracle"}},
+	} {
+		if cfg.Check() == nil {
+			t.Errorf("Expected %+v to fail the check", cfg)
+		}
+	}
+}
+
+func TestConfigValues(t *testing.T) {
+	names := map[string]string{
+		"":      "nil",
+		"plain": "nil",
+		"lower": "LowerConfig{}",
+		"rails": "RailsConfig{}",
+	}
+	for naming, expr := range names {
+		if (Config{Naming: naming}).NamingConfig() != expr {
+			t.Errorf("Expected %s naming to use %s", naming, expr)
+		}
+	}
+
+	cfg := Config{Dialects: []string{"pgx", "mssql"}}
+	drivers := []string{"postgres", "pgx", "mssql", "sqlserver"}
+	if !reflect.DeepEqual(cfg.DriverNames(), drivers) {
+		t.Errorf("Expected drivers %v, got %v", drivers, cfg.DriverNames())
+	}
+}
@@ -2,7 +2,7 @@ package example
 
 type User table {
   ID int
-  FirstName, LastName string `null="zero"`
+  FirstName, LastName string `null:"true"`
   Timestamps
 }
 
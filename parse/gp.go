@@ -0,0 +1,547 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// gpFile holds what a .gp file adds to Go: type declarations using table,
+// mixin or subrecord in place of struct, the relation and index blocks
+// inside them, and enum declarations. Everything else in the file is plain
+// Go.
+type gpFile struct {
+	Decls []*gpDecl
+}
+
+func (f *gpFile) Decl(name string) *gpDecl {
+	for _, decl := range f.Decls {
+		if decl.Name == name {
+			return decl
+		}
+	}
+	return nil
+}
+
+type gpDecl struct {
+	// Kind is one of table, mixin, subrecord or enum
+	Kind string
+	Name string
+	// Parent is the table of a variant, "type Announcement table Post"
+	Parent    string
+	Relations []gpRelation
+	Indexes   []gpIndex
+	Values    []gpEnumValue
+}
+
+// gpRelation is a line of a relation block, "Alias []Table `tag`" with
+// everything but the table optional.
+type gpRelation struct {
+	Alias, Table string
+	IsArray      bool
+	Tag          string
+	Pos          token.Position
+}
+
+// gpEnumValue is a line of an enum, a name followed by an optional string
+// when the value is stored as something other than its name.
+type gpEnumValue struct {
+	Name, Stored string
+	Pos          token.Position
+}
+
+// gpIndex is a line of an index block,
+// "unique Column, lower(Column) desc where condition `tag`" with everything
+// but the first column optional. Columns holds names or SQL expressions,
+// and Desc whether each of them is sorted descending.
+type gpIndex struct {
+	Unique  bool
+	Columns []string
+	Desc    []bool
+	Where   string
+	Tag     string
+	Pos     token.Position
+}
+
+// parseGP reads the .gp declarations out of src and rewrites it into Go,
+// the kind keywords become struct and the relation and index blocks are
+// blanked out. Line breaks are kept, and the returned sourceMap maps
+// positions in the rewritten source back onto src.
+func parseGP(filename string, src []byte) (*gpFile, []byte, *sourceMap, error) {
+	p := &gpParser{
+		file:   token.NewFileSet().AddFile(filename, -1, len(src)),
+		src:    src,
+		result: &gpFile{},
+	}
+	p.scan.Init(p.file, src, func(pos token.Position, msg string) {
+		if !p.quiet {
+			p.errs.Add(pos, msg)
+		}
+	}, 0)
+	p.next()
+
+	for p.tok != token.EOF {
+		if p.tok == token.TYPE {
+			p.next()
+			p.typeDecl()
+			continue
+		}
+		p.next()
+	}
+
+	if len(p.errs) > 0 {
+		p.errs.Sort()
+		return nil, nil, nil, p.errs
+	}
+	out, sources := p.rewrite()
+	return p.result, out, sources, nil
+}
+
+type gpParser struct {
+	file   *token.File
+	src    []byte
+	scan   scanner.Scanner
+	errs   scanner.ErrorList
+	result *gpFile
+	edits  []gpEdit
+
+	pos token.Pos
+	tok token.Token
+	lit string
+
+	// quiet drops scanner errors while passing over SQL in index blocks
+	quiet bool
+}
+
+// gpEdit replaces length bytes of the source at offset with text
+type gpEdit struct {
+	offset, length int
+	text           string
+}
+
+func (p *gpParser) next() {
+	p.pos, p.tok, p.lit = p.scan.Scan()
+}
+
+// skipNewlines passes over the semicolons the scanner inserts at the end of
+// lines, so braces can be on the line after the declaration.
+func (p *gpParser) skipNewlines() {
+	for p.tok == token.SEMICOLON && p.lit == "\n" {
+		p.next()
+	}
+}
+
+// skipEntry passes over the rest of a bad relation or index line
+func (p *gpParser) skipEntry() {
+	for p.tok != token.SEMICOLON && p.tok != token.RBRACE && p.tok != token.EOF {
+		p.next()
+	}
+}
+
+func (p *gpParser) error(pos token.Pos, format string, args ...interface{}) {
+	p.errs.Add(p.file.Position(pos), fmt.Sprintf(format, args...))
+}
+
+func (p *gpParser) unexpected(where string) {
+	lit := p.lit
+	if p.tok == token.SEMICOLON && lit == "\n" {
+		lit = "newline"
+	} else if lit == "" {
+		lit = p.tok.String()
+	}
+	p.error(p.pos, "unexpected %s in %s", lit, where)
+}
+
+func (p *gpParser) offset(pos token.Pos) int {
+	return p.file.Offset(pos)
+}
+
+// typeDecl handles a type declaration after the type keyword, only
+// declarations of a .gp kind are recorded and rewritten.
+func (p *gpParser) typeDecl() {
+	if p.tok != token.IDENT {
+		return
+	}
+	name, namePos := p.lit, p.pos
+	p.next()
+	if p.tok != token.IDENT {
+		return
+	}
+	switch p.lit {
+	case "table", "mixin", "subrecord":
+	case "enum":
+		p.enum(name)
+		return
+	default:
+		return
+	}
+
+	decl := &gpDecl{Kind: p.lit, Name: name}
+	kind := gpEdit{p.offset(p.pos), len(p.lit), "struct"}
+	p.next()
+	if decl.Kind == "table" && p.tok == token.IDENT {
+		// a variant is declared as the parent's struct under its own name
+		decl.Parent = p.lit
+		kind.text = strings.Repeat(" ", kind.length)
+		p.edits = append(p.edits, kind)
+		p.result.Decls = append(p.result.Decls, decl)
+		p.next()
+		return
+	}
+	p.edits = append(p.edits, kind)
+	p.skipNewlines()
+	if p.tok != token.LBRACE {
+		p.error(p.pos, "expected { to start %s %s", decl.Kind, name)
+		return
+	}
+	p.result.Decls = append(p.result.Decls, decl)
+	p.body(decl, namePos)
+}
+
+// enum reads the values of an enum, which becomes an int with the body
+// blanked out, the constants and methods are generated.
+func (p *gpParser) enum(name string) {
+	decl := &gpDecl{Kind: "enum", Name: name}
+	p.edits = append(p.edits, gpEdit{p.offset(p.pos), len(p.lit), "int"})
+	p.next()
+	p.skipNewlines()
+	if p.tok != token.LBRACE {
+		p.error(p.pos, "expected { to start enum %s", name)
+		return
+	}
+	start := p.pos
+	p.next()
+	for {
+		for p.tok == token.SEMICOLON {
+			p.next()
+		}
+		if p.tok == token.RBRACE || p.tok == token.EOF {
+			break
+		}
+
+		v := gpEnumValue{Pos: p.file.Position(p.pos)}
+		if p.tok != token.IDENT {
+			p.unexpected("enum")
+			p.skipEntry()
+			continue
+		}
+		v.Name = p.lit
+		p.next()
+		if !p.tag(&v.Stored, "enum") {
+			continue
+		}
+		decl.Values = append(decl.Values, v)
+	}
+	if p.tok != token.RBRACE {
+		p.error(p.pos, "enum %s is not closed", name)
+		return
+	}
+	p.result.Decls = append(p.result.Decls, decl)
+	p.blank(start)
+}
+
+func (p *gpParser) body(decl *gpDecl, namePos token.Pos) {
+	depth := 0
+	lineStart := false
+	for p.tok != token.EOF {
+		switch p.tok {
+		case token.LBRACE:
+			depth++
+			lineStart = depth == 1
+			p.next()
+			continue
+		case token.RBRACE:
+			depth--
+			if depth == 0 {
+				p.next()
+				return
+			}
+		case token.SEMICOLON:
+			lineStart = true
+			p.next()
+			continue
+		case token.IDENT:
+			if depth == 1 && lineStart && (p.lit == "relation" || p.lit == "index") {
+				start, block := p.pos, p.lit
+				p.next()
+				p.skipNewlines()
+				if p.tok == token.LBRACE {
+					if block == "relation" {
+						p.relations(decl)
+					} else {
+						p.indexes(decl)
+					}
+					p.blank(start)
+					lineStart = false
+					continue
+				}
+				p.error(start, "expected { to start %s block in %s", block, decl.Name)
+				continue
+			}
+		}
+		lineStart = false
+		p.next()
+	}
+	p.error(namePos, "%s %s is not closed", decl.Kind, decl.Name)
+}
+
+// blank replaces the source from start up to the current token with spaces,
+// keeping line breaks so the lines of the rewritten source still match.
+func (p *gpParser) blank(start token.Pos) {
+	from, to := p.offset(start), p.offset(p.pos)
+	if p.tok == token.RBRACE {
+		to++
+		p.next()
+	}
+	text := make([]byte, to-from)
+	for i, b := range p.src[from:to] {
+		if b == '\n' {
+			text[i] = '\n'
+		} else {
+			text[i] = ' '
+		}
+	}
+	p.edits = append(p.edits, gpEdit{from, to - from, string(text)})
+}
+
+func (p *gpParser) relations(decl *gpDecl) {
+	p.next()
+	for {
+		for p.tok == token.SEMICOLON {
+			p.next()
+		}
+		if p.tok == token.RBRACE || p.tok == token.EOF {
+			break
+		}
+
+		r := gpRelation{Pos: p.file.Position(p.pos)}
+		if p.tok == token.IDENT {
+			first := p.lit
+			p.next()
+			if p.tok == token.IDENT || p.tok == token.LBRACK {
+				r.Alias = first
+			} else {
+				r.Table = first
+			}
+		}
+		if r.Table == "" {
+			if p.tok == token.LBRACK {
+				p.next()
+				if p.tok != token.RBRACK {
+					p.unexpected("relation")
+					p.skipEntry()
+					continue
+				}
+				r.IsArray = true
+				p.next()
+			}
+			if p.tok != token.IDENT {
+				p.unexpected("relation")
+				p.skipEntry()
+				continue
+			}
+			r.Table = p.lit
+			p.next()
+		}
+		if !p.tag(&r.Tag, "relation") {
+			continue
+		}
+		decl.Relations = append(decl.Relations, r)
+	}
+	if p.tok != token.RBRACE {
+		p.error(p.pos, "relation block in %s is not closed", decl.Name)
+	}
+}
+
+// indexes reads the lines of an index block from the source rather than
+// the scanner, as expressions and where conditions are SQL, not Go.
+func (p *gpParser) indexes(decl *gpDecl) {
+	p.next()
+	for {
+		for p.tok == token.SEMICOLON {
+			p.next()
+		}
+		if p.tok == token.RBRACE || p.tok == token.EOF {
+			break
+		}
+
+		start := p.offset(p.pos)
+		end := indexLineEnd(p.src, start)
+		ix, at, err := parseIndexLine(string(p.src[start:end]))
+		if err != nil {
+			p.error(p.file.Pos(start+at), "%v", err)
+		}
+		ix.Pos = p.file.Position(p.pos)
+
+		p.quiet = true
+		for p.tok != token.EOF && p.offset(p.pos) < end {
+			p.next()
+		}
+		p.quiet = false
+		if err == nil {
+			decl.Indexes = append(decl.Indexes, ix)
+		}
+	}
+	if p.tok != token.RBRACE {
+		p.error(p.pos, "index block in %s is not closed", decl.Name)
+	}
+}
+
+// indexLineEnd finds the end of the index line starting at start, which is
+// the end of the line, a comment or the } closing the block
+func indexLineEnd(src []byte, start int) int {
+	depth, quote := 0, byte(0)
+	for i := start; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'', c == '"', c == '`':
+			quote = c
+		case c == '\n':
+			return i
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			return i
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '}' && depth <= 0:
+			return i
+		}
+	}
+	return len(src)
+}
+
+// parseIndexLine reads a line of an index block, errors come with the
+// offset in the line they were found at
+func parseIndexLine(line string) (gpIndex, int, error) {
+	ix := gpIndex{}
+	text := strings.TrimRight(line, " \t;")
+	if strings.HasSuffix(text, "`") {
+		open := strings.LastIndex(text[:len(text)-1], "`")
+		if open < 0 {
+			return ix, len(text) - 1, fmt.Errorf("bad tag in index")
+		}
+		ix.Tag = text[open+1 : len(text)-1]
+		text = text[:open]
+	}
+	offset := 0
+	if fields := strings.Fields(text); len(fields) > 1 && fields[0] == "unique" {
+		ix.Unique = true
+		offset = len(text) - len(strings.TrimLeft(text[len("unique"):], " \t"))
+	}
+
+	depth, quote, start := 0, byte(0), offset
+	column := func(end int) error {
+		col := strings.TrimSpace(text[start:end])
+		if col == "" {
+			return fmt.Errorf("missing column in index")
+		}
+		desc := false
+		if fields := strings.Fields(col); len(fields) > 1 {
+			switch strings.ToLower(fields[len(fields)-1]) {
+			case "desc":
+				desc = true
+				fallthrough
+			case "asc":
+				col = strings.TrimSpace(col[:len(col)-len(fields[len(fields)-1])])
+			}
+		}
+		ix.Columns = append(ix.Columns, col)
+		ix.Desc = append(ix.Desc, desc)
+		return nil
+	}
+	for i := offset; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+			if depth < 0 {
+				return ix, i, fmt.Errorf("unexpected %c in index", c)
+			}
+		case depth == 0 && c == ',':
+			if err := column(i); err != nil {
+				return ix, i, err
+			}
+			start = i + 1
+		case depth == 0 && isIndexWhere(text, start, i):
+			if err := column(i); err != nil {
+				return ix, i, err
+			}
+			ix.Where = strings.TrimSpace(text[i+len("where"):])
+			if ix.Where == "" {
+				return ix, i, fmt.Errorf("missing condition after where in index")
+			}
+			return ix, 0, nil
+		}
+	}
+	if quote != 0 || depth > 0 {
+		return ix, len(text), fmt.Errorf("index is not closed")
+	}
+	if err := column(len(text)); err != nil {
+		return ix, len(text), err
+	}
+	return ix, 0, nil
+}
+
+// isIndexWhere is whether the keyword where starts at i, it has to follow
+// the column started at start, otherwise it's a column named Where
+func isIndexWhere(text string, start, i int) bool {
+	if strings.TrimSpace(text[start:i]) == "" || text[i-1] != ' ' && text[i-1] != '\t' || len(text) < i+len("where") {
+		return false
+	}
+	end := i + len("where")
+	return strings.EqualFold(text[i:end], "where") && (end == len(text) || text[end] == ' ' || text[end] == '\t')
+}
+
+// tag reads an optional tag and the end of the line, reporting anything
+// else found on the line.
+func (p *gpParser) tag(tag *string, where string) bool {
+	if p.tok == token.STRING {
+		s, err := strconv.Unquote(p.lit)
+		if err != nil {
+			p.error(p.pos, "bad tag in %s", where)
+		}
+		*tag = s
+		p.next()
+	}
+	if p.tok != token.SEMICOLON && p.tok != token.RBRACE {
+		p.unexpected(where)
+		p.skipEntry()
+		return false
+	}
+	return true
+}
+
+func (p *gpParser) rewrite() ([]byte, *sourceMap) {
+	b := &bytes.Buffer{}
+	sources := &sourceMap{file: p.file}
+	last := 0
+	for _, edit := range p.edits {
+		b.Write(p.src[last:edit.offset])
+		sources.edits = append(sources.edits, sourceEdit{
+			out:     b.Len(),
+			outLen:  len(edit.text),
+			orig:    edit.offset,
+			origLen: edit.length,
+		})
+		b.WriteString(edit.text)
+		last = edit.offset + edit.length
+	}
+	b.Write(p.src[last:])
+	return b.Bytes(), sources
+}
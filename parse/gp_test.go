@@ -0,0 +1,157 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"strings"
+	"testing"
+)
+
+func TestParseGPLayouts(t *testing.T) {
+	src := `package example
+
+type User table // users of the site
+{
+  ID int
+  Name string
+
+  relation{
+    []Post
+    Editor []Post ` + "`onDelete:\"cascade\"`" + `
+  }
+  index
+  {
+    Name, ID // lookups
+  }
+}
+
+type Post table { // posts
+  ID int
+  UserID, EditorID int
+  relation { User; Editor User }
+}
+
+type Timestamps mixin {
+  CreatedAt time.Time
+}
+
+type Level enum {
+  Low; Medium
+  High "high"
+}
+`
+	gp, out, _, err := parseGP("example.gp", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(out, []byte("\n")) != bytes.Count([]byte(src), []byte("\n")) {
+		t.Errorf("Rewritten source changed line count:\n%s", out)
+	}
+	if bytes.Contains(out, []byte("relation")) || bytes.Contains(out, []byte(" table")) || bytes.Contains(out, []byte("Medium")) {
+		t.Errorf("Rewritten source still has .gp syntax:\n%s", out)
+	}
+
+	if len(gp.Decls) != 4 {
+		t.Fatalf("Expected 4 declarations, got %d", len(gp.Decls))
+	}
+	user := gp.Decl("User")
+	if user.Kind != "table" || len(user.Relations) != 2 || len(user.Indexes) != 1 {
+		t.Fatalf("User parsed as %+v", user)
+	}
+	editor := user.Relations[1]
+	if editor.Alias != "Editor" || editor.Table != "Post" || !editor.IsArray || editor.Tag != `onDelete:"cascade"` {
+		t.Errorf("Editor relation parsed as %+v", editor)
+	}
+	if editor.Pos.Line != 10 || editor.Pos.Column != 5 {
+		t.Errorf("Editor relation at %v", editor.Pos)
+	}
+	if ix := user.Indexes[0]; len(ix.Columns) != 2 || ix.Columns[0] != "Name" || ix.Columns[1] != "ID" {
+		t.Errorf("Index parsed as %+v", ix)
+	}
+
+	post := gp.Decl("Post")
+	if len(post.Relations) != 2 || post.Relations[1].Alias != "Editor" || post.Relations[1].Table != "User" {
+		t.Errorf("Post relations parsed as %+v", post.Relations)
+	}
+	if gp.Decl("Timestamps").Kind != "mixin" {
+		t.Errorf("Timestamps parsed as %+v", gp.Decl("Timestamps"))
+	}
+	level := gp.Decl("Level")
+	if len(level.Values) != 3 || level.Values[1].Name != "Medium" || level.Values[2].Stored != "high" {
+		t.Errorf("Level parsed as %+v", level)
+	}
+	if !bytes.Contains(out, []byte("type Level int")) {
+		t.Errorf("Level wasn't rewritten to an int:\n%s", out)
+	}
+}
+
+func TestParseGPErrors(t *testing.T) {
+	src := "package example\n\ntype User table {\n  relation {\n    []\n    Post Post Post\n  }\n"
+	_, _, _, err := parseGP("example.gp", []byte(src))
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatal("Expected an ErrorList, got:", err)
+	}
+
+	expected := []string{
+		"example.gp:3:6: table User is not closed",
+		"example.gp:5:7: unexpected newline in relation",
+		"example.gp:6:15: unexpected Post in relation",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %d errors, got: %v", len(expected), list)
+	}
+	for i, e := range list {
+		if e.Error() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], e.Error())
+		}
+	}
+}
+
+func TestParseGPIndexes(t *testing.T) {
+	src := "package example\n\ntype User table {\n  ID int\n  index {\n" +
+		"    unique Email\n" +
+		"    Name, CreatedAt desc `name:\"idx_recent\"`\n" +
+		"    lower(Email), coalesce(Name, ',') where DeletedAt IS NULL AND Name <> 'it''s' // partial\n" +
+		"  }\n}\n"
+	gp, _, _, err := parseGP("example.gp", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := gp.Decl("User").Indexes
+	if len(indexes) != 3 {
+		t.Fatalf("Expected 3 indexes, got %+v", indexes)
+	}
+	if ix := indexes[0]; !ix.Unique || len(ix.Columns) != 1 || ix.Columns[0] != "Email" {
+		t.Errorf("Unique index parsed as %+v", ix)
+	}
+	if ix := indexes[1]; len(ix.Columns) != 2 || ix.Columns[1] != "CreatedAt" || ix.Desc[0] || !ix.Desc[1] || ix.Tag != `name:"idx_recent"` {
+		t.Errorf("Sorted index parsed as %+v", ix)
+	}
+	ix := indexes[2]
+	if len(ix.Columns) != 2 || ix.Columns[0] != "lower(Email)" || ix.Columns[1] != "coalesce(Name, ',')" {
+		t.Errorf("Expression index parsed as %+v", ix)
+	}
+	if ix.Where != "DeletedAt IS NULL AND Name <> 'it''s'" || ix.Pos.Line != 8 {
+		t.Errorf("Partial index parsed as %+v", ix)
+	}
+
+	_, _, _, err = parseGP("example.gp", []byte("package example\n\ntype User table {\n  ID int\n  index {\n    Name where\n  }\n}\n"))
+	if err == nil || !strings.Contains(err.Error(), "6:10: missing condition after where in index") {
+		t.Error("Expected a missing condition error, got", err)
+	}
+
+	for line, expected := range map[string]gpIndex{
+		"unique\tEmail":                 {Unique: true, Columns: []string{"Email"}, Desc: []bool{false}},
+		"unique Where":                  {Unique: true, Columns: []string{"Where"}, Desc: []bool{false}},
+		"Name, Where desc":              {Columns: []string{"Name", "Where"}, Desc: []bool{false, true}},
+		"Where where Where IS NOT NULL": {Columns: []string{"Where"}, Desc: []bool{false}, Where: "Where IS NOT NULL"},
+	} {
+		ix, _, err := parseIndexLine(line)
+		if err != nil || fmt.Sprint(ix) != fmt.Sprint(expected) {
+			t.Errorf("%q parsed as %+v, %v", line, ix, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/acsellers/dr/migrate"
+	"github.com/acsellers/dr/schema"
+	"github.com/acsellers/inflections"
+)
+
+// WriteIntrospected writes table definitions for tables read out of an
+// existing database. Names are converted back from the Rails conventions,
+// so a package built with naming = "rails" maps them onto the original
+// tables, the default plain naming doesn't.
+func WriteIntrospected(w io.Writer, pkgName, source string, tables []migrate.IntrospectedTable) error {
+	names := map[string]string{}
+	for _, table := range tables {
+		names[table.Name] = GoTableName(table.Name)
+	}
+
+	children := map[string][]string{}
+	for _, table := range tables {
+		for _, fk := range table.ForeignKeys {
+			parent, ok := names[fk.ParentTable]
+			if !ok {
+				continue
+			}
+			alias := strings.TrimSuffix(GoColumnName(fk.Column), "ID")
+			if fk.ParentTable == table.Name {
+				// the alias names the parent side of a self reference, the
+				// children are named apart from it
+				children[fk.ParentTable] = append(children[fk.ParentTable], fmt.Sprintf(
+					"%s%s []%s `column:%q`",
+					alias, inflections.Pluralize(parent), parent, GoColumnName(fk.Column),
+				))
+			} else if alias == parent {
+				children[fk.ParentTable] = append(children[fk.ParentTable], "[]"+names[table.Name])
+			} else {
+				children[fk.ParentTable] = append(children[fk.ParentTable], alias+" []"+names[table.Name])
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "package %s\n\n", pkgName)
+	fmt.Fprintf(w, "// Introspected from %s, set naming = \"rails\" in dr.toml before the first\n", source)
+	fmt.Fprintf(w, "// dr build so these tables and columns map back onto the existing database.\n")
+
+	for _, table := range tables {
+		fmt.Fprintf(w, "\ntype %s table {\n", names[table.Name])
+		for _, col := range table.Columns {
+			goType, tag := goTypeFor(col)
+			if col.Null && goType != "[]byte" && col.Type != "json" {
+				goType = "*" + goType
+			}
+			if tag != "" {
+				fmt.Fprintf(w, "\t%s %s `%s`\n", GoColumnName(col.Name), goType, tag)
+			} else {
+				fmt.Fprintf(w, "\t%s %s\n", GoColumnName(col.Name), goType)
+			}
+		}
+
+		relations := []string{}
+		for _, fk := range table.ForeignKeys {
+			parent, ok := names[fk.ParentTable]
+			if !ok {
+				continue
+			}
+			alias := strings.TrimSuffix(GoColumnName(fk.Column), "ID")
+			if alias == parent {
+				relations = append(relations, parent+keyActionTag(fk))
+			} else {
+				relations = append(relations, alias+" "+parent+keyActionTag(fk))
+			}
+		}
+		relations = append(relations, children[table.Name]...)
+		if len(relations) > 0 {
+			sort.Strings(relations)
+			fmt.Fprintf(w, "\n\trelation {\n")
+			for _, relation := range relations {
+				fmt.Fprintf(w, "\t\t%s\n", relation)
+			}
+			fmt.Fprintf(w, "\t}\n")
+		}
+
+		if len(table.Index) > 0 || len(table.Unique) > 0 {
+			fmt.Fprintf(w, "\n\tindex {\n")
+			for _, index := range table.Index {
+				fmt.Fprintf(w, "\t\t%s\n", goColumnList(index.Columns))
+			}
+			for _, index := range table.Unique {
+				fmt.Fprintf(w, "\t\tunique %s\n", goColumnList(index.Columns))
+			}
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return nil
+}
+
+// GoTableName turns a table name like blog_posts into BlogPost
+func GoTableName(table string) string {
+	return GoColumnName(inflections.Singularize(table))
+}
+
+// GoColumnName turns a column name like author_id into AuthorID
+func GoColumnName(column string) string {
+	parts := strings.FieldsFunc(column, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+	for i, part := range parts {
+		switch lower := strings.ToLower(part); lower {
+		case "id", "url", "api", "html", "json", "ip", "uuid":
+			parts[i] = strings.ToUpper(lower)
+		default:
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// keyActionTag is the relation tag for the actions of an introspected
+// foreign key, actions the database takes by default are left out
+func keyActionTag(fk migrate.ForeignKey) string {
+	tags := []string{}
+	for _, action := range [][2]string{{"onDelete", fk.OnDelete}, {"onUpdate", fk.OnUpdate}} {
+		value := strings.ToLower(action[1])
+		if value != "" && value != "no action" && value != "restrict" {
+			tags = append(tags, fmt.Sprintf("%s:%q", action[0], value))
+		}
+	}
+	if fk.Deferrable {
+		tags = append(tags, `deferrable:"true"`)
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return " `" + strings.Join(tags, " ") + "`"
+}
+
+func goColumnList(columns []string) string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		// expressions are written out as the database has them
+		if strings.ContainsAny(column, "( ") {
+			names[i] = column
+			continue
+		}
+		names[i] = GoColumnName(column)
+	}
+	return strings.Join(names, ", ")
+}
+
+func goTypeFor(col schema.Column) (string, string) {
+	switch col.Type {
+	case "smallint":
+		if col.Unsigned {
+			return "uint16", ""
+		}
+		return "int16", ""
+	case "integer":
+		if col.Unsigned {
+			return "uint32", ""
+		}
+		return "int", ""
+	case "bigint":
+		if col.Unsigned {
+			return "uint64", ""
+		}
+		return "int64", ""
+	case "varchar":
+		if col.Length != 0 && col.Length != 255 {
+			return "string", fmt.Sprintf(`length:"%d"`, col.Length)
+		}
+		return "string", ""
+	case "text":
+		return "string", `type:"text"`
+	case "timestamp":
+		return "time.Time", ""
+	case "real":
+		return "float32", ""
+	case "double precision":
+		return "float64", ""
+	case "boolean":
+		return "bool", ""
+	case "blob":
+		return "[]byte", ""
+	case "json":
+		return "map[string]interface{}", `type:"json"`
+	case "decimal":
+		return "string", fmt.Sprintf(`type:"decimal" precision:"%d" scale:"%d"`, col.Precision, col.Scale)
+	}
+	return "string", fmt.Sprintf(`type:"%s"`, col.Type)
+}
@@ -0,0 +1,93 @@
+package parse
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/acsellers/dr/migrate"
+	"github.com/acsellers/dr/schema"
+)
+
+func TestWriteIntrospected(t *testing.T) {
+	expected := "package blog\n\n" +
+		"// Introspected from sqlite3, set naming = \"rails\" in dr.toml before the first\n" +
+		"// dr build so these tables and columns map back onto the existing database.\n" +
+		"\ntype Post table {\n" +
+		"\tID int\n" +
+		"\tAuthorID int\n" +
+		"\tTitle string `length:\"100\"`\n" +
+		"\tParentID *int\n" +
+		"\n\trelation {\n" +
+		"\t\tAuthor User `onDelete:\"cascade\"`\n" +
+		"\t\tParent Post\n" +
+		"\t\tParentPosts []Post `column:\"ParentID\"`\n" +
+		"\t}\n" +
+		"\n\tindex {\n" +
+		"\t\tAuthorID, Title\n" +
+		"\t}\n" +
+		"}\n" +
+		"\ntype User table {\n" +
+		"\tID int\n" +
+		"\tEmail string\n" +
+		"\n\trelation {\n" +
+		"\t\tAuthor []Post\n" +
+		"\t}\n" +
+		"\n\tindex {\n" +
+		"\t\tunique Email\n" +
+		"\t}\n" +
+		"}\n"
+
+	post := migrate.IntrospectedTable{
+		Table: schema.Table{
+			Name: "posts",
+			Columns: []schema.Column{
+				schema.Column{Name: "id", Type: "integer"},
+				schema.Column{Name: "author_id", Type: "integer"},
+				schema.Column{Name: "title", Type: "varchar", Length: 100},
+				schema.Column{Name: "parent_id", Type: "integer", Null: true},
+			},
+			Index: []schema.Index{
+				schema.Index{Columns: []string{"author_id", "title"}},
+			},
+		},
+		ForeignKeys: []migrate.ForeignKey{
+			migrate.ForeignKey{Column: "author_id", ParentTable: "users", ParentColumn: "id", OnDelete: "CASCADE", OnUpdate: "NO ACTION"},
+			migrate.ForeignKey{Column: "parent_id", ParentTable: "posts", ParentColumn: "id"},
+		},
+	}
+	user := migrate.IntrospectedTable{
+		Table: schema.Table{
+			Name: "users",
+			Columns: []schema.Column{
+				schema.Column{Name: "id", Type: "integer"},
+				schema.Column{Name: "email", Type: "varchar", Length: 255},
+			},
+		},
+		Unique: []schema.Index{
+			schema.Index{Columns: []string{"email"}, Unique: true},
+		},
+	}
+
+	b := &bytes.Buffer{}
+	err := WriteIntrospected(b, "blog", "sqlite3", []migrate.IntrospectedTable{post, user})
+	if err != nil {
+		t.Fatal("Received error:", err)
+	}
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\n\nRecieved:\n%s", expected, b.String())
+	}
+}
+
+func TestGoNames(t *testing.T) {
+	names := map[string]string{
+		"author_id":  "AuthorID",
+		"first_name": "FirstName",
+		"image_url":  "ImageURL",
+		"Name":       "Name",
+	}
+	for column, expected := range names {
+		if GoColumnName(column) != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, column, GoColumnName(column))
+		}
+	}
+}
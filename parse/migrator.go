@@ -0,0 +1,90 @@
+package parse
+
+import "io"
+
+// Migrator describes the throwaway program dr migrate builds to run the
+// migrate package against a package's generated Schema.
+type Migrator struct {
+	ImportPath   string
+	DriverImport string
+}
+
+func (m Migrator) Write(w io.Writer) error {
+	return tmpl.ExecuteTemplate(w, "migrator", m)
+}
+
+var migratorTemplate = `package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/acsellers/dr/migrate"
+	{{ if .DriverImport }}_ "{{ .DriverImport }}"{{ end }}
+	models "{{ .ImportPath }}"
+)
+
+func main() {
+	driver := flag.String("driver", "", "database/sql driver name")
+	dsn := flag.String("dsn", "", "data source name for the driver")
+	dryRun := flag.Bool("dry-run", false, "print the statements instead of running them")
+	prune := flag.Bool("prune", false, "remove tables and columns not in the schema")
+	status := flag.Bool("status", false, "report the tables that need migrating")
+	verbose := flag.Bool("verbose", false, "log migration progress")
+	flag.Parse()
+
+	conn, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		log.Fatal("Couldn't open database got error: ", err)
+	}
+	defer conn.Close()
+
+	d := migrate.Database{
+		DB:         conn,
+		Schema:     models.Schema,
+		Translator: models.NewAppConfig(*driver),
+		DBMS:       migrate.SystemFor(*driver),
+		Prune:      *prune,
+		Log:        log.New(ioutil.Discard, "", 0),
+	}
+	if *verbose {
+		d.Log = log.New(os.Stderr, "migrate: ", 0)
+	}
+
+	switch {
+	case *status:
+		d.SetAlterer()
+		current, err := d.UpToDate()
+		if err != nil {
+			log.Fatal("Couldn't check database got error: ", err)
+		}
+		if current {
+			fmt.Println("Database is up to date")
+			return
+		}
+		for _, table := range d.NewTables {
+			fmt.Println("New table:", table.Name)
+		}
+		for _, table := range d.ModifiedTables {
+			fmt.Println("Modified table:", table.Name)
+		}
+	case *dryRun:
+		statements, err := d.Plan()
+		if err != nil {
+			log.Fatal("Couldn't plan migration got error: ", err)
+		}
+		for _, statement := range statements {
+			fmt.Println(statement + ";")
+		}
+	default:
+		err = d.Migrate()
+		if err != nil {
+			log.Fatal("Couldn't migrate got error: ", err)
+		}
+	}
+}
+`
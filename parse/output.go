@@ -0,0 +1,66 @@
+package parse
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Output controls where generated files are written and what they're
+// named. In the patterns {file} is replaced with the .gp file's name less
+// its extension, {pkg} with the package name and {kind} with one of gen,
+// schema or lib.
+type Output struct {
+	// Dir defaults to the current directory
+	Dir string `toml:"dir" json:"dir"`
+	// DefFile names the Go file written for each .gp file
+	DefFile string `toml:"def_file" json:"def_file"`
+	// PackageFile names the files generated once for the package
+	PackageFile string `toml:"package_file" json:"package_file"`
+}
+
+const (
+	DefaultDefFile     = "{file}_def.go"
+	DefaultPackageFile = "{pkg}_{kind}.go"
+)
+
+func (o Output) defFile(src string) string {
+	pattern := o.DefFile
+	if pattern == "" {
+		pattern = DefaultDefFile
+	}
+	name := strings.TrimSuffix(filepath.Base(src), ".gp")
+	return filepath.Join(o.Dir, strings.Replace(pattern, "{file}", name, -1))
+}
+
+func (o Output) packageFile(pkg, kind string) string {
+	pattern := o.PackageFile
+	if pattern == "" {
+		pattern = DefaultPackageFile
+	}
+	name := strings.NewReplacer("{pkg}", pkg, "{kind}", kind).Replace(pattern)
+	return filepath.Join(o.Dir, name)
+}
+
+// InSourceDir is whether the files are written beside the .gp files, the
+// only place the lib file's go:generate dr build can run from
+func (o Output) InSourceDir() bool {
+	return filepath.Clean(o.Dir) == "."
+}
+
+func (o Output) configFile() string {
+	return filepath.Join(o.Dir, "db_config.go")
+}
+
+// generatedHeader starts every generated file so Go tools and reviewers
+// know to edit the .gp sources instead.
+func generatedHeader(sources ...string) []byte {
+	if len(sources) == 0 {
+		return []byte("// Code generated by dr. DO NOT EDIT.\n\n")
+	}
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = filepath.Base(source)
+	}
+	return []byte(fmt.Sprintf("// Code generated by dr from %s. DO NOT EDIT.\n\n", strings.Join(names, ", ")))
+}
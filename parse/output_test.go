@@ -0,0 +1,51 @@
+package parse
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOutputNames(t *testing.T) {
+	o := Output{}
+	if o.defFile("blog.gp") != "blog_def.go" || o.packageFile("blog", "gen") != "blog_gen.go" {
+		t.Errorf("Unexpected default names %s and %s", o.defFile("blog.gp"), o.packageFile("blog", "gen"))
+	}
+
+	o = Output{Dir: "models", DefFile: "{file}.gen.go", PackageFile: "dr_{kind}.go"}
+	if o.defFile("blog.gp") != filepath.Join("models", "blog.gen.go") {
+		t.Errorf("Unexpected def file %s", o.defFile("blog.gp"))
+	}
+	if o.packageFile("blog", "schema") != filepath.Join("models", "dr_schema.go") {
+		t.Errorf("Unexpected package file %s", o.packageFile("blog", "schema"))
+	}
+	if o.configFile() != filepath.Join("models", "db_config.go") {
+		t.Errorf("Unexpected config file %s", o.configFile())
+	}
+
+	pkg, err := parseString(t, "package blog\n\ntype User table {\n  ID int\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dir, generate := range map[string]bool{"": true, "./": true, "models": false} {
+		pkg.Config.Output.Dir = dir
+		b, err := pkg.render("lib", "blog_lib.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), "//go:generate dr build") != generate {
+			t.Errorf("Lib file written to %q has the wrong go:generate directive:\n%s", dir, b)
+		}
+	}
+}
+
+func TestGeneratedHeader(t *testing.T) {
+	// the form Go tooling uses to recognize generated files
+	generated := regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+	for _, header := range [][]byte{generatedHeader(), generatedHeader("a/blog.gp", "users.gp")} {
+		if !generated.Match(header) {
+			t.Errorf("Header %q isn't recognized as generated", header)
+		}
+	}
+}
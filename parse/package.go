@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/format"
+	"go/token"
 	"os"
 	"reflect"
 	"regexp"
@@ -17,11 +19,17 @@ import (
 
 type Package struct {
 	Tables      []Table
+	Variants    []Variant
 	Mixins      []Mixin
 	Subrecords  []Subrecord
+	Enums       []Enum
 	ActiveFiles []ActiveFile
 	Funcs       map[string][]Func
+	Config      Config
 	name        *string
+	fset        *token.FileSet
+	sources     map[string]*sourceMap
+	custom      *projectTemplates
 }
 
 func (p *Package) Name() string {
@@ -44,6 +52,59 @@ func (p Package) TableByName(tableName string) (Table, bool) {
 	return Table{}, false
 }
 
+// Variant is a table declared as "type Announcement table Post", its rows
+// are kept in the parent table and told apart by the parent's Type column
+type Variant struct {
+	Name   string
+	Parent string
+	Pkg    *Package
+	spec   *ast.TypeSpec
+}
+
+// variantColumn is the column of a table with variants holding the name of
+// the variant each row belongs to, it's empty for rows of the table itself
+const variantColumn = "Type"
+
+// Table is the table the variant's rows are kept in
+func (v Variant) Table() *Table {
+	table, _ := v.Pkg.TableByName(v.Parent)
+	return &table
+}
+
+// EnumByName finds an enum declared in the .gp files
+func (p Package) EnumByName(name string) (Enum, bool) {
+	for _, e := range p.Enums {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Enum{}, false
+}
+
+// Enum is a type declared with enum in a .gp file, the values are ints in
+// Go and are stored by name in the database
+type Enum struct {
+	Name   string
+	Values []EnumValue
+	spec   *ast.TypeSpec
+}
+
+// Length is the length of the longest stored name
+func (e Enum) Length() int {
+	length := 0
+	for _, v := range e.Values {
+		if len(v.Stored) > length {
+			length = len(v.Stored)
+		}
+	}
+	return length
+}
+
+type EnumValue struct {
+	Name, Stored string
+	pos          token.Position
+}
+
 type ActiveFile struct {
 	SrcName string
 	AST     *ast.File
@@ -97,19 +158,106 @@ func (t Table) ColumnByName(colName string) (Column, bool) {
 	return Column{}, false
 }
 
+// Index is a line of an index block, its Columns are column names or SQL
+// expressions using them
 type Index struct {
 	Columns []string
+	Desc    []bool
+	Unique  bool
+	Name    string
+	Where   string
+	pos     token.Position
+}
+
+// Descending is whether any of the columns are sorted descending
+func (i Index) Descending() bool {
+	for _, desc := range i.Desc {
+		if desc {
+			return true
+		}
+	}
+	return false
 }
 
 type Relationship struct {
 	Table string
-	// One of "ParentHasMany", "ChildHasMany", "HasOne", "BelongsTo"
+	// One of "ParentHasMany", "ChildHasMany", "HasOne", "BelongsTo" or
+	// "Polymorphic"
 	Type                  string
 	IsArray               bool
 	Alias                 string
 	Parent                Table
 	ParentName, ChildName string
 	OperativeColumn       string
+	// Tag holds the column of the foreign key, for relations named apart
+	// from it, and the onDelete, onUpdate and deferrable settings of the
+	// foreign key, which may be set on either side of the relation
+	Tag reflect.StructTag
+	pos token.Position
+}
+
+// foreignKeyActions are the actions a foreign key can take when the row it
+// refers to is deleted or updated
+var foreignKeyActions = map[string]string{
+	"cascade":     "CASCADE",
+	"set null":    "SET NULL",
+	"set default": "SET DEFAULT",
+	"restrict":    "RESTRICT",
+	"no action":   "NO ACTION",
+}
+
+// OnDelete is the ON DELETE action from the onDelete tag
+func (r Relationship) OnDelete() string {
+	return foreignKeyActions[strings.ToLower(r.foreignKeyTag().Get("onDelete"))]
+}
+
+// OnUpdate is the ON UPDATE action from the onUpdate tag
+func (r Relationship) OnUpdate() string {
+	return foreignKeyActions[strings.ToLower(r.foreignKeyTag().Get("onUpdate"))]
+}
+
+// Deferrable is whether the foreign key is checked at the end of the
+// transaction, from the deferrable tag
+func (r Relationship) Deferrable() bool {
+	deferrable, _ := strconv.ParseBool(r.foreignKeyTag().Get("deferrable"))
+	return deferrable
+}
+
+// foreignKeyTag is the tag of the relation, or the tag of the relation on
+// the other table when only that one has foreign key settings
+func (r Relationship) foreignKeyTag() reflect.StructTag {
+	if r.hasActions() || r.Parent.Pkg == nil {
+		return r.Tag
+	}
+	other, ok := r.Parent.Pkg.TableByName(r.Table)
+	if !ok {
+		return r.Tag
+	}
+	for _, back := range other.Relations {
+		if back.Table == r.Parent.name && back.pos != r.pos && back.OperativeColumn == r.OperativeColumn && back.hasActions() {
+			return back.Tag
+		}
+	}
+	return r.Tag
+}
+
+// Dependent is what deleting a record does to the records of its has many
+// or has one relation, "delete", "nullify" or "restrict" from the dependent
+// tag, or empty when they're left alone
+func (r Relationship) Dependent() string {
+	return strings.ToLower(r.Tag.Get("dependent"))
+}
+
+// dependentActions are the values the dependent tag may take
+var dependentActions = map[string]bool{"delete": true, "nullify": true, "restrict": true}
+
+func (r Relationship) hasActions() bool {
+	for _, key := range []string{"onDelete", "onUpdate", "deferrable"} {
+		if _, ok := r.Tag.Lookup(key); ok {
+			return true
+		}
+	}
+	return false
 }
 
 func (r Relationship) IsHasMany() bool {
@@ -128,21 +276,101 @@ func (r Relationship) IsBelongsTo() bool {
 	return r.Type == "BelongsTo"
 }
 
+// IsPolymorphic is true for relations declared like "Commentable
+// polymorphic", which can refer to a row of any table declaring a has many
+// relation back to it with an as tag
+func (r Relationship) IsPolymorphic() bool {
+	return r.Type == "Polymorphic"
+}
+
+// As is the polymorphic relation on the child table that a has many
+// relation goes through, from the as tag
+func (r Relationship) As() string {
+	return r.Tag.Get("as")
+}
+
+// TypeColumn is the column of a polymorphic relation holding the name of
+// the table it refers to
+func (r Relationship) TypeColumn() string {
+	if r.IsPolymorphic() {
+		return r.Alias + "Type"
+	}
+	if r.As() != "" {
+		return r.As() + "Type"
+	}
+	return ""
+}
+
+// PolymorphicTypes are the tables a polymorphic relation can refer to
+func (r Relationship) PolymorphicTypes() []Table {
+	types := []Table{}
+	if !r.IsPolymorphic() || r.Parent.Pkg == nil {
+		return types
+	}
+	for _, table := range r.Parent.Pkg.Tables {
+		for _, relate := range table.Relations {
+			if relate.Table == r.Parent.name && relate.As() == r.Alias {
+				types = append(types, table)
+				break
+			}
+		}
+	}
+	return types
+}
+
 func (r Relationship) ColumnName() string {
+	switch r.Type {
+	case "ParentHasMany", "HasOne":
+		return r.foreignKey(false)
+	case "ChildHasMany", "BelongsTo":
+		return r.foreignKey(true)
+	}
+	return ""
+}
+
+// SavesFirst is whether the record on the other side of the relation
+// holds the primary key, so it's saved before the record referring to it
+func (r Relationship) SavesFirst() bool {
+	return r.IsChildHasMany() || r.IsBelongsTo()
+}
+
+// Target is the table on the other side of the relation
+func (r Relationship) Target() *Table {
+	if r.Parent.Pkg == nil {
+		return &Table{}
+	}
+	target, _ := r.Parent.Pkg.TableByName(r.Table)
+	return &target
+}
+
+// ForeignKey is the foreign key column, on whichever side of the relation
+// holds it
+func (r Relationship) ForeignKey() Column {
+	holder := r.Parent
+	if !r.SavesFirst() {
+		holder = *r.Target()
+	}
+	col, _ := holder.ColumnByName(r.OperativeColumn)
+	return col
+}
+
+// foreignKey is the column of the foreign key, from the column tag or the
+// alias, or else named after the table holding the primary key. Child is
+// whether the relation's own table holds the foreign key.
+func (r Relationship) foreignKey(child bool) string {
+	if column := r.Tag.Get("column"); column != "" {
+		return column
+	}
+	if r.As() != "" {
+		return r.As() + "ID"
+	}
 	if r.Alias != "" {
 		return r.Alias + "ID"
 	}
-	switch r.Type {
-	case "ParentHasMany":
-		return r.Parent.name + "ID"
-	case "ChildHasMany":
-		return r.Table + "ID"
-	case "HasOne":
-		return r.Parent.name + "ID"
-	case "BelongsTo":
+	if child {
 		return r.Table + "ID"
 	}
-	return ""
+	return r.Parent.name + "ID"
 }
 
 func (r Relationship) Name() string {
@@ -210,9 +438,52 @@ func (t *Table) Columns() []Column {
 	return t.cols
 }
 
-func (t Table) RelationshipTo(tableName string) (Relationship, bool) {
+// Timestamps is whether CreatedAt and UpdatedAt are kept up to date
+func (t Table) Timestamps() bool {
+	if t.Pkg == nil || !t.Pkg.Config.Timestamps {
+		return false
+	}
+	created, cok := t.ColumnByName("CreatedAt")
+	updated, uok := t.ColumnByName("UpdatedAt")
+	return cok && uok &&
+		created.GoType == "&{time Time}" && !created.MustNull &&
+		updated.GoType == "&{time Time}" && !updated.MustNull
+}
+
+// SoftDelete is whether deleting records sets DeletedAt instead
+func (t Table) SoftDelete() bool {
+	if t.Pkg == nil || !t.Pkg.Config.SoftDelete {
+		return false
+	}
+	deleted, ok := t.ColumnByName("DeletedAt")
+	return ok && deleted.GoType == "&{time Time}" && deleted.MustNull
+}
+
+// Variants are the variants kept in the table
+func (t Table) Variants() []Variant {
+	variants := []Variant{}
+	if t.Pkg == nil {
+		return variants
+	}
+	for _, variant := range t.Pkg.Variants {
+		if variant.Parent == t.name {
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+// VariantColumn is the column holding the variant of each row, for tables
+// with variants
+func (t Table) VariantColumn() string {
+	return variantColumn
+}
+
+// RelationshipTo finds the relation on t that is the other side of the
+// child relation r, the one to r's table using the same foreign key
+func (t Table) RelationshipTo(r Relationship) (Relationship, bool) {
 	for _, relate := range t.Relations {
-		if relate.Table == tableName {
+		if relate.Table == r.Parent.name && relate.pos != r.pos && relate.foreignKey(false) == r.foreignKey(true) {
 			return relate, true
 		}
 	}
@@ -232,6 +503,30 @@ func (t Table) HasRelationship(relate string) bool {
 	return false
 }
 
+// HasDependents is whether deleting the table's records deletes, nullifies
+// or is restricted by the records of its relations
+func (t Table) HasDependents() bool {
+	for _, relation := range t.Relations {
+		if relation.Dependent() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SavedRelations are the relations whose records can be set on the
+// table's Related field to be saved along with it, every one but the
+// polymorphic relations, which don't know the table they refer to
+func (t Table) SavedRelations() []Relationship {
+	relations := []Relationship{}
+	for _, relation := range t.Relations {
+		if !relation.IsPolymorphic() {
+			relations = append(relations, relation)
+		}
+	}
+	return relations
+}
+
 type Column struct {
 	GoType, Name string
 	Tag          reflect.StructTag
@@ -245,9 +540,16 @@ type Column struct {
 }
 
 func (c Column) NonZeroCheck() string {
-	switch c.GoType {
-	case "int", "int32", "int64", "int16":
+	if c.MustNull {
+		return " != nil"
+	}
+	if _, ok := nullTypes[c.GoType]; ok {
+		return ".Valid"
+	}
+	if integerTypes[c.GoType] != "" {
 		return " != 0"
+	}
+	switch c.GoType {
 	case "string":
 		return ` != ""`
 	case "&{time Time}":
@@ -262,6 +564,12 @@ func (c Column) NonZeroCheck() string {
 }
 
 func (c Column) Preset() bool {
+	if c.Nullable() || c.Constrained() {
+		return false
+	}
+	if c.GoType == "string" && c.Length() != 255 {
+		return false
+	}
 	switch c.GoType {
 	case "int", "string", "bool", "&{time.Time}", "&{time Time}":
 		return c.Tag.Get("length") == "" && c.Tag.Get("type") == ""
@@ -270,10 +578,152 @@ func (c Column) Preset() bool {
 	}
 }
 
+// integerTypes are the column types of the Go integer types, unsigned
+// integers get a wider type in databases without unsigned columns
+var integerTypes = map[string]string{
+	"int8":   "smallint",
+	"int16":  "smallint",
+	"uint8":  "smallint",
+	"byte":   "smallint",
+	"uint16": "smallint",
+	"int":    "integer",
+	"int32":  "integer",
+	"uint":   "integer",
+	"uint32": "integer",
+	"int64":  "bigint",
+	"uint64": "bigint",
+}
+
+// Unsigned is whether the column holds an unsigned integer
+func (c Column) Unsigned() bool {
+	t := c.baseType()
+	return integerTypes[t] != "" && (t == "byte" || strings.HasPrefix(t, "uint"))
+}
+
+// nullTypes are the database/sql Null types, by the Go type they wrap
+var nullTypes = map[string]string{
+	"&{sql NullString}":  "string",
+	"&{sql NullInt64}":   "int64",
+	"&{sql NullInt32}":   "int32",
+	"&{sql NullInt16}":   "int16",
+	"&{sql NullByte}":    "byte",
+	"&{sql NullFloat64}": "float64",
+	"&{sql NullBool}":    "bool",
+	"&{sql NullTime}":    "&{time Time}",
+}
+
+// Nullable is whether the column may hold NULL, which is the case for
+// pointer fields, the sql.Null types and byte slices, where a nil slice
+// is written as NULL. A null tag overrides this either way.
+func (c Column) Nullable() bool {
+	if null, err := strconv.ParseBool(c.Tag.Get("null")); err == nil {
+		return null
+	}
+	_, null := nullTypes[c.GoType]
+	return c.MustNull || null || c.GoType == "[]byte"
+}
+
+// Default is the SQL expression from the default tag, used as is
+func (c Column) Default() string {
+	return c.Tag.Get("default")
+}
+
+// Unique is whether the unique tag is set
+func (c Column) Unique() bool {
+	unique, _ := strconv.ParseBool(c.Tag.Get("unique"))
+	return unique
+}
+
+// Check is the SQL expression from the check tag, used as is
+func (c Column) Check() string {
+	return c.Tag.Get("check")
+}
+
+// Comment is the comment tag, kept on the column in the database
+func (c Column) Comment() string {
+	return c.Tag.Get("comment")
+}
+
+// Constrained is whether any of the constraint tags are on the column
+func (c Column) Constrained() bool {
+	for _, key := range []string{"null", "default", "unique", "check", "comment"} {
+		if _, ok := c.Tag.Lookup(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// baseType is the Go type of the column with any sql.Null wrapper removed
+func (c Column) baseType() string {
+	if t, ok := nullTypes[c.GoType]; ok {
+		return t
+	}
+	return c.GoType
+}
+
+// SimpleType is whether the column is stored in a single database column,
+// either as one of the Go types dr knows, a Custom type or JSON
 func (c Column) SimpleType() bool {
-	switch c.GoType {
-	case "int", "int32", "int64", "int16":
+	return c.builtinType() || c.Custom() || c.JSON()
+}
+
+// Custom is whether the column's type handles its own conversion through
+// sql.Scanner and driver.Valuer. Enums and types defined in the .gp files
+// are found by their Scan method, other types need a type tag to give
+// their SQL type.
+func (c Column) Custom() bool {
+	if c.builtinType() || c.Subrecord() != nil {
+		return false
+	}
+	return c.hasScan() || (c.Tag.Get("type") != "" && !c.JSON())
+}
+
+// JSON is whether the column is a struct, map or slice kept as JSON text,
+// which is asked for with type:"json" or, on fields dr couldn't otherwise
+// store, a json tag.
+func (c Column) JSON() bool {
+	if c.builtinType() || c.Subrecord() != nil || c.hasScan() {
+		return false
+	}
+	if t := c.Tag.Get("type"); t != "" {
+		return t == "json"
+	}
+	json := c.Tag.Get("json")
+	return json != "" && json != "-"
+}
+
+// Enum is the enum the column holds, if it holds one
+func (c Column) Enum() *Enum {
+	if c.Pkg == nil {
+		return nil
+	}
+	if e, ok := c.Pkg.EnumByName(c.GoType); ok {
+		return &e
+	}
+	return nil
+}
+
+func (c Column) hasScan() bool {
+	if c.Pkg == nil {
+		return false
+	}
+	if c.Enum() != nil || c.baseType() == "&{dr Decimal}" {
+		return true
+	}
+	for _, f := range c.Pkg.Funcs[c.GoType] {
+		if f.Spec.Name.Name == "Scan" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Column) builtinType() bool {
+	if integerTypes[c.baseType()] != "" {
 		return true
+	}
+	switch c.baseType() {
 	case "string":
 		return true
 	case "&{time Time}":
@@ -290,7 +740,7 @@ func (c Column) SimpleType() bool {
 }
 
 func (c Column) Subrecord() *Subrecord {
-	if c.SimpleType() {
+	if c.builtinType() || c.Pkg == nil {
 		return nil
 	}
 	for _, sr := range c.Pkg.Subrecords {
@@ -349,14 +799,23 @@ func (c *Column) IterateColumns(ch chan Column) {
 	close(ch)
 }
 
+// Type is the SQL type of the column, a type tag takes precedence over the
+// type picked for the Go type
 func (c Column) Type() string {
-	switch c.GoType {
-	case "int":
-		return "integer"
+	if c.JSON() {
+		return "json"
+	}
+	if c.Enum() != nil {
+		return "enum"
+	}
+	if t := c.Tag.Get("type"); t != "" {
+		return t
+	}
+	if t := integerTypes[c.baseType()]; t != "" {
+		return t
+	}
+	switch c.baseType() {
 	case "string":
-		if c.Tag.Get("type") == "text" {
-			return "text"
-		}
 		return "varchar"
 	case "&{time Time}":
 		return "timestamp"
@@ -368,10 +827,29 @@ func (c Column) Type() string {
 		return "boolean"
 	case "[]byte":
 		return "blob"
+	case "&{dr Decimal}":
+		return "decimal"
 	default:
 		return "varchar"
 	}
-	return ""
+}
+
+// Precision is the number of digits in a decimal column, from the precision
+// tag or 10 by default
+func (c Column) Precision() int {
+	if p, err := strconv.Atoi(c.Tag.Get("precision")); err == nil {
+		return p
+	}
+	return 10
+}
+
+// Scale is the number of digits after the decimal point in a decimal
+// column, from the scale tag or 2 by default
+func (c Column) Scale() int {
+	if s, err := strconv.Atoi(c.Tag.Get("scale")); err == nil {
+		return s
+	}
+	return 2
 }
 
 func (c Column) Length() int {
@@ -381,19 +859,32 @@ func (c Column) Length() int {
 			return int(l)
 		}
 	}
-	switch c.GoType {
-	case "int":
+	switch c.baseType() {
+	case "int", "int32", "uint", "uint32":
 		return 10
 	case "string":
 		if c.Tag.Get("type") == "text" {
 			return 0
 		}
-		return 255
+		return c.stringLength()
 	default:
+		if e := c.Enum(); e != nil {
+			return e.Length()
+		}
+		if c.Custom() && c.Type() == "varchar" {
+			return c.stringLength()
+		}
 		return 0
 	}
 }
 
+func (c Column) stringLength() int {
+	if c.Pkg != nil && c.Pkg.Config.StringLength > 0 {
+		return c.Pkg.Config.StringLength
+	}
+	return 255
+}
+
 type Subrecord struct {
 	name string
 	spec *ast.TypeSpec
@@ -420,9 +911,19 @@ func (t Subrecord) File() *ast.File {
 var tmpl *template.Template
 
 func init() {
-	rg := regexp.MustCompile(`^[A-Z].*`)
 	var err error
-	tmpl, err = template.New("dr").
+	tmpl, err = newTemplates()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// newTemplates parses the built in templates into a new set, a set can't be
+// cloned once it has been executed so each project template directory is
+// added to a fresh one.
+func newTemplates() (*template.Template, error) {
+	rg := regexp.MustCompile(`^[A-Z].*`)
+	t, err := template.New("dr").
 		Funcs(template.FuncMap{
 		"plural": inflections.Pluralize,
 		"public": func(s string) bool {
@@ -431,110 +932,148 @@ func init() {
 	}).
 		New("gen").Parse(genTemplate)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	tmpl, err = tmpl.New("schema").Parse(schemaTemplate)
+	t, err = t.New("schema").Parse(schemaTemplate)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	tmpl, err = tmpl.New("lib").Parse(libTemplate)
+	t, err = t.New("lib").Parse(libTemplate)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	return t.New("migrator").Parse(migratorTemplate)
 }
 
-func (pkg *Package) OutputTemplates() {
-	b := &bytes.Buffer{}
-	err := tmpl.ExecuteTemplate(b, "gen", pkg)
-	if err != nil {
-		panic(err)
+// Generate renders the code dr build writes for a parsed Package, keyed by
+// file name. db_config.go is not included, it is only written when missing
+// and belongs to the package author from then on.
+func (pkg *Package) Generate() (map[string][]byte, error) {
+	if len(pkg.ActiveFiles) == 0 {
+		return nil, fmt.Errorf("No table, mixin or subrecord declarations were found")
 	}
 
-	f, err := os.Create(pkg.ActiveFiles[0].AST.Name.Name + "_gen.go")
-	if err != nil {
-		fmt.Println("Could not write schema file")
+	files := map[string][]byte{}
+	sources := []string{}
+	for _, active := range pkg.ActiveFiles {
+		filename := pkg.Config.Output.defFile(active.SrcName)
+		if _, ok := files[filename]; ok {
+			return nil, fmt.Errorf("More than one .gp file would be written to %s", filename)
+		}
+		b := &bytes.Buffer{}
+		err := format.Node(b, pkg.fset, active.AST)
+		if err != nil {
+			return nil, err
+		}
+		ib, err := imports.Process(filename, b.Bytes(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		files[filename] = append(generatedHeader(active.SrcName), ib...)
+		sources = append(sources, active.SrcName)
 	}
-	defer f.Close()
 
-	ib, err := imports.Process(pkg.ActiveFiles[0].AST.Name.Name+"_gen.go", b.Bytes(), nil)
+	for _, name := range []string{"gen", "schema", "lib"} {
+		filename := pkg.Config.Output.packageFile(pkg.Name(), name)
+		if _, ok := files[filename]; ok {
+			return nil, fmt.Errorf("More than one generated file would be written to %s", filename)
+		}
+		b, err := pkg.render(name, filename)
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = append(generatedHeader(sources...), b...)
+	}
+
+	t, err := pkg.templates()
 	if err != nil {
-		fmt.Println("Error in Gen File:", err)
-		f.Write(b.Bytes())
-		return
+		return nil, err
+	}
+	for _, name := range t.extras {
+		filename := pkg.Config.Output.packageFile(pkg.Name(), name)
+		if _, ok := files[filename]; ok {
+			return nil, fmt.Errorf("Template %s would be written to %s, which is already generated", name, filename)
+		}
+		b, err := pkg.render(name, filename)
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = append(generatedHeader(sources...), b...)
 	}
-	f.Write(ib)
+	return files, nil
+}
 
-	w, err := os.Create(pkg.ActiveFiles[0].AST.Name.Name + "_schema.go")
+// render executes a template and formats the result, if it can't be
+// formatted the raw output is returned so the problem can be found.
+func (pkg *Package) render(name, filename string) ([]byte, error) {
+	t, err := pkg.templates()
 	if err != nil {
-		panic("Couldn't open schema file for writing: " + err.Error())
+		return nil, err
 	}
-	b = &bytes.Buffer{}
-	err = tmpl.ExecuteTemplate(b, "schema", pkg)
+	b := &bytes.Buffer{}
+	err = t.ExecuteTemplate(b, name, pkg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	ib, err = imports.Process(pkg.ActiveFiles[0].AST.Name.Name+"_schema.go", b.Bytes(), nil)
+
+	ib, err := imports.Process(filename, b.Bytes(), nil)
 	if err != nil {
 		fmt.Println("Error in Gen File:", err)
-		w.Write(b.Bytes())
-		return
+		return b.Bytes(), nil
 	}
-	w.Write(ib)
-
-	pkg.WriteLibraryFiles()
+	return ib, nil
 }
 
-func (pkg *Package) WriteLibraryFiles() {
-	b := &bytes.Buffer{}
-	err := tmpl.ExecuteTemplate(b, "lib", pkg)
+func (pkg *Package) OutputTemplates() {
+	files, err := pkg.Generate()
 	if err != nil {
 		panic(err)
 	}
-
-	f, err := os.Create(pkg.Name() + "_lib.go")
+	_, err = WriteFiles(files)
 	if err != nil {
-		fmt.Println("Could not write schema file")
+		fmt.Println("Could not write generated files:", err)
 	}
 
-	ib, err := imports.Process(pkg.Name()+"_lib.go", b.Bytes(), nil)
+	err = pkg.WriteConfig()
 	if err != nil {
-		fmt.Println("Error in Gen File:", err)
-		f.Write(b.Bytes())
-		f.Close()
-		return
+		fmt.Println("Could not write db_config.go:", err)
 	}
-	f.Write(ib)
-	f.Close()
+}
 
-	filename := "db_config.go"
-	if _, err = os.Stat(filename); err == nil {
-		// fmt.Println("Library file already written")
-		return
-	}
-	b = &bytes.Buffer{}
-	err = tmpl.ExecuteTemplate(b, "config", pkg)
+// WriteLibraryFiles writes the lib file and the starting db_config.go, it
+// doesn't need any .gp files to have been parsed.
+func (pkg *Package) WriteLibraryFiles() {
+	filename := pkg.Config.Output.packageFile(pkg.Name(), "lib")
+	b, err := pkg.render("lib", filename)
 	if err != nil {
 		panic(err)
 	}
-
-	f, err = os.Create(filename)
+	_, err = WriteFiles(map[string][]byte{filename: append(generatedHeader(), b...)})
 	if err != nil {
-		fmt.Println("Could not write schema file")
+		fmt.Println("Could not write", filename)
 	}
 
-	ib, err = imports.Process(filename, b.Bytes(), nil)
+	err = pkg.WriteConfig()
 	if err != nil {
-		fmt.Println("Error in Gen File:", err)
-		f.Write(b.Bytes())
-		f.Close()
-		return
+		fmt.Println("Could not write db_config.go:", err)
 	}
-	f.Write(ib)
-	f.Close()
+}
 
+// WriteConfig writes the starting AppConfig file, unless it already exists
+func (pkg *Package) WriteConfig() error {
+	filename := pkg.Config.Output.configFile()
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	b, err := pkg.render("config", filename)
+	if err != nil {
+		return err
+	}
+	_, err = WriteFiles(map[string][]byte{filename: b})
+	return err
 }
 
 func (pkg *Package) WriteStarterFile() {
@@ -1,37 +1,35 @@
 package parse
 
-var schemaTemplate = `/*
-  This code was generated by the Doctor ORM Generator and isn't meant to be edited.
-	If at all possible, please regenerate this file from your gp files instead of
-	attempting to edit it to add changes.
-*/
+var schemaTemplate = `package {{ .Name }}
 
-package {{ .Name }}
-
-import "github.com/acsellers/dr/schema"
+import (
+	dr "github.com/acsellers/dr/runtime"
+	"github.com/acsellers/dr/schema"
+)
 
 var Schema = schema.Schema{
 	Tables: map[string]*schema.Table{
 		{{ range $table := .Tables }}
 			"{{ .Name }}": &schema.Table{
 				Name: "{{ .Name }}",
+				{{ if .SoftDelete }}DeletedAt: "DeletedAt",{{ end }}
 				Columns: []schema.Column{
 					{{ range $column := .Columns }}
 						{{ if $column.Preset }}
 							{{ if eq $column.GoType "int" }}
-								DefaultInt("{{ $column.Name }}"),
+								dr.DefaultInt("{{ $column.Name }}"),
 							{{ end }}
 							{{ if eq $column.Type "varchar" }}
-								DefaultString("{{ $column.Name }}"),
+								dr.DefaultString("{{ $column.Name }}"),
 							{{ end }}
 							{{ if eq $column.GoType "bool" }}
-								DefaultBool("{{ $column.Name }}"),
+								dr.DefaultBool("{{ $column.Name }}"),
 							{{ end }}
 							{{ if eq $column.GoType "&{time.Time}" }}
-								DefaultTime("{{ $column.Name }}"),
+								dr.DefaultTime("{{ $column.Name }}"),
 							{{ end }}
 							{{ if eq $column.Type "timestamp" }}
-								DefaultTime("{{ $column.Name }}"),
+								dr.DefaultTime("{{ $column.Name }}"),
 							{{ end }}
 						{{ else }}
 							{{ if $column.SimpleType }}
@@ -39,6 +37,17 @@ var Schema = schema.Schema{
 									Name: "{{ $column.Name }}",
 									Type: "{{ $column.Type }}",
 									Length: {{ $column.Length }},
+									{{ if $column.Nullable }}Null: true,{{ end }}
+									{{ if $column.Unsigned }}Unsigned: true,{{ end }}
+									{{ if $column.Default }}Default: {{ printf "%q" $column.Default }},{{ end }}
+									{{ if $column.Unique }}Unique: true,{{ end }}
+									{{ if $column.Check }}Check: {{ printf "%q" $column.Check }},{{ end }}
+									{{ if $column.Comment }}Comment: {{ printf "%q" $column.Comment }},{{ end }}
+									{{ if eq $column.Type "decimal" }}Precision: {{ $column.Precision }}, Scale: {{ $column.Scale }},{{ end }}
+									{{ if $column.Enum }}
+										Enum: "{{ $column.Enum.Name }}",
+										Values: []string{ {{ range $column.Enum.Values }}{{ printf "%q" .Stored }}, {{ end }} },
+									{{ end }}
 								},
 							{{ end }}
 							{{ if $column.Subrecord }}
@@ -47,6 +56,17 @@ var Schema = schema.Schema{
 										Name: "{{ $subcolumn.Name }}",
 										Type: "{{ $subcolumn.Type }}",
 										Length: {{ $subcolumn.Length }},
+										{{ if $subcolumn.Nullable }}Null: true,{{ end }}
+										{{ if $subcolumn.Unsigned }}Unsigned: true,{{ end }}
+										{{ if $subcolumn.Default }}Default: {{ printf "%q" $subcolumn.Default }},{{ end }}
+										{{ if $subcolumn.Unique }}Unique: true,{{ end }}
+										{{ if $subcolumn.Check }}Check: {{ printf "%q" $subcolumn.Check }},{{ end }}
+										{{ if $subcolumn.Comment }}Comment: {{ printf "%q" $subcolumn.Comment }},{{ end }}
+										{{ if eq $subcolumn.Type "decimal" }}Precision: {{ $subcolumn.Precision }}, Scale: {{ $subcolumn.Scale }},{{ end }}
+										{{ if $subcolumn.Enum }}
+											Enum: "{{ $subcolumn.Enum.Name }}",
+											Values: []string{ {{ range $subcolumn.Enum.Values }}{{ printf "%q" .Stored }}, {{ end }} },
+										{{ end }}
 										IncludeName: "{{ $subcolumn.IncludeName }}",
 									},
 								{{ end }}
@@ -61,8 +81,12 @@ var Schema = schema.Schema{
 					{{ range $index := $table.Indexes }}
 						schema.Index{
 							Columns: []string{ {{ range .Columns }}
-								"{{ . }}",{{ end }}
+								{{ printf "%q" . }},{{ end }}
 							},
+							{{ if .Unique }}Unique: true,{{ end }}
+							{{ if .Descending }}Desc: []bool{ {{ range .Desc }}{{ . }}, {{ end }} },{{ end }}
+							{{ if .Name }}Name: {{ printf "%q" .Name }},{{ end }}
+							{{ if .Where }}Where: {{ printf "%q" .Where }},{{ end }}
 						},
 					{{ end }}
 				},
@@ -82,6 +106,14 @@ func init() {
 							Child: Schema.Tables["{{ $relate.ChildName }}"],
 							ChildColumn: Schema.Tables["{{ $relate.ChildName }}"].FindColumn("{{ $relate.OperativeColumn }}"),
 							Alias: "{{ $relate.Alias }}",
+							{{ if $relate.OnDelete }}OnDelete: "{{ $relate.OnDelete }}",{{ end }}
+							{{ if $relate.OnUpdate }}OnUpdate: "{{ $relate.OnUpdate }}",{{ end }}
+							{{ if $relate.Deferrable }}Deferrable: true,{{ end }}
+							{{ if $relate.Dependent }}Dependent: "{{ $relate.Dependent }}",{{ end }}
+							{{ if $relate.As }}
+								TypeColumn: Schema.Tables["{{ $relate.ChildName }}"].FindColumn("{{ $relate.TypeColumn }}"),
+								TypeName: "{{ $table.Name }}",
+							{{ end }}
 						},
 					{{ end }}
 				{{ end }}
@@ -90,15 +122,30 @@ func init() {
 	{{ end }}
 
 	{{ range $table := .Tables }}
-		{{ if $table.HasRelationship "ChildHasMany" }}
+		{{ if or ($table.HasRelationship "ChildHasMany") ($table.HasRelationship "Polymorphic") }}
 			Schema.Tables["{{ .Name }}"].ChildOf = []schema.ManyRelationship{
 				{{ range $relate := $table.Relations }}
+					{{ if $relate.IsPolymorphic }}
+						{{ range $type := $relate.PolymorphicTypes }}
+							schema.ManyRelationship{
+								Parent: Schema.Tables["{{ $type.Name }}"],
+								Child: Schema.Tables["{{ $table.Name }}"],
+								ChildColumn: Schema.Tables["{{ $table.Name }}"].FindColumn("{{ $relate.OperativeColumn }}"),
+								Alias: "{{ $relate.Alias }}",
+								TypeColumn: Schema.Tables["{{ $table.Name }}"].FindColumn("{{ $relate.TypeColumn }}"),
+								TypeName: "{{ $type.Name }}",
+							},
+						{{ end }}
+					{{ end }}
 					{{ if $relate.IsChildHasMany }}
 						schema.ManyRelationship{
 							Parent: Schema.Tables["{{ $relate.ParentName }}"],
 							Child: Schema.Tables["{{ $relate.ChildName }}"],
 							ChildColumn: Schema.Tables["{{ $relate.ChildName }}"].FindColumn("{{ $relate.OperativeColumn }}"),
 							Alias: "{{ $relate.Alias }}",
+							{{ if $relate.OnDelete }}OnDelete: "{{ $relate.OnDelete }}",{{ end }}
+							{{ if $relate.OnUpdate }}OnUpdate: "{{ $relate.OnUpdate }}",{{ end }}
+							{{ if $relate.Deferrable }}Deferrable: true,{{ end }}
 						},
 					{{ end }}
 				{{ end }}
@@ -116,6 +163,10 @@ func init() {
 							Child: Schema.Tables["{{ $relate.ChildName }}"],
 							ChildColumn: Schema.Tables["{{ $relate.ChildName }}"].FindColumn("{{ $relate.OperativeColumn }}"),
 							Alias: "{{ $relate.Alias }}",
+							{{ if $relate.OnDelete }}OnDelete: "{{ $relate.OnDelete }}",{{ end }}
+							{{ if $relate.OnUpdate }}OnUpdate: "{{ $relate.OnUpdate }}",{{ end }}
+							{{ if $relate.Deferrable }}Deferrable: true,{{ end }}
+							{{ if $relate.Dependent }}Dependent: "{{ $relate.Dependent }}",{{ end }}
 						},
 					{{ end }}
 				{{ end }}
@@ -133,6 +184,9 @@ func init() {
 							Child: Schema.Tables["{{ $relate.ChildName }}"],
 							ChildColumn: Schema.Tables["{{ $relate.ChildName }}"].FindColumn("{{ $relate.OperativeColumn }}"),
 							Alias: "{{ $relate.Alias }}",
+							{{ if $relate.OnDelete }}OnDelete: "{{ $relate.OnDelete }}",{{ end }}
+							{{ if $relate.OnUpdate }}OnUpdate: "{{ $relate.OnUpdate }}",{{ end }}
+							{{ if $relate.Deferrable }}Deferrable: true,{{ end }}
 						},
 					{{ end }}
 				{{ end }}
@@ -141,8 +195,11 @@ func init() {
 	{{ end }}
 }
 
-{{ range $table := .Tables }}
+{{ range .Tables }}
+	{{ template "record_methods" . }}
+{{ end }}
 
+{{ define "record_methods" }}{{ $table := . }}
 func (t {{ $table.Name }}) Scope() *{{ $table.Name }}Scope {
 	return t.cached_conn.{{ $table.Name }}.{{ .PrimaryKeyColumn.Name }}().Eq(t.{{ .PrimaryKeyColumn.Name }})
 }
@@ -152,6 +209,31 @@ func (t {{ $table.Name }}) ToScope(c *Conn) *{{ $table.Name }}Scope {
 }
 
 func (t *{{ $table.Name }}) Save(c *Conn) error {
+	{{ range $column := $table.Columns }}
+		{{ if $column.Enum }}
+			{{ if $column.MustNull }}
+				if t.{{ $column.Name }} != nil && !t.{{ $column.Name }}.Valid() {
+					return fmt.Errorf("{{ $table.Name }}.{{ $column.Name }} is not a valid {{ $column.Enum.Name }}: %d", *t.{{ $column.Name }})
+				}
+			{{ else }}
+				if !t.{{ $column.Name }}.Valid() {
+					return fmt.Errorf("{{ $table.Name }}.{{ $column.Name }} is not a valid {{ $column.Enum.Name }}: %d", t.{{ $column.Name }})
+				}
+			{{ end }}
+		{{ end }}
+	{{ end }}
+
+	{{ if $table.SavedRelations }}
+		if t.Related.set() {
+			if c == nil {
+				c = t.cached_conn
+			}
+			if c == nil {
+				return fmt.Errorf("{{ $table.Name }} has related records to save, but no Conn to save them with")
+			}
+			return c.Transaction(t.saveRelated)
+		}
+	{{ end }}
 
 	// check the primary key vs the zero value, if they match then
 	// we will assume we have a new record
@@ -163,48 +245,177 @@ func (t *{{ $table.Name }}) Save(c *Conn) error {
 	}
 }
 
+{{ if $table.SavedRelations }}
+	// {{ $table.Name }}Related holds records to save along with a {{ $table.Name }}, apart
+	// from its fields as the relation methods already use their names. Save
+	// sets the {{ $table.Name }}'s foreign keys from the records it refers to, and saves
+	// the new records on either side in the same transaction. Records that
+	// were saved before and refer to the {{ $table.Name }} are left as they are.
+	type {{ $table.Name }}Related struct {
+		{{ range $relate := $table.SavedRelations }}
+			{{ $relate.Name }} {{ if $relate.IsArray }}[]{{ else }}*{{ end }}{{ $relate.Table }}
+		{{ end }}
+	}
+
+	// set is whether any related records have been set
+	func (r {{ $table.Name }}Related) set() bool {
+		return {{ range $i, $relate := $table.SavedRelations }}{{ if $i }} || {{ end }}{{ if $relate.IsArray }}len(r.{{ $relate.Name }}) > 0{{ else }}r.{{ $relate.Name }} != nil{{ end }}{{ end }}
+	}
+
+	// saveRelated saves the new records the {{ $table.Name }} refers to, then the {{ $table.Name }}
+	// and then the new records referring to it. The records are put back as
+	// they were when the transaction is rolled back.
+	func (t *{{ $table.Name }}) saveRelated(c *Conn) error {
+		before := *t
+		c.onRollback(func() { *t = before })
+		{{ range $relate := $table.SavedRelations }}
+			{{ if $relate.SavesFirst }}
+				if parent := t.Related.{{ $relate.Name }}; parent != nil {
+					if parent.{{ $relate.Target.PrimaryKeyColumn.Name }} == 0 {
+						saved := *parent
+						c.onRollback(func() { *parent = saved })
+						if err := parent.Save(c); err != nil {
+							return err
+						}
+					}
+					{{ if $relate.ForeignKey.MustNull }}
+						id := {{ $relate.ForeignKey.GoType }}(parent.{{ $relate.Target.PrimaryKeyColumn.Name }})
+						t.{{ $relate.OperativeColumn }} = &id
+					{{ else }}
+						t.{{ $relate.OperativeColumn }} = {{ $relate.ForeignKey.GoType }}(parent.{{ $relate.Target.PrimaryKeyColumn.Name }})
+					{{ end }}
+				}
+			{{ end }}
+		{{ end }}
+		var err error
+		if t.{{ .PrimaryKeyColumn.Name }} == 0 {
+			err = t.create(c)
+		} else {
+			err = t.update(c)
+		}
+		if err != nil {
+			return err
+		}
+		{{ range $relate := $table.SavedRelations }}
+			{{ if not $relate.SavesFirst }}
+				{{ if $relate.IsArray }}
+					for i := range t.Related.{{ $relate.Name }} {
+						child := &t.Related.{{ $relate.Name }}[i]
+				{{ else }}
+					if child := t.Related.{{ $relate.Name }}; child != nil {
+				{{ end }}
+					if child.{{ $relate.Target.PrimaryKeyColumn.Name }} == 0 {
+						saved := *child
+						c.onRollback(func() { *child = saved })
+						{{ if $relate.ForeignKey.MustNull }}
+							id := {{ $relate.ForeignKey.GoType }}(t.{{ $table.PrimaryKeyColumn.Name }})
+							child.{{ $relate.OperativeColumn }} = &id
+						{{ else }}
+							child.{{ $relate.OperativeColumn }} = {{ $relate.ForeignKey.GoType }}(t.{{ $table.PrimaryKeyColumn.Name }})
+						{{ end }}
+						{{ if $relate.As }}
+							child.{{ $relate.TypeColumn }} = "{{ $table.Name }}"
+						{{ end }}
+						if err := child.Save(c); err != nil {
+							return err
+						}
+					}
+				}
+			{{ end }}
+		{{ end }}
+		return nil
+	}
+{{ end }}
+
 func (t *{{ $table.Name }}) simpleCols(c *Conn) []string {
 	return []string{ {{ range $column := $table.Columns }}{{ if and (ne $column.Name $table.PrimaryKeyColumn.Name) $column.SimpleType }} c.SQLColumn("{{ $table.Name }}", "{{ $column.Name }}"),{{ end }}{{ end }} }	
 }
 
 func (t *{{ $table.Name }}) simpleVals() []interface{} {
-	return []interface{}{ {{ range $column := $table.Columns }}{{ if and (ne $column.Name $table.PrimaryKeyColumn.Name) $column.SimpleType }} t.{{ $column.Name }},{{ end }}{{ end }} }	
+	return []interface{}{ {{ range $column := $table.Columns }}{{ if and (ne $column.Name $table.PrimaryKeyColumn.Name) $column.SimpleType }}{{ if $column.JSON }} dr.JSON(&t.{{ $column.Name }}),{{ else }} t.{{ $column.Name }},{{ end }}{{ end }}{{ end }} }	
 }
 
 func (t *{{ $table.Name }}) create(c *Conn) error {
+	{{ if $table.Timestamps }}
+		now := time.Now()
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		t.UpdatedAt = now
+	{{ end }}
 	cols := t.simpleCols(c)
 	vals := t.simpleVals()
 	{{ range $column := $table.Columns }}
 		{{ if $column.Subrecord }}
 			{{ range $subcolumn := $column.Subcolumns }}
-				{{ if $subcolumn.SimpleType }}
-					if t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }}{{ $subcolumn.NonZeroCheck }} {
-						vals = append(vals, t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }})
-						cols = append(cols, c.SQLColumn("{{ $table.Name }}", "{{ $subcolumn.Name }}"))
-					}
+				{{ if $subcolumn.JSON }}
+					vals = append(vals, dr.JSON(&t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }}))
+					cols = append(cols, c.SQLColumn("{{ $table.Name }}", "{{ $subcolumn.Name }}"))
+				{{ else if $subcolumn.SimpleType }}
+					vals = append(vals, t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }})
+					cols = append(cols, c.SQLColumn("{{ $table.Name }}", "{{ $subcolumn.Name }}"))
 				{{ end }}
 			{{ end }}
 		{{ end }}
 	{{ end }}
 
-	pk ,err := createRecord(c, cols, vals, "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
+	pk ,err := dr.Create(c, cols, vals, "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
 	if err == nil {
-			t.{{ $table.PrimaryKeyColumn.Name }} = pk
-			t.cached_conn = c
+			t.{{ $table.PrimaryKeyColumn.Name }} = {{ $table.PrimaryKeyColumn.GoType }}(pk)
+			t.cached_conn = c.base()
 	}
 	return err
 }
 
 func (t *{{ $table.Name }}) update(c *Conn) error {
+	{{ if $table.Timestamps }}
+		t.UpdatedAt = time.Now()
+	{{ end }}
 	if c == nil {
-		return updateRecord(t.cached_conn, t.simpleCols(t.cached_conn), append(t.simpleVals(), t.{{ $table.PrimaryKeyColumn.Name }}), "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
+		return dr.Update(t.cached_conn, t.simpleCols(t.cached_conn), append(t.simpleVals(), t.{{ $table.PrimaryKeyColumn.Name }}), "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
 	} else {
-		return updateRecord(c, t.simpleCols(c), append(t.simpleVals(), t.{{ $table.PrimaryKeyColumn.Name }}), "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
+		return dr.Update(c, t.simpleCols(c), append(t.simpleVals(), t.{{ $table.PrimaryKeyColumn.Name }}), "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
 	}
 }
 
-func (t {{ $table.Name }}) Delete(c *Conn) error {
-	return deleteRecord(c, t.{{ $table.PrimaryKeyColumn.Name }}, "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
+{{ if $table.Variants }}
+	// Variant converts the {{ $table.Name }} to the variant its {{ $table.VariantColumn }} names, it's
+	// returned as it is when {{ $table.VariantColumn }} is empty
+	func (t {{ $table.Name }}) Variant() interface{} {
+		switch t.{{ $table.VariantColumn }} {
+		{{ range $table.Variants }}
+			case "{{ .Name }}":
+				return {{ .Name }}(t)
+		{{ end }}
+		}
+		return t
+	}
+{{ end }}
+
+{{ if $table.HasDependents }}
+	// Delete removes the {{ $table.Name }}, first deleting, nullifying or refusing to
+	// delete for the records depending on it, in one transaction
+	func (t {{ $table.Name }}) Delete(c *Conn) error {
+		return c.Transaction(func(c *Conn) error {
+			err := dr.DeleteDependents(c, &Schema, "{{ $table.Name }}", []int64{int64(t.{{ $table.PrimaryKeyColumn.Name }})})
+			if err != nil {
+				return err
+			}
+			return t.deleteRecord(c)
+		})
+	}
+{{ else }}
+	func (t {{ $table.Name }}) Delete(c *Conn) error {
+		return t.deleteRecord(c)
+	}
+{{ end }}
+
+func (t {{ $table.Name }}) deleteRecord(c *Conn) error {
+	{{ if $table.SoftDelete }}
+		return dr.SoftDelete(c, t.{{ $table.PrimaryKeyColumn.Name }}, "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}", "DeletedAt")
+	{{ else }}
+		return dr.Delete(c, t.{{ $table.PrimaryKeyColumn.Name }}, "{{ $table.Name }}", "{{ $table.PrimaryKeyColumn.Name }}")
+	{{ end }}
 }
 {{ end }}
 `
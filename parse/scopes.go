@@ -1,46 +1,49 @@
 package parse
 
-var genTemplate = `/*
-  This code was generated by the Doc ORM Generator and isn't meant to be edited.
-	If at all possible, please regenerate this file from your gp files instead of
-	attempting to edit it to add changes.
-*/
-
-package {{ .Name }}
+var genTemplate = `package {{ .Name }}
 
 import (
 	"database/sql"
 	"fmt"
-	"strings"
-	"time"
+
+	dr "github.com/acsellers/dr/runtime"
 )
 
 type Conn struct {
 	*sql.DB
 	AppConfig
-	reformat bool
-	returning bool
+	dialect dr.Dialect
 	Log *log.Logger
+	// tx is set on the Conn a Transaction runs with, outer is the Conn it
+	// was started from
+	tx *sql.Tx
+	outer *Conn
+	// undo puts records changed in the transaction back when it's rolled back
+	undo []func()
 	{{ range .Tables }}
 		{{ .Name }} *{{ .Name }}Scope
 	{{ end }}
+	{{ range .Variants }}
+		{{ .Name }} *{{ .Name }}Scope
+	{{ end }}
 }
 
 func Open(driverName, dataSourceName string) (*Conn, error) {
-	c := &Conn{}
-	if driverName == "postgres" {
-		c.reformat = true
-		c.returning = true
-	}
+	{{ if .Config.Dialects }}
+		switch driverName {
+		case {{ range $i, $name := .Config.DriverNames }}{{ if $i }}, {{ end }}"{{ $name }}"{{ end }}:
+		default:
+			return nil, fmt.Errorf("%s is not one of the dialects {{ .Name }} was generated for", driverName)
+		}
+	{{ end }}
+	c := &Conn{dialect: dr.DialectFor(driverName)}
 	var err error
 	c.DB, err = sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 	c.AppConfig = NewAppConfig(driverName)
-	{{ range .Tables }}
-	c.{{ .Name }} = New{{ .Name }}Scope(c)
-	{{ end }}
+	c.initScopes()
 	return c, nil
 }
 
@@ -48,347 +51,81 @@ func (c *Conn) Clone() *Conn {
 	c2 := &Conn{
 		DB: c.DB,
 		AppConfig: c.AppConfig,
-		reformat: c.reformat,
-		returning: c.returning,
+		dialect: c.dialect,
 		Log: c.Log,
 	}
-	{{ range .Tables }}
-	c2.{{ .Name }} = New{{ .Name }}Scope(c2)
-	{{ end }}
+	c2.initScopes()
 	return c2
 }
 
-
-
-{{ range $table := .Tables }}
-type {{ .Name }}Scope struct {
-	*internalScope
-}
-
-func New{{ .Name }}Scope(c *Conn) *{{ .Name }}Scope {
-	return &{{ .Name }}Scope {
-		&internalScope{
-			conn:          c,
-			table:         c.SQLTable("{{ .Name }}"),
-			currentColumn: c.SQLTable("{{ .Name }}") + "." + c.SQLColumn("{{ .Name }}", "{{ .PrimaryKeyColumn.Name }}"),
-		},
-	}
-}
-
-func (scope *{{ .Name }}Scope) SetConn(conn *Conn) Scope {
-	scope.conn = conn
-	return scope
-}
-
-func ({{ .Name }}Scope) scopeName() string {
-	return "{{ .Name }}"
-}
-
-// basic conditions
-func (scope *{{ .Name }}Scope) Eq(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Eq(val)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Neq(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Neq(val)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Gt(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Gt(val)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Gte(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Gte(val)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Lt(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Lt(val)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Lte(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Lte(val)
-	return scope
-}
-
-
-// multi value conditions
-func (scope *{{ .Name }}Scope) Between(lower, upper interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Between(lower, upper)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) In(vals ...interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.In(vals...)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) NotIn(vals ...interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.NotIn(vals...)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Like(str string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Like(str)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Where(sql string, vals ...interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.internalScope.Where(sql, vals...)
-	return scope
-}
-
-// ordering conditions
-func (scope *{{ .Name }}Scope) Order(ordering string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-	scope.order = append(scope.order, ordering)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Desc() *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.order = append(scope.order, scope.currentColumn+" DESC")
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Asc() *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.order = append(scope.order, scope.currentColumn+" ASC")
-	return scope
-}
-
-// Join funcs
-func (scope *{{ .Name }}Scope)	OuterJoin(things ...Scope) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.internalScope.outerJoin("{{ .Name }}", things...)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope)	InnerJoin(things ...Scope) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.internalScope.innerJoin("{{ .Name }}", things...)
-	return scope
-}
-
-// JoinBy allows you to specify the exact join SQL statment for one or more
-// tables. You can also pass the Scope objects that you are manually joining, 
-// which are recorded for future Joining to work off of or to be Include'd.
-func (scope *{{ .Name }}Scope)	JoinBy(joins string, joinedScopes ...Scope) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.joins = append(scope.joins, joins)
-	scope.joinedScopes = append(scope.joinedScopes, joinedScopes...)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) joinable() string {
-	if scope.tableAlias != "" {
-		return fmt.Sprintf(
-			"%s AS %s",
-			scope.conn.SQLTable("{{ .Name }}"),
-			scope.tableAlias,
-		)
-	}
-	return scope.conn.SQLTable("{{ .Name }}")
-}
-
-func (scope *{{ .Name }}Scope) tableName() string {
-	if scope.tableAlias != "" {
-		return scope.tableAlias
-	}
-	return scope.conn.SQLTable("{{ .Name }}")
-}
-
-// aggregation filtering
-func (scope *{{ .Name }}Scope) Having(sql string, vals ...interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.having = append(scope.having, sql)
-	scope.haveVals = append(scope.haveVals, vals...)
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) GroupBySQL(cols ...string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.groupBy = append(scope.groupBy, cols...)
-	return scope
+func (c *Conn) initScopes() {
+	{{ range .Tables }}
+		c.{{ .Name }} = New{{ .Name }}Scope(c)
+		dr.Share(c.{{ .Name }})
+	{{ end }}
+	{{ range .Variants }}
+		c.{{ .Name }} = New{{ .Name }}Scope(c)
+		dr.Share(c.{{ .Name }})
+	{{ end }}
 }
 
-// Result count filtering
-func (scope *{{ .Name }}Scope) Limit(limit int64) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.limit = &limit
-	return scope
-}
+{{ range .Tables }}
+	{{ template "scope" . }}
+{{ end }}
 
-func (scope *{{ .Name }}Scope) Offset(offset int64) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
+{{ range .Tables }}
+	{{ template "relation_methods" . }}
+{{ end }}
 
-	scope.offset = &offset
-	return scope
-}
+{{ range .Variants }}
+	{{ template "variant" . }}
+{{ end }}
 
-// misc scope operations
-func (scope *{{ .Name }}Scope) Clear() *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
+{{ range .Enums }}
+	{{ template "enum" . }}
+{{ end }}
 
-	goods := []condition{}
-	for _, cond := range scope.conditions {
-		if !strings.HasSuffix(cond.column, "."+scope.currentColumn) {
-			goods = append(goods, cond)
-		}
-	}
-	scope.conditions = goods
-	return scope
+{{ define "scope" }}{{ $table := . }}
+type {{ .Name }}Scope struct {
+	dr.Scope[*{{ .Name }}Scope, *Conn]
 }
 
-func (scope *{{ .Name }}Scope) ClearAll() *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.conditions = []condition{}
-	return scope
+var tableFor{{ .Name }} = &dr.Table[*{{ .Name }}Scope, *Conn]{
+	Name: "{{ .Name }}",
+	PrimaryKey: "{{ .PrimaryKeyColumn.Name }}",
+	{{ if .SoftDelete }}DeletedAt: "DeletedAt",{{ end }}
+	Schema: &Schema,
+	Wrap: func(s dr.Scope[*{{ .Name }}Scope, *Conn]) *{{ .Name }}Scope {
+		return &{{ .Name }}Scope{s}
+	},
 }
 
-func (scope *{{ .Name }}Scope) Base() *{{ .Name }}Scope {
-	return New{{ .Name }}Scope(scope.conn)
+func New{{ .Name }}Scope(c *Conn) *{{ .Name }}Scope {
+	return tableFor{{ .Name }}.New(c)
 }
 
 // struct saving and loading
 func (scope *{{ .Name }}Scope) Find(id interface{}) ({{ .Name }}, error) {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
 	return scope.And(scope.Base().Eq(id)).Retrieve()
 }
 
 func (scope *{{ .Name }}Scope) Retrieve() ({{ .Name }}, error) {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	val := &{{ .Name }}{}
-	m := mapperFor{{ .Name }}(scope)
-	m.Current = &val
-	scope.columns = m.Columns
-
-	ss, vv := scope.QuerySQL()
-	row := scope.conn.QueryRow(ss, vv...)
-	err := row.Scan(m.Scanners...)
-	if err != nil {
-		err = fmt.Errorf("SQL: %s\n%s", ss, err.Error())
-	}
-	val.cached_conn = scope.conn
-	return *val, err
-
+	val, err := dr.Retrieve(scope, fieldsFor{{ .Name }})
+	val.cached_conn = scope.Conn().base()
+	return val, err
 }
 
 func (scope *{{ .Name }}Scope) RetrieveAll() ([]{{ .Name }}, error) {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	m := mapperFor{{ .Name }}(scope)
-	scope.columns = m.Columns
-
-	ss, vv := scope.QuerySQL()
-	rows, err := scope.conn.Query(ss, vv...)
-	if err != nil {
-		err = fmt.Errorf("SQL: %s\n%s", ss, err.Error())
-		return []{{ .Name }}{}, err
-	}
-	defer rows.Close()
-
-	vals := []{{ .Name }}{}
-
-	for rows.Next() {
-		temp := &{{ .Name }}{}
-		m.Current = &temp
-		err = rows.Scan(m.Scanners...)
-		if err != nil {
-			return []{{ .Name }}{}, err
-		}
-		temp.cached_conn = scope.conn
-		vals = append(vals, *temp)
+	vals, err := dr.RetrieveAll(scope, fieldsFor{{ .Name }})
+	for i := range vals {
+		vals[i].cached_conn = scope.Conn().base()
 	}
-
-	return vals, nil
+	return vals, err
 }
 
 func (scope *{{ .Name }}Scope) SaveAll(vals []{{ .Name }}) error {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
 	for i := range vals {
-		err := vals[i].Save(scope.conn)
+		err := vals[i].Save(scope.Conn())
 		if err != nil {
 			return err
 		}
@@ -396,394 +133,74 @@ func (scope *{{ .Name }}Scope) SaveAll(vals []{{ .Name }}) error {
 	return nil
 }
 
-
-// Scope attribute updating
-func (scope *{{ .Name }}Scope) Set(val interface{}) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	if scope.updates == nil {
-		scope.updates = make(map[string]interface{})
-	}
-	colName := strings.TrimPrefix(scope.currentColumn, scope.conn.SQLTable("{{ $table.Name }}")+".")
-	scope.updates[colName] = val
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Update() error {
-	sql, vals := scope.UpdateSQL()
-	_, err := scope.conn.Exec(sql, vals...)
-	return err
-}
-
-// subset plucking
-func (scope *{{ .Name }}Scope) Pick(sql string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.isDistinct = false
-	scope.currentColumn = sql
-
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) PluckStruct(result interface{}) error {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	return scope.internalScope.pluckStruct("{{ .Name }}", result)
-}
-
-// direct sql
-func (scope *{{ .Name }}Scope) Count() int64 {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	return scope.{{ .PrimaryKeyColumn.Name }}().Distinct().CountOf()
-}
-
-func (scope *{{ .Name }}Scope) CountBy(sql string) int64 {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.columns = []string{sql}
-	ss, sv := scope.QuerySQL()
-	var value int64
-	row := scope.conn.QueryRow(ss, sv...)
-	err := row.Scan(&value)
-	if err != nil {
-		panic(err)
-	}
-
-	return value
-}
-
-func (scope *{{ .Name }}Scope) CountOf() int64 {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	if scope.isDistinct {
-		return scope.CountBy(fmt.Sprintf("COUNT(DISTINCT %s)", scope.currentColumn))
-	}
-	return scope.CountBy(fmt.Sprintf("COUNT(%s)", scope.currentColumn))
-}
-
-func (scope *{{ .Name }}Scope) UpdateBySQL(sql string, vals ...interface{}) error {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.columns = []string{""}
-	ss, sv := scope.query()
-	ss = strings.TrimPrefix(ss, "SELECT FROM "+scope.table)
-	ss = fmt.Sprintf("UPDATE %s SET %s %s", scope.table, sql, ss)
-	_, err := scope.conn.Exec(ss, append(vals, sv...))
-	return err
-}
-
-func (scope *{{ .Name }}Scope) Delete() error {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	sql, cv := scope.DeleteSQL()
-	if sql == "" {
-		if err, ok := cv[0].(error); ok {
-			return err
-		} else {
-			return fmt.Errorf("Unspecified Error in DeleteSQL()")
-		}
-	}
-	_, err := scope.conn.Exec(sql, cv...)
-	if err != nil {
-		return fmt.Errorf("Encountered error: %v\nSQL: %s %v", err, sql, cv)
-	}
-	return nil
-}
-func (scope {{ .Name }}Scope) condSQL() (string, []interface{}) {
-	conds := []string{}
-	vals := []interface{}{}
-	for _, condition := range scope.conditions {
-		conds = append(conds, condition.ToSQL())
-		vals = append(vals, condition.vals...)
-	}
-	return strings.Join(conds, " AND "), vals
-}
-
-// special
-func (scope {{ .Name }}Scope) Clone() *{{ .Name }}Scope {
-	return &scope
-}
-
-func (scope {{ .Name }}Scope) QuerySQL() (string, []interface{}) {
-	return scope.query()
-}
-
-func (scope {{ .Name }}Scope) UpdateSQL() (string, []interface{}) {
-	sql := fmt.Sprintf(
-		"UPDATE %s SET ",
-		scope.conn.SQLTable("{{ $table.Name }}"),
-	)
-
-	updates := []string{}
-	vals := []interface{}{}
-	for col, val := range scope.updates {
-		updates = append(updates, col + " = ?")
-		vals = append(vals, val)
-	}
-	sql += strings.Join(updates, ", ")
-
-	if len(scope.conditions) > 0 {
-		cs, cv := scope.conditionSQL()
-		sql += " WHERE " + cs
-		vals = append(vals, cv...)
-	}
-	return sql, vals
-}
-
-func (scope {{ .Name }}Scope) DeleteSQL() (string, []interface{}) {
-	delScope := scope.Clone()
-	if len(scope.joins) > 0 || len(scope.having) > 0 {
-		ids, err := scope.{{ .PrimaryKeyColumn.Name }}().Distinct().PluckInt()
-		if err != nil {
-			return "", []interface{}{err}
-		}
-		delScope = delScope.ClearAll().{{ .PrimaryKeyColumn.Name }}().In(ids)
-	}
-	cs, cv := scope.condSQL()
-
-	if cs == "" {
-		sql := fmt.Sprintf("DELETE FROM %s",scope.table)
-		return sql, []interface{}{}
-	} else {
-		sql := fmt.Sprintf("DELETE FROM %s WHERE %s",scope.table, cs)
-		return sql, cv
-	}
-}
-
-// As sets a column alias
-func (scope *{{ .Name }}Scope) As(alias string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.currentAlias = alias
-	return scope
-}
-
-// Alias sets a table alias
-func (scope *{{ .Name }}Scope) Alias(alias string) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.tableAlias = alias
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Distinct() *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	scope.isDistinct = true
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) And(scopes ...Scope) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
-	}
-
-	for _, is := range scopes {
-		scope.conditions = append(scope.conditions, is.conds()...)
-	}
-	return scope
-}
-
-func (scope *{{ .Name }}Scope) Or(scopes ...Scope) *{{ .Name }}Scope {
-	if scope.conn.{{ .Name }} == scope {
-		scope = &{{.Name}}Scope{scope.internalScope.Clone()}
+{{ if .HasDependents }}
+	// Delete removes the records in the scope, first deleting, nullifying or
+	// refusing to delete for the records depending on them, in one transaction
+	func (scope *{{ .Name }}Scope) Delete() error {
+		return scope.Conn().Transaction(func(c *Conn) error {
+			return scope.Clone().SetConn(c).Scope.Delete()
+		})
 	}
+{{ end }}
 
-	c := condition{}
-	ors := []string{}
-	for _, oscope := range scopes {
-		cond := []string{}
-		conds := oscope.conds()
-		if len(conds) == 1 {
-			c.vals = append(c.vals, conds[0].vals...)
-			ors = append(ors, conds[0].ToSQL())
-		} else {
-			for _, ocond := range conds {
-				c.vals = append(c.vals, ocond.vals...)
-				cond = append(cond, ocond.ToSQL())
-			}
-			ors = append(ors, "("+strings.Join(cond, " AND ")+")")
+{{ if .Variants }}
+	// RetrieveVariants retrieves the records as the variant their {{ .VariantColumn }} names,
+	// or as a {{ .Name }} when it's empty
+	func (scope *{{ .Name }}Scope) RetrieveVariants() ([]interface{}, error) {
+		vals, err := scope.RetrieveAll()
+		variants := make([]interface{}, len(vals))
+		for i, val := range vals {
+			variants[i] = val.Variant()
 		}
+		return variants, err
 	}
-	c.cond = "(" + strings.Join(ors, " OR ") + ")"
-
-	scope.conditions = append(scope.conditions, c)
-
-	return scope
-}
+{{ end }}
 
 {{ range $column := .Columns }}
 	{{ if $column.SimpleType }}
 		func (scope *{{ $table.Name }}Scope) {{ $column.Name }}(eq ...interface{}) *{{ $table.Name }}Scope {
-			if scope.conn.{{ $table.Name }} == scope {
-				scope = &{{ $table.Name }}Scope{scope.internalScope.Clone()}
-			}
-
-			scope.currentColumn =
-				scope.tableName() +
-					"." +
-					scope.conn.SQLColumn(scope.scopeName(), "{{ $column.Name }}")
-			scope.currentAlias = ""
-			scope.isDistinct = false
-			if len(eq) > 0 {
-				for _, ev := range eq {
-					scope.Eq(ev)
-				}
-			}
-			return scope
-		}
-
-		type mapper{{ $table.Name }}To{{ $column.Name }} struct {
-			Mapper *mapper{{ $table.Name }}
+			return scope.Column("{{ $column.Name }}", eq...)
 		}
-
-		{{ if eq $column.GoType "int" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "int_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "string" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "string_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "&{time Time}" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "time_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "bool" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "bool_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "float64" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "float64_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "float32" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "float32_mapper" $column }}
-			}
-		{{ else if eq $column.GoType "[]byte" }}
-			func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-				{{ template "byte_mapper" $column }}
-			}
-		{{ end }}
 	{{ end }}
-	{{ if $column.Subrecord }}
-		/*
-			type scope{{ $table.Name }}{{ $column.Subrecord.Name }} struct {
-				scope {{ $table.Name }}Scope
-			}
-			type {{ $table.Name }}{{ $column.Subrecord.Name }}Scope interface {
-				Include() {{ $table.Name }}Scope
-				{{ range $subcolumn := $column.Subcolumns }}
-					{{ $subcolumn.Name }}() {{ $table.Name }}Scope
-				{{ end }}
-			}
-
-			func (scope scope{{ $table.Name }}) {{ $column.Subrecord.Name }}() {{ $table.Name }}{{ $column.Subrecord.Name }}Scope {
-				return scope{{ $table.Name }}{{ $column.Subrecord.Name }}{scope}
-			}
-
-			func (scope scope{{ $table.Name }}{{ $column.Subrecord.Name }}) Include() {{ $table.Name }}Scope {
-				scope.scope.includes = append(scope.scope.includes, "{{ $column.Subrecord.Name }}")
-				return scope.scope
-			}
-		
-			{{ range $subcolumn := $column.Subcolumns }}
-
-				func (scope scope{{ $table.Name }}{{ $column.Subrecord.Name }}) {{ $subcolumn.Name }}() {{ $table.Name }}Scope {
-					scope.scope.currentColumn = 	
-						scope.scope.conn.SQLTable("{{ $table.Name }}") +
-							"." +
-							scope.scope.conn.SQLColumn("{{ $table.Name }}", "{{ $subcolumn.Name }}")
-					scope.scope.currentAlias = ""
-					scope.scope.isDistinct = false
-					return scope.scope
-				}
-
-				type mapper{{ $table.Name }}To{{ $subcolumn.Name }} struct {
-					Mapper *mapper{{ $table.Name }}
-				}
-
-				{{ if eq $subcolumn.GoType "int" }}
-					func (m mapper{{ $table.Name }}To{{ $subcolumn.Name }}) Scan(v interface{}) error {
-						{{ template "int_mapper" $subcolumn }}
-					}
-				{{ else if eq $subcolumn.GoType "string" }}
-					func (m mapper{{ $table.Name }}To{{ $subcolumn.Name }}) Scan(v interface{}) error {
-						{{ template "string_mapper" $subcolumn }}
-					}
-				{{ else if eq $subcolumn.GoType "&{time Time}" }}
-					func (m mapper{{ $table.Name }}To{{ $subcolumn.Name }}) Scan(v interface{}) error {
-						{{ template "time_mapper" $subcolumn }}
-					}
-				{{ else if eq $column.GoType "[]byte" }}
-					func (m mapper{{ $table.Name }}To{{ $column.Name }}) Scan(v interface{}) error {
-						{{ template "byte_mapper" $column }}
-					}
-				{{ end }}
-			{{ end }}
-		*/
+	{{ if $column.Enum }}
+		// {{ $column.Name }}Named filters by the names of {{ $column.Enum.Name }} values
+		func (scope *{{ $table.Name }}Scope) {{ $column.Name }}Named(names ...string) *{{ $table.Name }}Scope {
+			return scope.Column("{{ $column.Name }}").In(names)
+		}
 	{{ end }}
 {{ end }}
 
-type mapper{{ .Name }} struct {
-	Current  **{{ .Name }}
-	Columns  []string
-	Scanners []interface{}
-}
-
-func mapperFor{{ .Name }}(scope  *{{ .Name }}Scope) *mapper{{ .Name }} {
-	m := &mapper{{ .Name }}{}
-	m.Columns = []string{ {{ range $column := .Columns }} {{ if $column.SimpleType }} scope.tableName() + "." + scope.conn.SQLColumn("{{ $table.Name }}", "{{ $column.Name }}"), {{ end }} {{ end }} }
-	m.Scanners = []interface{}{
+func fieldsFor{{ .Name }}(t *{{ .Name }}) []dr.Field {
+	return []dr.Field{
 		{{ range $column := .Columns }}
 			{{ if $column.SimpleType }}
-				mapper{{ $table.Name }}To{{ $column.Name }}{m},
+				{{ if $column.JSON }}
+					{Column: "{{ $column.Name }}", Dest: dr.JSON(&t.{{ $column.Name }})},
+				{{ else if and $column.Custom (not $column.MustNull) }}
+					{Column: "{{ $column.Name }}", Dest: &t.{{ $column.Name }}},
+				{{ else }}
+					{Column: "{{ $column.Name }}", Dest: dr.Scanner(&t.{{ $column.Name }})},
+				{{ end }}
+			{{ end }}
+			{{ if $column.Subrecord }}
+				{{ range $subcolumn := $column.Subcolumns }}
+					{{ if $subcolumn.SimpleType }}
+						{{ if $subcolumn.JSON }}
+							{Column: "{{ $subcolumn.Name }}", Dest: dr.JSON(&t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }})},
+						{{ else if and $subcolumn.Custom (not $subcolumn.MustNull) }}
+							{Column: "{{ $subcolumn.Name }}", Dest: &t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }}},
+						{{ else }}
+							{Column: "{{ $subcolumn.Name }}", Dest: dr.Scanner(&t.{{ $column.Subrecord.Name }}.{{ $subcolumn.Name }})},
+						{{ end }}
+					{{ end }}
+				{{ end }}
 			{{ end }}
 		{{ end }}
 	}
-
-	{{ range $column := .Columns }}
-		{{ if $column.Subrecord }}
-			if drStringArray(scope.includes).Includes("{{ $column.Subrecord.Name }}") {
-				m.Columns = append(m.Columns,
-					{{ range $subcolumn := $column.Subcolumns }}scope.conn.SQLTable("{{ $table.Name }}") + "." + scope.conn.SQLColumn("{{ $table.Name }}", "{{ $subcolumn.Name }}"),{{ end }}
-				)
-
-				m.Scanners = append(m.Scanners,
-					{{ range $subcolumn := $column.Subcolumns }}mapper{{ $table.Name }}To{{ $subcolumn.Name }}{m},{{ end }}
-				)
-			}
-		{{ end }}
-	{{ end }}
-	return m
 }
 {{ end }}
 
-{{ range $table := .Tables }}
+{{ define "relation_methods" }}{{ $table := . }}
 	{{ if $table.HasRelationship "ParentHasMany" }}
 		{{ range $relate := .Relations }}
 			{{ if $relate.IsHasMany }}
@@ -791,14 +208,13 @@ func mapperFor{{ .Name }}(scope  *{{ .Name }}Scope) *mapper{{ .Name }} {
 					return t.{{ $relate.Name }}Scope(c).RetrieveAll()
 				}
 				func (t {{ $table.Name }}) {{ $relate.Name }}Scope(c *Conn) *{{ $relate.Table }}Scope {
-					return c.{{ $relate.Table }}.{{ $relate.ColumnName }}().Eq(t.{{ $table.PrimaryKeyColumn.Name }})
+					return c.{{ $relate.Table }}.{{ $relate.ColumnName }}().Eq(t.{{ $table.PrimaryKeyColumn.Name }}){{ if $relate.As }}.{{ $relate.TypeColumn }}().Eq("{{ $table.Name }}"){{ end }}
 				}
 				func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}Scope() *{{ $relate.Table }}Scope {
 					{{ if eq $relate.Name $relate.Table }}
-						return &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}).internal()}
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}))
 					{{ else }}
-						rs := &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}.Alias("{{ $relate.Name }}")).internal()}
-						return rs.Alias("{{ $relate.Name }}")
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}.Alias("{{ $relate.Name }}")))
 					{{ end }}
 				}
 			{{ end }}
@@ -811,14 +227,13 @@ func mapperFor{{ .Name }}(scope  *{{ .Name }}Scope) *mapper{{ .Name }} {
 					return t.{{ $relate.Name }}Scope(c).Retrieve()
 				}
 				func (t {{ $table.Name }}) {{ $relate.Name }}Scope(c *Conn) *{{ $relate.Table }}Scope {
-					return c.{{ $relate.Table }}.Eq(t.{{ $relate.Name }}ID)
+					return c.{{ $relate.Table }}.Eq(t.{{ $relate.OperativeColumn }})
 				}
 				func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}Scope() *{{ $relate.Table }}Scope {
 					{{ if eq $relate.Name $relate.Table }}
-						return &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}).internal()}
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}))
 					{{ else }}
-						rs := &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}.Alias("{{ $relate.Name }}")).internal()}
-						return rs.Alias("{{ $relate.Name }}")
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}.Alias("{{ $relate.Name }}")))
 					{{ end }}
 				}
 			{{ end }}
@@ -835,10 +250,9 @@ func mapperFor{{ .Name }}(scope  *{{ .Name }}Scope) *mapper{{ .Name }} {
 				}
 				func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}Scope() *{{ $relate.Table }}Scope {
 					{{ if eq $relate.Name $relate.Table }}
-						return &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}).internal()}
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}))
 					{{ else }}
-						rs := &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}.Alias("{{ $relate.Name }}")).internal()}
-						return rs.Alias("{{ $relate.Name }}")
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}.Alias("{{ $relate.Name }}")))
 					{{ end }}
 				}
 			{{ end }}
@@ -851,18 +265,218 @@ func mapperFor{{ .Name }}(scope  *{{ .Name }}Scope) *mapper{{ .Name }} {
 					return t.{{ $relate.Name }}Scope(c).Retrieve()
 				}
 				func (t {{ $table.Name }}) {{ $relate.Name }}Scope(c *Conn) *{{ $relate.Table }}Scope {
-					return c.{{ $relate.Table }}.Eq(t.{{ $relate.Name }}ID)
+					return c.{{ $relate.Table }}.Eq(t.{{ $relate.OperativeColumn }})
 				}
 				func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}Scope() *{{ $relate.Table }}Scope {
 					{{ if eq $relate.Name $relate.Table }}
-						return &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}).internal()}
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}))
 					{{ else }}
-						rs := &{{ $relate.Table }}Scope{scope.InnerJoin(scope.conn.{{ $relate.Table }}.Alias("{{ $relate.Name }}")).internal()}
-						return rs.Alias("{{ $relate.Name }}")
+						return tableFor{{ $relate.Table }}.From(scope.InnerJoin(scope.Conn().{{ $relate.Table }}.Alias("{{ $relate.Name }}")))
+					{{ end }}
+				}
+			{{ end }}
+		{{ end }}
+	{{ end }}
+	{{ if $table.HasRelationship "Polymorphic" }}
+		{{ range $relate := .Relations }}
+			{{ if $relate.IsPolymorphic }}
+				// {{ $relate.Name }} loads the record the {{ $table.Name }} belongs to, which is one of
+				// {{ range $i, $type := $relate.PolymorphicTypes }}{{ if $i }}, {{ end }}{{ $type.Name }}{{ end }}
+				func (t {{ $table.Name }}) {{ $relate.Name }}(c *Conn) (interface{}, error) {
+					switch t.{{ $relate.TypeColumn }} {
+					{{ range $type := $relate.PolymorphicTypes }}
+						case "{{ $type.Name }}":
+							return c.{{ $type.Name }}.Eq(t.{{ $relate.OperativeColumn }}).Retrieve()
 					{{ end }}
+					}
+					return nil, fmt.Errorf("{{ $table.Name }} can't belong to a %q", t.{{ $relate.TypeColumn }})
+				}
+
+				// Set{{ $relate.Name }} makes the {{ $table.Name }} belong to record
+				func (t *{{ $table.Name }}) Set{{ $relate.Name }}(record interface{}) error {
+					switch r := record.(type) {
+					{{ range $type := $relate.PolymorphicTypes }}
+						case {{ $type.Name }}:
+							t.{{ $relate.OperativeColumn }}, t.{{ $relate.TypeColumn }} = int(r.{{ $type.PrimaryKeyColumn.Name }}), "{{ $type.Name }}"
+						case *{{ $type.Name }}:
+							t.{{ $relate.OperativeColumn }}, t.{{ $relate.TypeColumn }} = int(r.{{ $type.PrimaryKeyColumn.Name }}), "{{ $type.Name }}"
+					{{ end }}
+					default:
+						return fmt.Errorf("{{ $table.Name }} can't belong to a %T", record)
+					}
+					return nil
+				}
+
+				{{ range $type := $relate.PolymorphicTypes }}
+					func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}Is{{ $type.Name }}() *{{ $table.Name }}Scope {
+						return scope.{{ $relate.TypeColumn }}().Eq("{{ $type.Name }}")
+					}
+					func (scope *{{ $table.Name }}Scope) {{ $relate.Name }}{{ $type.Name }}Scope() *{{ $type.Name }}Scope {
+						return tableFor{{ $type.Name }}.From(scope.InnerJoin(scope.Conn().{{ $type.Name }}.Alias("{{ $relate.Name }}")))
+					}
+				{{ end }}
+
+				// Load{{ $table.Name }}{{ $relate.Name }} loads the {{ $relate.Name }} of each record with a
+				// query for each type, loaded[i] is nil when records[i]'s is missing
+				func Load{{ $table.Name }}{{ $relate.Name }}(c *Conn, records []{{ $table.Name }}) ([]interface{}, error) {
+					loaded := make([]interface{}, len(records))
+					ids := map[string][]int{}
+					for _, record := range records {
+						ids[record.{{ $relate.TypeColumn }}] = append(ids[record.{{ $relate.TypeColumn }}], record.{{ $relate.OperativeColumn }})
+					}
+					{{ range $type := $relate.PolymorphicTypes }}
+						if len(ids["{{ $type.Name }}"]) > 0 {
+							found, err := c.{{ $type.Name }}.{{ $type.PrimaryKeyColumn.Name }}().In(ids["{{ $type.Name }}"]).RetrieveAll()
+							if err != nil {
+								return nil, err
+							}
+							byID := map[int]{{ $type.Name }}{}
+							for _, f := range found {
+								byID[int(f.{{ $type.PrimaryKeyColumn.Name }})] = f
+							}
+							for i, record := range records {
+								if f, ok := byID[record.{{ $relate.OperativeColumn }}]; ok && record.{{ $relate.TypeColumn }} == "{{ $type.Name }}" {
+									loaded[i] = f
+								}
+							}
+						}
+					{{ end }}
+					return loaded, nil
 				}
 			{{ end }}
 		{{ end }}
 	{{ end }}
 {{ end }}
+
+{{ define "variant" }}{{ $table := .Table }}
+type {{ .Name }}Scope struct {
+	dr.Scope[*{{ .Name }}Scope, *Conn]
+}
+
+var tableFor{{ .Name }} = &dr.Table[*{{ .Name }}Scope, *Conn]{
+	Name: "{{ $table.Name }}",
+	PrimaryKey: "{{ $table.PrimaryKeyColumn.Name }}",
+	{{ if $table.SoftDelete }}DeletedAt: "DeletedAt",{{ end }}
+	TypeColumn: "{{ $table.VariantColumn }}",
+	Variant: "{{ .Name }}",
+	Schema: &Schema,
+	Wrap: func(s dr.Scope[*{{ .Name }}Scope, *Conn]) *{{ .Name }}Scope {
+		return &{{ .Name }}Scope{s}
+	},
+}
+
+func New{{ .Name }}Scope(c *Conn) *{{ .Name }}Scope {
+	return tableFor{{ .Name }}.New(c)
+}
+
+func (scope *{{ .Name }}Scope) Find(id interface{}) ({{ .Name }}, error) {
+	return scope.And(scope.Base().Eq(id)).Retrieve()
+}
+
+func (scope *{{ .Name }}Scope) Retrieve() ({{ .Name }}, error) {
+	val, err := dr.Retrieve(scope, fieldsFor{{ .Name }})
+	val.cached_conn = scope.Conn().base()
+	return val, err
+}
+
+func (scope *{{ .Name }}Scope) RetrieveAll() ([]{{ .Name }}, error) {
+	vals, err := dr.RetrieveAll(scope, fieldsFor{{ .Name }})
+	for i := range vals {
+		vals[i].cached_conn = scope.Conn().base()
+	}
+	return vals, err
+}
+
+func (scope *{{ .Name }}Scope) SaveAll(vals []{{ .Name }}) error {
+	for i := range vals {
+		err := vals[i].Save(scope.Conn())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+{{ if $table.HasDependents }}
+	// Delete removes the records in the scope, first deleting, nullifying or
+	// refusing to delete for the records depending on them, in one transaction
+	func (scope *{{ .Name }}Scope) Delete() error {
+		return scope.Conn().Transaction(func(c *Conn) error {
+			return scope.Clone().SetConn(c).Scope.Delete()
+		})
+	}
+{{ end }}
+
+{{ range $column := $table.Columns }}
+	{{ if $column.SimpleType }}
+		func (scope *{{ $.Name }}Scope) {{ $column.Name }}(eq ...interface{}) *{{ $.Name }}Scope {
+			return scope.Column("{{ $column.Name }}", eq...)
+		}
+	{{ end }}
+	{{ if $column.Enum }}
+		// {{ $column.Name }}Named filters by the names of {{ $column.Enum.Name }} values
+		func (scope *{{ $.Name }}Scope) {{ $column.Name }}Named(names ...string) *{{ $.Name }}Scope {
+			return scope.Column("{{ $column.Name }}").In(names)
+		}
+	{{ end }}
+{{ end }}
+
+func fieldsFor{{ .Name }}(t *{{ .Name }}) []dr.Field {
+	return fieldsFor{{ $table.Name }}((*{{ $table.Name }})(t))
+}
+
+func (t {{ .Name }}) Scope() *{{ .Name }}Scope {
+	return t.cached_conn.{{ .Name }}.{{ $table.PrimaryKeyColumn.Name }}().Eq(t.{{ $table.PrimaryKeyColumn.Name }})
+}
+
+func (t {{ .Name }}) ToScope(c *Conn) *{{ .Name }}Scope {
+	return c.{{ .Name }}.{{ $table.PrimaryKeyColumn.Name }}().Eq(t.{{ $table.PrimaryKeyColumn.Name }})
+}
+
+// Save stores the {{ .Name }} as a {{ $table.Name }} with {{ $table.VariantColumn }} set to "{{ .Name }}"
+func (t *{{ .Name }}) Save(c *Conn) error {
+	t.{{ $table.VariantColumn }} = "{{ .Name }}"
+	return (*{{ $table.Name }})(t).Save(c)
+}
+
+func (t {{ .Name }}) Delete(c *Conn) error {
+	return {{ $table.Name }}(t).Delete(c)
+}
+{{ end }}
+
+{{ define "enum" }}
+const (
+	{{ range $i, $value := .Values }}
+		{{ $.Name }}{{ $value.Name }}{{ if eq $i 0 }} {{ $.Name }} = iota{{ end }}
+	{{ end }}
+)
+
+var enumFor{{ .Name }} = dr.Enum{
+	Type: "{{ .Name }}",
+	Names: []string{ {{ range .Values }}{{ printf "%q" .Stored }}, {{ end }} },
+}
+
+func Parse{{ .Name }}(name string) ({{ .Name }}, error) {
+	i, err := enumFor{{ .Name }}.Parse(name)
+	return {{ .Name }}(i), err
+}
+
+func (e {{ .Name }}) String() string {
+	return enumFor{{ .Name }}.Name(int(e))
+}
+
+func (e {{ .Name }}) Valid() bool {
+	return enumFor{{ .Name }}.Valid(int(e))
+}
+
+func (e *{{ .Name }}) Scan(v interface{}) error {
+	i, err := enumFor{{ .Name }}.Scan(v)
+	*e = {{ .Name }}(i)
+	return err
+}
+
+func (e {{ .Name }}) Value() (driver.Value, error) {
+	return enumFor{{ .Name }}.Value(int(e))
+}
+{{ end }}
 `
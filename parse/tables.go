@@ -1,40 +1,85 @@
 package parse
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
-	"log"
+	"io/ioutil"
 	"os"
-	"regexp"
-	"strings"
-
-	"golang.org/x/tools/imports"
-)
-
-var (
-	subrecordDef = regexp.MustCompile(`type ([a-zA-Z0-9]+) subrecord {`)
-	mixinDef     = regexp.MustCompile(`type ([a-zA-Z0-9]+) mixin {`)
-	tableDef     = regexp.MustCompile(`type ([a-zA-Z0-9]+) table {`)
-	relationDef  = regexp.MustCompile(`relation {`)
-	indexDef     = regexp.MustCompile(`index {`)
-	codeStart    = regexp.MustCompile(`^[a-zA-Z\[]`)
+	"path/filepath"
+	"reflect"
+	"sort"
 )
 
+// ParseSrc reads the .gp files and writes out the generated code for them
 func (pkg *Package) ParseSrc(src ...*os.File) error {
-	fset := token.NewFileSet()
+	err := pkg.Parse(src...)
+	if err != nil {
+		return err
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		return err
+	}
+	_, err = WriteFiles(files)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteConfig()
+}
+
+// WriteFiles writes out generated files, skipping any that are already up
+// to date so their modification times are left alone. The names of the
+// files written are returned.
+func WriteFiles(files map[string][]byte) ([]string, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	written := []string{}
+	for _, name := range names {
+		if current, err := ioutil.ReadFile(name); err == nil && bytes.Equal(current, files[name]) {
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(name), 0777)
+		if err != nil {
+			return written, err
+		}
+		err = ioutil.WriteFile(name, files[name], 0666)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+// Parse reads the .gp files into the Package and checks the result, any
+// problems are returned as a scanner.ErrorList positioned in the .gp files.
+func (pkg *Package) Parse(src ...*os.File) error {
+	pkg.fset = token.NewFileSet()
+	pkg.sources = map[string]*sourceMap{}
 
 	// process files
+	errs := scanner.ErrorList{}
 	for _, file := range src {
-		err := pkg.processFile(fset, file)
-		if err != nil {
+		err := pkg.processFile(pkg.fset, file)
+		if list, ok := err.(scanner.ErrorList); ok {
+			errs = append(errs, list...)
+		} else if err != nil {
 			return err
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 
 	// process mixins
 	pkg.exciseMixins()
@@ -46,38 +91,25 @@ func (pkg *Package) ParseSrc(src ...*os.File) error {
 		subrecord.AddRetrieved()
 	}
 
-	// process relations
-	for i, table := range pkg.Tables {
-		pkg.Tables[i] = pkg.processForRelations(table)
+	// add the columns needed by enabled features
+	for _, table := range pkg.Tables {
+		pkg.injectFeatureFields(table)
 	}
+
+	// process relations
 	for i, table := range pkg.Tables {
 		pkg.Tables[i] = pkg.linkRelations(table)
 	}
 
+	err := pkg.validate()
+	if err != nil {
+		return err
+	}
+
 	// inject extra code
 	for i, table := range pkg.Tables {
 		pkg.Tables[i] = pkg.injectFields(table)
 	}
-
-	// Write out processed files
-	for _, active := range pkg.ActiveFiles {
-		f, err := os.Create(active.DefName())
-		if err != nil {
-			return err
-		}
-		b := &bytes.Buffer{}
-		format.Node(b, fset, active.AST)
-		ib, err := imports.Process(active.DefName(), b.Bytes(), nil)
-		if err != nil {
-			log.Print(active.DefName())
-			log.Fatal(err)
-		}
-		f.Write(ib)
-		f.Close()
-	}
-
-	pkg.OutputTemplates()
-
 	return nil
 }
 
@@ -163,66 +195,16 @@ func (pkg *Package) exciseMixins() {
 	}
 }
 
-func (pkg *Package) processForRelations(table Table) Table {
-	if st, ok := table.Spec().Type.(*ast.StructType); ok {
-		for i, field := range st.Fields.List {
-			if len(field.Names) == 1 && field.Names[0].Name == "DRRelation" {
-				if rst, ok := field.Type.(*ast.StructType); ok {
-					for _, rfield := range rst.Fields.List {
-						r := Relationship{Parent: table}
-						// TODO: names can be plural, fix this later
-						if !strings.HasPrefix(rfield.Names[0].Name, "DRRelated") {
-							r.Alias = rfield.Names[0].Name
-						}
-						if id, ok := rfield.Type.(*ast.Ident); ok {
-							r.Table = id.Name
-						}
-						if se, ok := rfield.Type.(*ast.ArrayType); ok {
-							if id, ok := se.Elt.(*ast.Ident); ok {
-								r.IsArray = true
-								r.Table = id.Name
-							}
-						}
-						table.Relations = append(table.Relations, r)
-					}
-				}
-				if i > 0 {
-					st.Fields.List = append(st.Fields.List[:i], st.Fields.List[i+1:]...)
-				} else {
-					st.Fields.List = st.Fields.List[1:]
-				}
-			}
-		}
-
-		for i, field := range st.Fields.List {
-			if len(field.Names) == 1 && field.Names[0].Name == "DRIndex" {
-				if rst, ok := field.Type.(*ast.StructType); ok {
-					for _, rfield := range rst.Fields.List {
-						ix := Index{}
-						if len(rfield.Names) > 0 {
-							for _, name := range rfield.Names {
-								ix.Columns = append(ix.Columns, name.Name)
-							}
-						} else {
-							ix.Columns = append(ix.Columns, fmt.Sprint(rfield.Type))
-						}
-						table.Indexes = append(table.Indexes, ix)
-					}
-				}
-				if i > 0 {
-					st.Fields.List = append(st.Fields.List[:i], st.Fields.List[i+1:]...)
-				} else {
-					st.Fields.List = st.Fields.List[1:]
-				}
-			}
-		}
-	}
-
-	return table
-}
-
 func (pkg *Package) linkRelations(table Table) Table {
 	for i, relate := range table.Relations {
+		if relate.Table == "polymorphic" {
+			relate.Type = "Polymorphic"
+			relate.ChildName = table.name
+			relate.OperativeColumn = relate.Alias + "ID"
+			table.Relations[i] = relate
+			continue
+		}
+
 		// parent relations
 		if relate.IsArray {
 			relate.Type = "ParentHasMany"
@@ -233,7 +215,7 @@ func (pkg *Package) linkRelations(table Table) Table {
 			continue
 		}
 
-		if _, ok := table.ColumnByName(relate.Table + "ID"); !ok {
+		if _, ok := table.ColumnByName(relate.foreignKey(true)); !ok {
 			relate.Type = "HasOne"
 			relate.ParentName = table.name
 			relate.ChildName = relate.Table
@@ -243,13 +225,13 @@ func (pkg *Package) linkRelations(table Table) Table {
 		}
 
 		parent, ok := pkg.TableByName(relate.Table)
-		// ghetto error checking
 		if !ok {
-			panic(fmt.Sprintf("Table named %s for %s doesn't exist", relate.Table, table.Name()))
+			// reported by validate
+			continue
 		}
 
 		// child relations
-		if pRelate, ok := parent.RelationshipTo(table.name); !ok || pRelate.IsArray {
+		if pRelate, ok := parent.RelationshipTo(relate); !ok || pRelate.IsArray {
 			relate.Type = "ChildHasMany"
 			relate.ParentName = relate.Table
 			relate.ChildName = table.name
@@ -268,8 +250,58 @@ func (pkg *Package) linkRelations(table Table) Table {
 	return table
 }
 
+// injectFeatureFields adds the columns timestamps, soft deletes, variants
+// and polymorphic relations rely on to a table, unless the table already
+// declares them.
+func (pkg *Package) injectFeatureFields(table Table) {
+	st, ok := table.Spec().Type.(*ast.StructType)
+	if !ok {
+		return
+	}
+	declared := map[string]bool{}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			declared[name.Name] = true
+		}
+	}
+
+	timeType := func() ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("time"), Sel: ast.NewIdent("Time")}
+	}
+	add := func(name string, fieldType ast.Expr) {
+		if !declared[name] {
+			st.Fields.List = append(st.Fields.List, &ast.Field{
+				Names: []*ast.Ident{ast.NewIdent(name)},
+				Type:  fieldType,
+			})
+		}
+	}
+	if pkg.Config.Timestamps {
+		add("CreatedAt", timeType())
+		add("UpdatedAt", timeType())
+	}
+	if pkg.Config.SoftDelete {
+		add("DeletedAt", &ast.StarExpr{X: timeType()})
+	}
+	if len(table.Variants()) > 0 {
+		add(variantColumn, ast.NewIdent("string"))
+	}
+	for _, relate := range table.Relations {
+		if relate.Table == "polymorphic" && relate.Alias != "" {
+			add(relate.Alias+"ID", ast.NewIdent("int"))
+			add(relate.Alias+"Type", ast.NewIdent("string"))
+		}
+	}
+}
+
 func (pkg *Package) injectFields(table Table) Table {
 	if st, ok := table.Spec().Type.(*ast.StructType); ok {
+		if len(table.SavedRelations()) > 0 {
+			st.Fields.List = append(st.Fields.List, &ast.Field{
+				Names: []*ast.Ident{ast.NewIdent("Related")},
+				Type:  ast.NewIdent(table.Name() + "Related"),
+			})
+		}
 		st.Fields.List = append(st.Fields.List, &ast.Field{
 			Names: []*ast.Ident{ast.NewIdent("cached_conn")},
 			Type:  ast.NewIdent("*Conn"),
@@ -279,77 +311,83 @@ func (pkg *Package) injectFields(table Table) Table {
 }
 
 func (pkg *Package) processFile(fset *token.FileSet, file *os.File) error {
-	tables, mixins, subrecords := []string{}, []string{}, []string{}
-	output := []string{}
-
-	scanner := bufio.NewScanner(file)
-	inRelation := 0
-	for scanner.Scan() {
-		text := scanner.Text()
-		stripped := strings.TrimSpace(text)
+	src, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	gp, goSrc, sources, err := parseGP(file.Name(), src)
+	if err != nil {
+		return err
+	}
+	pkg.sources[file.Name()] = sources
 
-		switch {
-		case subrecordDef.MatchString(text):
-			subrecord := subrecordDef.FindStringSubmatch(text)[1]
-			subrecords = append(subrecords, subrecord)
-			text = strings.Replace(text, " subrecord ", " struct ", 1)
-		case mixinDef.MatchString(text):
-			mixin := mixinDef.FindStringSubmatch(text)[1]
-			mixins = append(mixins, mixin)
-			text = strings.Replace(text, " mixin ", " struct ", 1)
-		case tableDef.MatchString(text):
-			table := tableDef.FindStringSubmatch(text)[1]
-			tables = append(tables, table)
-			text = strings.Replace(text, " table ", " struct ", 1)
-		case relationDef.MatchString(text):
-			text = "DRRelation struct {"
-			inRelation = 1
-		case indexDef.MatchString(text):
-			text = "DRIndex struct {"
-			inRelation = 0
-		case inRelation > 0 && stripped == "}":
-			inRelation = 0
-		case inRelation > 0 && codeStart.MatchString(stripped):
-			if len(strings.Split(stripped, " ")) == 1 {
-				text = fmt.Sprintf("DRRelated%d %s", inRelation, stripped)
-				inRelation++
-			}
+	fa, err := parser.ParseFile(fset, file.Name(), goSrc, parser.ParseComments)
+	if list, ok := err.(scanner.ErrorList); ok {
+		for _, e := range list {
+			e.Pos = sources.original(e.Pos)
 		}
-
-		output = append(output, text)
+		return list
 	}
-
-	fa, err := parser.ParseFile(fset, file.Name(), strings.Join(output, "\n"), parser.ParseComments)
 	if err != nil {
 		return err
 	}
 
 	active := false
-DeclLoop:
 	for _, decl := range fa.Decls {
 		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
 			if td, ok := gd.Specs[0].(*ast.TypeSpec); ok {
 				name := td.Name.Name
-				for _, subrecord := range subrecords {
-					if subrecord == name {
-						pkg.Subrecords = append(pkg.Subrecords, Subrecord{name, td, fa})
-						active = true
-						continue DeclLoop
-					}
+				gpDecl := gp.Decl(name)
+				if gpDecl == nil {
+					continue
 				}
-				for _, mixin := range mixins {
-					if mixin == name {
-						pkg.Mixins = append(pkg.Mixins, Mixin{name, td, fa})
-						active = true
-						continue DeclLoop
+				active = true
+				switch gpDecl.Kind {
+				case "subrecord":
+					pkg.Subrecords = append(pkg.Subrecords, Subrecord{name, td, fa})
+				case "mixin":
+					pkg.Mixins = append(pkg.Mixins, Mixin{name, td, fa})
+				case "enum":
+					enum := Enum{Name: name, spec: td}
+					for _, value := range gpDecl.Values {
+						stored := value.Stored
+						if stored == "" {
+							stored = value.Name
+						}
+						enum.Values = append(enum.Values, EnumValue{
+							Name:   value.Name,
+							Stored: stored,
+							pos:    value.Pos,
+						})
 					}
-				}
-				for _, table := range tables {
-					if table == name {
-						pkg.Tables = append(pkg.Tables, Table{name: name, spec: td, file: fa, Pkg: pkg})
-						active = true
-						continue DeclLoop
+					pkg.Enums = append(pkg.Enums, enum)
+				case "table":
+					if gpDecl.Parent != "" {
+						pkg.Variants = append(pkg.Variants, Variant{Name: name, Parent: gpDecl.Parent, Pkg: pkg, spec: td})
+						continue
+					}
+					table := Table{name: name, spec: td, file: fa, Pkg: pkg}
+					for _, relation := range gpDecl.Relations {
+						table.Relations = append(table.Relations, Relationship{
+							Table:   relation.Table,
+							Alias:   relation.Alias,
+							IsArray: relation.IsArray,
+							Tag:     reflect.StructTag(relation.Tag),
+							Parent:  table,
+							pos:     relation.Pos,
+						})
+					}
+					for _, index := range gpDecl.Indexes {
+						table.Indexes = append(table.Indexes, Index{
+							Columns: index.Columns,
+							Desc:    index.Desc,
+							Unique:  index.Unique,
+							Name:    reflect.StructTag(index.Tag).Get("name"),
+							Where:   index.Where,
+							pos:     index.Pos,
+						})
 					}
+					pkg.Tables = append(pkg.Tables, table)
 				}
 			}
 		} else if fd, ok := decl.(*ast.FuncDecl); ok {
@@ -1,7 +1,10 @@
 package parse
 
 import (
+	"go/scanner"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -22,3 +25,731 @@ func TestTableParse(t *testing.T) {
 
 	pkg.OutputTemplates()
 }
+
+func TestNullableColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID          int
+  Name        string
+  BannedUntil *time.Time
+  ParentID    *int
+  Nickname    sql.NullString
+  Score       sql.NullFloat64
+  Avatar      []byte
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []struct {
+		name, sqlType string
+		null          bool
+	}{
+		{"Name", "varchar", false},
+		{"BannedUntil", "timestamp", true},
+		{"ParentID", "integer", true},
+		{"Nickname", "varchar", true},
+		{"Score", "double precision", true},
+		{"Avatar", "blob", true},
+	}
+	for _, expected := range columns {
+		col, ok := pkg.Tables[0].ColumnByName(expected.name)
+		if !ok {
+			t.Fatal("Missing column", expected.name)
+		}
+		if !col.SimpleType() || col.Type() != expected.sqlType || col.Nullable() != expected.null {
+			t.Errorf("%s: expected %s (null %v), got %s (null %v)", expected.name, expected.sqlType, expected.null, col.Type(), col.Nullable())
+		}
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(regexp.MustCompile(`Null:\s+true`).FindAll(files["example_schema.go"], -1)); n != 5 {
+		t.Errorf("Expected 5 nullable columns in the schema, found %d:\n%s", n, files["example_schema.go"])
+	}
+}
+
+func TestCustomColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID      int
+  Balance Money
+  Address net.IP `+"`type:\"inet\"`"+`
+  Avatar  *Money
+  Bio     string `+"`type:\"text\"`"+`
+}
+
+type Money struct {
+  Cents int64
+}
+
+func (m *Money) Scan(v interface{}) error {
+  return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+  return m.Cents, nil
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []struct {
+		name, sqlType string
+		custom        bool
+	}{
+		{"Balance", "varchar", true},
+		{"Address", "inet", true},
+		{"Avatar", "varchar", true},
+		{"Bio", "text", false},
+	}
+	for _, expected := range columns {
+		col, ok := pkg.Tables[0].ColumnByName(expected.name)
+		if !ok {
+			t.Fatal("Missing column", expected.name)
+		}
+		if !col.SimpleType() || col.Type() != expected.sqlType || col.Custom() != expected.custom {
+			t.Errorf("%s: expected %s (custom %v), got %s (custom %v)", expected.name, expected.sqlType, expected.custom, col.Type(), col.Custom())
+		}
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := string(files["example_gen.go"])
+	for _, field := range []string{
+		`{Column: "Balance", Dest: &t.Balance}`,
+		`{Column: "Address", Dest: &t.Address}`,
+		`{Column: "Avatar", Dest: dr.Scanner(&t.Avatar)}`,
+	} {
+		if !strings.Contains(gen, field) {
+			t.Errorf("Missing field %s in:\n%s", field, gen)
+		}
+	}
+}
+
+func TestJSONColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Forum table {
+  ID       int
+  Settings map[string]string `+"`type:\"json\"`"+`
+  Rules    []Rule `+"`json:\"rules\"`"+`
+  Name     string `+"`json:\"name\"`"+`
+  Raw      string `+"`type:\"json\"`"+`
+}
+
+type Rule struct {
+  Text string
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, json := range map[string]bool{"Settings": true, "Rules": true, "Name": false, "Raw": false} {
+		col, ok := pkg.Tables[0].ColumnByName(name)
+		if !ok {
+			t.Fatal("Missing column", name)
+		}
+		if col.JSON() != json || col.Type() != "json" && name != "Name" {
+			t.Errorf("%s: expected JSON %v, got %v with type %s", name, json, col.JSON(), col.Type())
+		}
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files["example_gen.go"]), `{Column: "Settings", Dest: dr.JSON(&t.Settings)}`) {
+		t.Errorf("Settings isn't scanned as JSON:\n%s", files["example_gen.go"])
+	}
+	if !strings.Contains(string(files["example_schema.go"]), `dr.JSON(&t.Settings), dr.JSON(&t.Rules), t.Name, t.Raw`) {
+		t.Errorf("Settings and Rules aren't written as JSON:\n%s", files["example_schema.go"])
+	}
+}
+
+func TestEnumColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Forum table {
+  ID         int
+  Visibility Visibility
+}
+
+type Visibility enum {
+  Public
+  Members "members-only"
+  Hidden
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	col, ok := pkg.Tables[0].ColumnByName("Visibility")
+	if !ok {
+		t.Fatal("Missing column Visibility")
+	}
+	if col.Enum() == nil || col.Type() != "enum" || col.Length() != 12 {
+		t.Fatalf("Expected an enum column of length 12, got %s(%d)", col.Type(), col.Length())
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{
+		"VisibilityPublic Visibility = iota",
+		`Names: []string{"Public", "members-only", "Hidden"}`,
+		"func ParseVisibility(name string) (Visibility, error)",
+		"func (scope *ForumScope) VisibilityNamed(names ...string) *ForumScope",
+	} {
+		if !strings.Contains(string(files["example_gen.go"]), code) {
+			t.Errorf("Missing %s in:\n%s", code, files["example_gen.go"])
+		}
+	}
+	if !strings.Contains(string(files["example_schema.go"]), `Values: []string{"Public", "members-only", "Hidden"}`) {
+		t.Errorf("Enum values aren't in the schema:\n%s", files["example_schema.go"])
+	}
+}
+
+func TestDecimalColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+import dr "github.com/acsellers/dr/runtime"
+
+type Invoice table {
+  ID     int
+  Total  dr.Decimal `+"`precision:\"12\"`"+`
+  Tax    string `+"`type:\"decimal\" precision:\"6\" scale:\"4\"`"+`
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := map[string][2]int{"Total": {12, 2}, "Tax": {6, 4}}
+	for name, size := range sizes {
+		col, _ := pkg.Tables[0].ColumnByName(name)
+		if col.Type() != "decimal" || col.Precision() != size[0] || col.Scale() != size[1] {
+			t.Errorf("%s: expected decimal(%d,%d), got %s(%d,%d)", name, size[0], size[1], col.Type(), col.Precision(), col.Scale())
+		}
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files["example_gen.go"]), `{Column: "Total", Dest: &t.Total}`) {
+		t.Errorf("Total isn't scanned as a Decimal:\n%s", files["example_gen.go"])
+	}
+	if !strings.Contains(string(files["example_schema.go"]), "Precision: 6, Scale: 4") {
+		t.Errorf("Tax precision isn't in the schema:\n%s", files["example_schema.go"])
+	}
+
+	_, err = parseString(t, "package example\n\ntype Invoice table {\n  ID int\n  Total string `type:\"decimal\" precision:\"2\" scale:\"4\"`\n}\n")
+	if err == nil || !strings.Contains(err.Error(), "scale 4 is larger than precision 2") {
+		t.Error("Expected a scale error, got", err)
+	}
+}
+
+func TestIntegerColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Counter table {
+  ID     int
+  Small  int16
+  Flag   byte
+  Count  int32
+  Total  uint
+  Big    int64
+  Huge   uint64
+  Hits   sql.NullInt64
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[string]string{
+		"Small": "smallint", "Flag": "smallint+", "Count": "integer", "Total": "integer+",
+		"Big": "bigint", "Huge": "bigint+", "Hits": "bigint",
+	}
+	for name, expected := range types {
+		col, _ := pkg.Tables[0].ColumnByName(name)
+		got := col.Type()
+		if col.Unsigned() {
+			got += "+"
+		}
+		if got != expected || !col.SimpleType() {
+			t.Errorf("%s: expected %s, got %s", name, expected, got)
+		}
+	}
+}
+
+func TestConstraintColumns(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Account table {
+  ID     int
+  Email  string `+"`unique:\"true\" comment:\"where receipts are sent\"`"+`
+  Status string `+"`default:\"'active'\" check:\"status <> ''\"`"+`
+  Note   *string `+"`null:\"false\"`"+`
+  Name   string
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := pkg.Tables[0]
+	email, _ := table.ColumnByName("Email")
+	status, _ := table.ColumnByName("Status")
+	note, _ := table.ColumnByName("Note")
+	name, _ := table.ColumnByName("Name")
+	if !email.Unique() || email.Comment() != "where receipts are sent" {
+		t.Error("Email constraints", email.Unique(), email.Comment())
+	}
+	if status.Default() != "'active'" || status.Check() != "status <> ''" {
+		t.Error("Status constraints", status.Default(), status.Check())
+	}
+	if note.Nullable() {
+		t.Error("Note was made NOT NULL by its tag")
+	}
+	if email.Preset() || !name.Preset() {
+		t.Error("Only columns without constraints should use the preset columns")
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`Unique: true,`,
+		`Comment: "where receipts are sent",`,
+		`Default: "'active'",`,
+		`Check: "status <> ''",`,
+	} {
+		if !strings.Contains(string(files["example_schema.go"]), expected) {
+			t.Errorf("Missing %s from the schema:\n%s", expected, files["example_schema.go"])
+		}
+	}
+
+	_, err = parseString(t, "package example\n\ntype Account table {\n  ID int\n  Email string `unique:\"yes\"`\n}\n")
+	if err == nil || !strings.Contains(err.Error(), `unique "yes" must be true or false`) {
+		t.Error("Expected a unique error, got", err)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID        int
+  Email     string
+  CreatedAt time.Time
+
+  index {
+    unique lower(Email) where CreatedAt IS NOT NULL
+    Email, CreatedAt desc `+"`name:\"idx_recent\"`"+`
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`"lower(Email)",`,
+		`Unique: true,`,
+		`Where: "CreatedAt IS NOT NULL",`,
+		`Desc: []bool{false, true},`,
+		`Name: "idx_recent",`,
+	} {
+		if !strings.Contains(string(files["example_schema.go"]), expected) {
+			t.Errorf("Missing %s from the schema:\n%s", expected, files["example_schema.go"])
+		}
+	}
+}
+
+func TestForeignKeyActions(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID int
+
+  relation {
+    []Post `+"`onDelete:\"cascade\"`"+`
+  }
+}
+
+type Post table {
+  ID       int
+  UserID   int
+  EditorID *int
+
+  relation {
+    User
+    Editor User `+"`onDelete:\"set null\" onUpdate:\"restrict\" deferrable:\"true\"`"+`
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, _ := pkg.TableByName("Post")
+	user, editor := post.Relations[0], post.Relations[1]
+	if user.OnDelete() != "CASCADE" || user.OnUpdate() != "" {
+		t.Error("The action from the User side wasn't used", user.OnDelete(), user.OnUpdate())
+	}
+	if editor.OnDelete() != "SET NULL" || editor.OnUpdate() != "RESTRICT" || !editor.Deferrable() {
+		t.Error("Editor actions", editor.OnDelete(), editor.OnUpdate(), editor.Deferrable())
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_schema.go"])), " ")
+	for _, expected := range []string{
+		`OnDelete: "CASCADE",`,
+		`OnDelete: "SET NULL",`,
+		`OnUpdate: "RESTRICT",`,
+		`Deferrable: true,`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the schema:\n%s", expected, files["example_schema.go"])
+		}
+	}
+
+	_, err = parseString(t, "package example\n\ntype User table {\n  ID int\n}\n\ntype Post table {\n  ID int\n  UserID int\n\n  relation {\n    User `onDelete:\"set null\" onUpdate:\"drop\"`\n  }\n}\n")
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) != 2 {
+		t.Fatal("Expected two errors, got", err)
+	}
+	if !strings.Contains(list[0].Msg, `onUpdate "drop" on relation User is not cascade`) {
+		t.Error("Expected an onUpdate error, got", list[0])
+	}
+	if list[1].Msg != "relation User on Post sets UserID to null, but it isn't nullable" {
+		t.Error("Expected a set null error, got", list[1])
+	}
+}
+
+func TestAliasedRelations(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID int
+
+  relation {
+    Author []Post
+    Editor []Post
+  }
+}
+
+type Post table {
+  ID       int
+  AuthorID int
+  EditorID *int
+  ParentID *int
+
+  relation {
+    Author User
+    Editor User
+    Parent Post
+    Replies []Post `+"`column:\"ParentID\"`"+`
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, _ := pkg.TableByName("Post")
+	expected := []struct{ kind, column string }{
+		{"ChildHasMany", "AuthorID"},
+		{"ChildHasMany", "EditorID"},
+		{"ChildHasMany", "ParentID"},
+		{"ParentHasMany", "ParentID"},
+	}
+	for i, relate := range post.Relations {
+		if relate.Type != expected[i].kind || relate.OperativeColumn != expected[i].column {
+			t.Errorf("Expected %s to be %s on %s, got %s on %s", relate.Name(), expected[i].kind, expected[i].column, relate.Type, relate.OperativeColumn)
+		}
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_gen.go"])), " ")
+	for _, expected := range []string{
+		`func (t Post) Replies(c *Conn) ([]Post, error)`,
+		`return c.Post.ParentID().Eq(t.ID)`,
+		`return c.Post.Eq(t.ParentID)`,
+		`Alias("Parent")`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the generated code", expected)
+		}
+	}
+
+	_, err = parseString(t, "package example\n\ntype Post table {\n  ID int\n  PostID *int\n\n  relation {\n    Post\n  }\n}\n")
+	if err == nil || !strings.Contains(err.Error(), "relation from Post to itself needs a name") {
+		t.Error("Expected a self relation error, got", err)
+	}
+}
+
+func TestPolymorphicRelations(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Thread table {
+  ID int
+
+  relation {
+    Comments []Comment `+"`as:\"Commentable\"`"+`
+  }
+}
+
+type Post table {
+  ID int
+
+  relation {
+    Comments []Comment `+"`as:\"Commentable\"`"+`
+  }
+}
+
+type Comment table {
+  ID int
+
+  relation {
+    Commentable polymorphic
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment, _ := pkg.TableByName("Comment")
+	if _, ok := comment.ColumnByName("CommentableType"); !ok {
+		t.Error("CommentableType wasn't added to Comment")
+	}
+	types := comment.Relations[0].PolymorphicTypes()
+	if len(types) != 2 || types[0].Name() != "Thread" || types[1].Name() != "Post" {
+		t.Error("Unexpected types", types)
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_gen.go"])+string(files["example_schema.go"])), " ")
+	for _, expected := range []string{
+		`return c.Comment.CommentableID().Eq(t.ID).CommentableType().Eq("Thread")`,
+		`func (scope *CommentScope) CommentableIsPost() *CommentScope`,
+		`func LoadCommentCommentable(c *Conn, records []Comment) ([]interface{}, error)`,
+		`TypeName: "Post",`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the generated code", expected)
+		}
+	}
+
+	_, err = parseString(t, "package example\n\ntype Post table {\n  ID int\n\n  relation {\n    Comments []Comment `as:\"Commentable\"`\n  }\n}\n\ntype Comment table {\n  ID int\n\n  relation {\n    polymorphic\n  }\n}\n")
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) != 2 {
+		t.Fatal("Expected two errors, got", err)
+	}
+	if list[0].Msg != "relation Comments on Post goes through Comment.Commentable, which isn't a polymorphic relation" {
+		t.Error("Unexpected error", list[0])
+	}
+	if list[1].Msg != "polymorphic relation on Comment needs a name, like Commentable polymorphic" {
+		t.Error("Unexpected error", list[1])
+	}
+}
+
+func TestTableVariants(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Post table {
+  ID    int
+  Title string
+}
+
+// Announcements are shown above the other posts
+type Announcement table Post
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, _ := pkg.TableByName("Post")
+	if _, ok := post.ColumnByName("Type"); !ok {
+		t.Error("Type wasn't added to Post")
+	}
+	if len(pkg.Variants) != 1 || pkg.Variants[0].Name != "Announcement" || pkg.Variants[0].Parent != "Post" {
+		t.Fatal("Unexpected variants", pkg.Variants)
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_gen.go"])+string(files["example_schema.go"])), " ")
+	for _, expected := range []string{
+		`TypeColumn: "Type", Variant: "Announcement",`,
+		`func (scope *AnnouncementScope) Title(eq ...interface{}) *AnnouncementScope`,
+		`func (scope *PostScope) RetrieveVariants() ([]interface{}, error)`,
+		`case "Announcement": return Announcement(t)`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the generated code", expected)
+		}
+	}
+
+	_, err = parseString(t, "package example\n\ntype Announcement table Post\n")
+	if err == nil || !strings.Contains(err.Error(), "variant Announcement refers to unknown table Post") {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestSaveRelated(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type Thread table {
+  ID int
+
+  relation {
+    []Post
+  }
+}
+
+type Post table {
+  ID       int
+  ThreadID int
+  ParentID *int
+
+  relation {
+    Thread
+    Parent Post
+    Replies []Post `+"`column:\"ParentID\"`"+`
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, _ := pkg.TableByName("Post")
+	if _, ok := post.ColumnByName("Related"); !ok {
+		t.Error("Related wasn't added to Post")
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_schema.go"])), " ")
+	for _, expected := range []string{
+		`type PostRelated struct { Thread *Thread Parent *Post Replies []Post }`,
+		`if t.Related.set() { if c == nil { c = t.cached_conn }`,
+		`return c.Transaction(t.saveRelated) }`,
+		`before := *t c.onRollback(func() { *t = before })`,
+		`t.ThreadID = int(parent.ID)`,
+		`id := int(parent.ID) t.ParentID = &id`,
+		`id := int(t.ID) child.ParentID = &id`,
+		`child.ThreadID = int(t.ID)`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the generated code", expected)
+		}
+	}
+}
+
+func TestDependentRelations(t *testing.T) {
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID int
+
+  relation {
+    []Post `+"`dependent:\"delete\"`"+`
+    Profile `+"`dependent:\"nullify\"`"+`
+  }
+}
+
+type Post table {
+  ID        int
+  UserID    int
+  DeletedAt *time.Time
+}
+
+type Profile table {
+  ID     int
+  UserID *int
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := strings.Join(strings.Fields(string(files["example_gen.go"])+string(files["example_schema.go"])), " ")
+	for _, expected := range []string{
+		`Dependent: "delete",`,
+		`Dependent: "nullify",`,
+		`err := dr.DeleteDependents(c, &Schema, "User", []int64{int64(t.ID)})`,
+		`return scope.Clone().SetConn(c).Scope.Delete()`,
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Missing %s from the generated code", expected)
+		}
+	}
+	if strings.Contains(generated, `func (scope *PostScope) Delete() error`) {
+		t.Error("Post has no dependents, but its scope's Delete was replaced")
+	}
+
+	// DeleteDependents soft deletes the tables the schema marks, which
+	// takes soft_delete as well as a DeletedAt column
+	if strings.Contains(generated, `DeletedAt: "DeletedAt",`) {
+		t.Error("Post was soft deleted without soft_delete")
+	}
+	pkg.Config.SoftDelete = true
+	files, err = pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated = strings.Join(strings.Fields(string(files["example_schema.go"])), " ")
+	if !strings.Contains(generated, `Name: "Post", DeletedAt: "DeletedAt",`) {
+		t.Errorf("Post wasn't marked as soft deleted in the schema:\n%s", files["example_schema.go"])
+	}
+
+	_, err = parseString(t, "package example\n\ntype User table {\n  ID int\n\n  relation {\n    []Post `dependent:\"nullify\"`\n  }\n}\n\ntype Post table {\n  ID     int\n  UserID int\n\n  relation {\n    User `dependent:\"destroy\"`\n  }\n}\n")
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) != 2 {
+		t.Fatal("Expected two errors, got", err)
+	}
+	if list[0].Msg != "relation Post on User nullifies Post.UserID, but it isn't nullable" {
+		t.Error("Unexpected error", list[0])
+	}
+	if list[1].Msg != `dependent "destroy" on relation User is not delete, nullify or restrict` {
+		t.Error("Unexpected error", list[1])
+	}
+}
@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	tparse "text/template/parse"
+)
+
+// TemplateExt is the extension of the files read from Config.Templates
+const TemplateExt = ".tmpl"
+
+// projectTemplates is the built in template set with a project's template
+// directory added to it.
+type projectTemplates struct {
+	*template.Template
+	// extras are the project templates rendered to files of their own
+	extras []string
+}
+
+// templates returns the template set for the package, reading the project
+// template directory the first time it's needed.
+func (pkg *Package) templates() (*projectTemplates, error) {
+	if pkg.custom != nil {
+		return pkg.custom, nil
+	}
+	if pkg.Config.Templates == "" {
+		return &projectTemplates{Template: tmpl}, nil
+	}
+
+	t, err := loadTemplates(pkg.Config.Templates)
+	if err != nil {
+		return nil, err
+	}
+	pkg.custom = t
+	return t, nil
+}
+
+// loadTemplates adds every .tmpl file in dir to a new built in template
+// set. A define block in one of the files replaces the template of the same
+// name, like config, scope, relation_methods or record_methods. A file with
+// anything outside of define blocks becomes a template named after the file
+// and is rendered with the Package into a file of its own.
+func loadTemplates(dir string) (*projectTemplates, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*"+TemplateExt))
+	if err != nil {
+		return nil, err
+	}
+	if len(filenames) == 0 {
+		if _, err := ioutil.ReadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(filenames)
+
+	t, err := newTemplates()
+	if err != nil {
+		return nil, err
+	}
+	pt := &projectTemplates{Template: t}
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(filename), TemplateExt)
+		builtin := t.Lookup(name) != nil
+
+		added, err := t.New(name).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if !builtin && added.Tree != nil && !tparse.IsEmptyTree(added.Tree.Root) {
+			pt.extras = append(pt.extras, name)
+		}
+	}
+	return pt, nil
+}
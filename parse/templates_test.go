@@ -0,0 +1,103 @@
+package parse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drtemplates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	templates := map[string]string{
+		// only define blocks, replaces the built in record methods
+		"record.tmpl": `{{ define "record_methods" }}
+func (t {{ .Name }}) TableName() string {
+	return "{{ .Name | plural }}"
+}
+{{ end }}`,
+		// a file of its own
+		"names.tmpl": `package {{ .Name }}
+
+var TableNames = []string{ {{ range .Tables }}"{{ .Name }}",{{ end }} }
+`,
+		"ignored.txt": `{{ bad`,
+	}
+	for name, content := range templates {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg, err := parseString(t, `package example
+
+type User table {
+  ID int
+  Name string
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.Config.Templates = dir
+	files, err := pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := files["example_schema.go"]
+	if !bytes.Contains(schema, []byte(`func (t User) TableName() string {`)) {
+		t.Errorf("record_methods wasn't replaced:\n%s", schema)
+	}
+	if bytes.Contains(schema, []byte(`func (t *User) Save(`)) {
+		t.Errorf("Built in record_methods was still used:\n%s", schema)
+	}
+
+	names, ok := files["example_names.go"]
+	if !ok {
+		t.Fatal("Extra template wasn't rendered, got files", len(files))
+	}
+	if !bytes.Contains(names, []byte(`var TableNames = []string{"User"}`)) {
+		t.Errorf("Unexpected extra file:\n%s", names)
+	}
+	if _, ok := files["example_record.go"]; ok {
+		t.Error("A file of only define blocks shouldn't be rendered")
+	}
+
+	// the built in templates are left alone for other packages
+	pkg.Config.Templates = ""
+	pkg.custom = nil
+	files, err = pkg.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(files["example_schema.go"], []byte(`func (t *User) Save(`)) {
+		t.Error("Built in templates were changed by the project templates")
+	}
+}
+
+func TestBadProjectTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drtemplates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte(`{{ range .Tables }}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadTemplates(dir); err == nil {
+		t.Error("Expected an error for an unclosed range")
+	}
+	if _, err = loadTemplates(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
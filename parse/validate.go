@@ -0,0 +1,337 @@
+package parse
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// sourceMap maps positions in the Go source rewritten from a .gp file
+// back onto the .gp file itself.
+type sourceMap struct {
+	file  *token.File
+	edits []sourceEdit
+}
+
+// sourceEdit is a span of the .gp file that was replaced while rewriting
+type sourceEdit struct {
+	out, outLen   int
+	orig, origLen int
+}
+
+func (m *sourceMap) original(pos token.Position) token.Position {
+	if m == nil || !pos.IsValid() {
+		return pos
+	}
+	offset := pos.Offset
+	for _, edit := range m.edits {
+		if pos.Offset < edit.out {
+			break
+		}
+		if pos.Offset < edit.out+edit.outLen {
+			offset = edit.orig + pos.Offset - edit.out
+			if offset >= edit.orig+edit.origLen {
+				offset = edit.orig + edit.origLen - 1
+			}
+			break
+		}
+		offset = edit.orig + edit.origLen + pos.Offset - edit.out - edit.outLen
+	}
+	if offset > m.file.Size() {
+		offset = m.file.Size()
+	}
+	return m.file.Position(m.file.Pos(offset))
+}
+
+func (pkg *Package) position(pos token.Pos) token.Position {
+	p := pkg.fset.Position(pos)
+	return pkg.sources[p.Filename].original(p)
+}
+
+// validate checks the parsed package for the mistakes that would otherwise
+// panic during generation or quietly produce the wrong columns. Every
+// problem found is returned in a scanner.ErrorList sorted by position.
+func (pkg *Package) validate() error {
+	errs := scanner.ErrorList{}
+	reportAt := func(pos token.Position, format string, args ...interface{}) {
+		errs.Add(pos, fmt.Sprintf(format, args...))
+	}
+	report := func(pos token.Pos, format string, args ...interface{}) {
+		reportAt(pkg.position(pos), format, args...)
+	}
+
+	declared := map[string]bool{}
+	declare := func(name string, spec *ast.TypeSpec) {
+		if declared[name] {
+			report(spec.Name.Pos(), "%s is declared more than once", name)
+		}
+		declared[name] = true
+	}
+	for _, table := range pkg.Tables {
+		declare(table.name, table.spec)
+	}
+	for _, mixin := range pkg.Mixins {
+		declare(mixin.Name, mixin.Spec)
+	}
+	for _, subrecord := range pkg.Subrecords {
+		declare(subrecord.Name(), subrecord.spec)
+	}
+	for _, variant := range pkg.Variants {
+		declare(variant.Name, variant.spec)
+		parent, ok := pkg.TableByName(variant.Parent)
+		if !ok {
+			report(variant.spec.Type.Pos(), "variant %s refers to unknown table %s", variant.Name, variant.Parent)
+		} else if col, ok := parent.ColumnByName(variantColumn); ok && (col.GoType != "string" || col.MustNull) {
+			report(variant.spec.Type.Pos(), "variant %s needs %s.%s to be a string", variant.Name, variant.Parent, variantColumn)
+		}
+	}
+	for _, enum := range pkg.Enums {
+		declare(enum.Name, enum.spec)
+		if len(enum.Values) == 0 {
+			report(enum.spec.Name.Pos(), "enum %s has no values", enum.Name)
+		}
+		stored := map[string]bool{}
+		for _, v := range enum.Values {
+			if stored[v.Stored] {
+				reportAt(v.pos, "%s is stored more than once in enum %s", v.Stored, enum.Name)
+			}
+			stored[v.Stored] = true
+		}
+	}
+
+	for _, subrecord := range pkg.Subrecords {
+		pkg.validateFields(subrecord.spec, report)
+	}
+	for _, table := range pkg.Tables {
+		pkg.validateFields(table.spec, report)
+		if len(table.Columns()) == 0 {
+			report(table.spec.Name.Pos(), "table %s has no columns, the first column is used as the primary key", table.name)
+			continue
+		}
+
+		for _, index := range table.Indexes {
+			for _, column := range index.Columns {
+				if !token.IsIdentifier(column) {
+					continue
+				}
+				if _, ok := table.ColumnByName(column); !ok {
+					reportAt(index.pos, "index on %s uses unknown column %s", table.name, column)
+				}
+			}
+		}
+
+		if _, ok := table.ColumnByName("DeletedAt"); ok && pkg.Config.SoftDelete && !table.SoftDelete() {
+			report(fieldPos(table.spec, "DeletedAt"), "soft_delete needs %s.DeletedAt to be a *time.Time, records would be deleted outright", table.name)
+		}
+
+		named := map[string]bool{}
+		for _, relate := range table.Relations {
+			if named[relate.Name()] {
+				reportAt(relate.pos, "relation %s is declared more than once on %s, name one of them", relate.Name(), table.name)
+			}
+			named[relate.Name()] = true
+			if dependent, ok := relate.Tag.Lookup("dependent"); ok {
+				if !dependentActions[strings.ToLower(dependent)] {
+					reportAt(relate.pos, "dependent %q on relation %s is not delete, nullify or restrict", dependent, relate.Name())
+				} else if !relate.IsHasMany() && !relate.IsHasOne() {
+					reportAt(relate.pos, "dependent on relation %s of %s belongs on the has many or has one side of it", relate.Name(), table.name)
+				}
+			}
+			if relate.Table == table.name && relate.Alias == "" {
+				reportAt(relate.pos, "relation from %s to itself needs a name", table.name)
+			}
+			if relate.Table == "polymorphic" {
+				if relate.Alias == "" {
+					reportAt(relate.pos, "polymorphic relation on %s needs a name, like Commentable polymorphic", table.name)
+				}
+				if relate.hasActions() {
+					reportAt(relate.pos, "polymorphic relation %s on %s has no foreign key to take actions", relate.Name(), table.name)
+				}
+				continue
+			}
+			target, ok := pkg.TableByName(relate.Table)
+			if !ok {
+				reportAt(relate.pos, "relation %s on %s refers to unknown table %s", relate.Name(), table.name, relate.Table)
+				continue
+			}
+			holder := target
+			if relate.IsChildHasMany() || relate.IsBelongsTo() {
+				holder = table
+			}
+			if as := relate.As(); as != "" {
+				found := false
+				for _, back := range target.Relations {
+					found = found || back.IsPolymorphic() && back.Alias == as
+				}
+				if !relate.IsArray {
+					reportAt(relate.pos, "relation %s on %s goes through a polymorphic relation, so it must be a []%s", relate.Name(), table.name, target.name)
+				} else if !found {
+					reportAt(relate.pos, "relation %s on %s goes through %s.%s, which isn't a polymorphic relation", relate.Name(), table.name, target.name, as)
+				} else if relate.hasActions() {
+					reportAt(relate.pos, "polymorphic relation %s on %s has no foreign key to take actions", relate.Name(), table.name)
+				} else if relate.Dependent() == "nullify" {
+					reportAt(relate.pos, "relation %s on %s nullifies %s.%sID, but it isn't nullable", relate.Name(), table.name, target.name, as)
+				}
+				continue
+			}
+			if _, ok := holder.ColumnByName(relate.OperativeColumn); !ok {
+				reportAt(relate.pos, "relation %s on %s needs a foreign key column %s on %s", relate.Name(), table.name, relate.OperativeColumn, holder.name)
+			}
+			for _, key := range []string{"onDelete", "onUpdate"} {
+				action, ok := relate.Tag.Lookup(key)
+				if ok && foreignKeyActions[strings.ToLower(action)] == "" {
+					reportAt(relate.pos, "%s %q on relation %s is not cascade, set null, set default, restrict or no action", key, action, relate.Name())
+				}
+			}
+			if deferrable, ok := relate.Tag.Lookup("deferrable"); ok {
+				if _, err := strconv.ParseBool(deferrable); err != nil {
+					reportAt(relate.pos, "deferrable %q on relation %s must be true or false", deferrable, relate.Name())
+				}
+			}
+			if col, ok := holder.ColumnByName(relate.OperativeColumn); ok && holder.name == table.name && !col.Nullable() {
+				if relate.OnDelete() == "SET NULL" || relate.OnUpdate() == "SET NULL" {
+					reportAt(relate.pos, "relation %s on %s sets %s to null, but it isn't nullable", relate.Name(), table.name, relate.OperativeColumn)
+				}
+			}
+			if col, ok := holder.ColumnByName(relate.OperativeColumn); ok && holder.name != table.name && !col.Nullable() && relate.Dependent() == "nullify" {
+				reportAt(relate.pos, "relation %s on %s nullifies %s.%s, but it isn't nullable", relate.Name(), table.name, holder.name, relate.OperativeColumn)
+			}
+		}
+	}
+
+	errs.Sort()
+	return errs.Err()
+}
+
+func (pkg *Package) validateFields(spec *ast.TypeSpec, report func(token.Pos, string, ...interface{})) {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		report(spec.Type.Pos(), "%s must be declared with braces", spec.Name.Name)
+		return
+	}
+
+	for _, field := range st.Fields.List {
+		var tag string
+		if field.Tag != nil {
+			var err error
+			tag, err = strconv.Unquote(field.Tag.Value)
+			if err == nil {
+				err = checkTag(tag)
+			}
+			if err != nil {
+				report(field.Tag.Pos(), "bad tag: %v", err)
+			} else if length := reflect.StructTag(tag).Get("length"); length != "" {
+				if l, err := strconv.Atoi(length); err != nil || l <= 0 {
+					report(field.Tag.Pos(), "length %q must be a positive number", length)
+				}
+			}
+			if err == nil {
+				validateDecimal(reflect.StructTag(tag), field.Tag.Pos(), report)
+				for _, key := range []string{"null", "unique"} {
+					value, ok := reflect.StructTag(tag).Lookup(key)
+					if _, err := strconv.ParseBool(value); ok && err != nil {
+						report(field.Tag.Pos(), "%s %q must be true or false", key, value)
+					}
+				}
+			}
+		}
+
+		ft := field.Type
+		if se, ok := ft.(*ast.StarExpr); ok {
+			ft = se.X
+		}
+		col := Column{GoType: fmt.Sprint(ft), Tag: reflect.StructTag(tag), Pkg: pkg}
+		if at, ok := ft.(*ast.ArrayType); ok {
+			col.GoType = "[]" + fmt.Sprint(at.Elt)
+		}
+		if col.SimpleType() || col.Subrecord() != nil {
+			continue
+		}
+		if len(field.Names) == 0 {
+			report(field.Pos(), "embedded %s is not a mixin or subrecord", types.ExprString(ft))
+		} else {
+			report(field.Type.Pos(), "unsupported type %s for %s", types.ExprString(ft), field.Names[0].Name)
+		}
+	}
+}
+
+// fieldPos is where a field is declared in the struct, or the struct's name
+// for fields brought in by a mixin or subrecord
+func fieldPos(spec *ast.TypeSpec, name string) token.Pos {
+	if st, ok := spec.Type.(*ast.StructType); ok {
+		for _, field := range st.Fields.List {
+			for _, ident := range field.Names {
+				if ident.Name == name {
+					return ident.Pos()
+				}
+			}
+		}
+	}
+	return spec.Name.Pos()
+}
+
+// validateDecimal checks the precision and scale of a decimal column
+func validateDecimal(tag reflect.StructTag, pos token.Pos, report func(token.Pos, string, ...interface{})) {
+	col := Column{Tag: tag}
+	if precision := tag.Get("precision"); precision != "" {
+		if p, err := strconv.Atoi(precision); err != nil || p <= 0 {
+			report(pos, "precision %q must be a positive number", precision)
+			return
+		}
+	}
+	if scale := tag.Get("scale"); scale != "" {
+		if s, err := strconv.Atoi(scale); err != nil || s < 0 {
+			report(pos, "scale %q must be zero or a positive number", scale)
+			return
+		}
+	}
+	if col.Scale() > col.Precision() {
+		report(pos, "scale %d is larger than precision %d", col.Scale(), col.Precision())
+	}
+}
+
+// checkTag reports the first problem in a tag that doesn't follow the
+// key:"value" convention reflect.StructTag expects.
+func checkTag(tag string) error {
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return fmt.Errorf(`%s is not in key:"value" form`, tag)
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return fmt.Errorf("unterminated value for %s", name)
+		}
+		if _, err := strconv.Unquote(tag[:i+1]); err != nil {
+			return fmt.Errorf("bad value for %s", name)
+		}
+		tag = tag[i+1:]
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package parse
+
+import (
+	"fmt"
+	"go/scanner"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func parseString(t *testing.T, src string) (*Package, error) {
+	f, err := ioutil.TempFile("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString(src)
+	f.Seek(0, 0)
+
+	pkg := &Package{Funcs: make(map[string][]Func)}
+	return pkg, pkg.Parse(f)
+}
+
+func TestValidate(t *testing.T) {
+	src := `package example
+
+type User table {
+  ID int
+  Address Address
+  Tags []string
+  Name string ` + "`length:\"abc\"`" + `
+  Nick string ` + "`null=\"zero\"`" + `
+
+  relation {
+    []Post
+    Group
+  }
+
+  index {
+    Email
+  }
+}
+
+type Post table {
+  ID int
+  Title string
+}
+
+type Post table {
+  ID int
+}
+
+type Level enum {
+  Low "low"
+  High "low"
+}
+`
+	expected := []string{
+		"5:11 unsupported type Address for Address",
+		"6:8 unsupported type []string for Tags",
+		"7:15 length \"abc\" must be a positive number",
+		"8:15 bad tag: null=\"zero\" is not in key:\"value\" form",
+		"11:5 relation Post on User needs a foreign key column UserID on Post",
+		"12:5 relation Group on User refers to unknown table Group",
+		"16:5 index on User uses unknown column Email",
+		"25:6 Post is declared more than once",
+		"31:3 low is stored more than once in enum Level",
+	}
+
+	_, err := parseString(t, src)
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatal("Expected an ErrorList, got:", err)
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		got := fmt.Sprintf("%d:%d %s", e.Pos.Line, e.Pos.Column, e.Msg)
+		if got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	src := "package example\n\ntype User table {\n  ID int\n  index {\n    A, B ]\n  }\n}\n"
+	_, err := parseString(t, src)
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		t.Fatal("Expected an ErrorList, got:", err)
+	}
+	if list[0].Pos.Line != 6 || list[0].Pos.Column != 10 {
+		t.Errorf("Expected error at 6:10, got %v", list[0])
+	}
+}
+
+func TestValidateSoftDelete(t *testing.T) {
+	f, err := ioutil.TempFile("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("package example\n\ntype User table {\n  ID int\n  DeletedAt time.Time\n}\n")
+	f.Seek(0, 0)
+
+	pkg := &Package{Funcs: make(map[string][]Func), Config: Config{SoftDelete: true}}
+	err = pkg.Parse(f)
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) != 1 {
+		t.Fatal("Expected one error, got:", err)
+	}
+	got := fmt.Sprintf("%d:%d %s", list[0].Pos.Line, list[0].Pos.Column, list[0].Msg)
+	if got != "5:3 soft_delete needs User.DeletedAt to be a *time.Time, records would be deleted outright" {
+		t.Error("Unexpected error", got)
+	}
+}
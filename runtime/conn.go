@@ -0,0 +1,166 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acsellers/dr/schema"
+)
+
+// Dialect holds the differences in SQL between the supported databases
+type Dialect struct {
+	// Placeholder replaces each ? with a numbered placeholder, like $1
+	Placeholder string
+	// Returning reads new primary keys with INSERT ... RETURNING
+	Returning bool
+	// OutputInserted reads new primary keys with OUTPUT INSERTED
+	OutputInserted bool
+	// FetchNext pages with TOP and OFFSET ... FETCH NEXT instead of LIMIT
+	FetchNext bool
+	// JSONPath is how a path into a JSON column is read, either the #>> or
+	// ->> operator or the JSON_VALUE function, json_extract when it's empty
+	JSONPath string
+}
+
+// Dialects by the driver names that need something other than the default
+var Dialects = map[string]Dialect{
+	"postgres":  Dialect{Placeholder: "$", Returning: true, JSONPath: "#>>"},
+	"mysql":     Dialect{JSONPath: "->>"},
+	"mssql":     Dialect{Placeholder: "@p", OutputInserted: true, FetchNext: true, JSONPath: "JSON_VALUE"},
+	"sqlserver": Dialect{Placeholder: "@p", OutputInserted: true, FetchNext: true, JSONPath: "JSON_VALUE"},
+}
+
+// DialectFor returns the dialect for a database/sql driver name
+func DialectFor(driverName string) Dialect {
+	return Dialects[driverName]
+}
+
+// FormatQuery numbers the placeholders of a query for the dialect
+func (d Dialect) FormatQuery(query string) string {
+	if d.Placeholder == "" {
+		return query
+	}
+
+	parts := strings.Split(query, "?")
+	var newQuery []string
+	for i, part := range parts[:len(parts)-1] {
+		newQuery = append(newQuery, fmt.Sprintf("%s%s%d", part, d.Placeholder, i+1))
+	}
+	newQuery = append(newQuery, parts[len(parts)-1])
+
+	return strings.Join(newQuery, "")
+}
+
+// jsonPath is the SQL reading the text found by following keys into the
+// JSON held in column
+func (d Dialect) jsonPath(column string, keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = strings.Replace(key, "'", "''", -1)
+	}
+	if d.JSONPath == "#>>" {
+		return fmt.Sprintf("%s #>> '{%s}'", column, strings.Join(quoted, ","))
+	}
+
+	path := "$"
+	for _, key := range quoted {
+		path += `."` + strings.Replace(key, `"`, `\"`, -1) + `"`
+	}
+	switch d.JSONPath {
+	case "->>":
+		return fmt.Sprintf("%s ->> '%s'", column, path)
+	case "JSON_VALUE":
+		return fmt.Sprintf("JSON_VALUE(%s, '%s')", column, path)
+	default:
+		return fmt.Sprintf("json_extract(%s, '%s')", column, path)
+	}
+}
+
+// Create inserts a record and returns its new primary key
+func Create(c Conn, cols []string, vals []interface{}, name, pkname string) (int, error) {
+	dialect := c.Dialect()
+	if dialect.OutputInserted {
+		sql := fmt.Sprintf(
+			"INSERT INTO %s (%s) OUTPUT INSERTED.%s VALUES (%s)",
+			c.SQLTable(name),
+			strings.Join(cols, ", "),
+			c.SQLColumn(name, pkname),
+			questions(len(cols)),
+		)
+		var pk int
+		err := c.QueryRow(sql, vals...).Scan(&pk)
+		return pk, err
+	}
+
+	sql := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		c.SQLTable(name),
+		strings.Join(cols, ", "),
+		questions(len(cols)),
+	)
+	if dialect.Returning {
+		sql += " RETURNING " + c.SQLColumn(name, pkname)
+		var pk int
+		err := c.QueryRow(sql, vals...).Scan(&pk)
+		return pk, err
+	}
+
+	result, err := c.Exec(sql, vals...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Update writes the columns of a record, the primary key is the last value
+func Update(c Conn, cols []string, vals []interface{}, name, pkname string) error {
+	sql := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s=?",
+		c.SQLTable(name),
+		strings.Join(cols, " = ?, ")+" = ?",
+		c.SQLColumn(name, pkname),
+	)
+	_, err := c.Exec(sql, vals...)
+	return err
+}
+
+// SoftDelete sets the deleted at column of a record to now
+func SoftDelete(c Conn, val interface{}, name, pkname, column string) error {
+	sql := fmt.Sprintf(
+		"UPDATE %s SET %s = ? WHERE %s = ?",
+		c.SQLTable(name),
+		c.SQLColumn(name, column),
+		c.SQLColumn(name, pkname),
+	)
+	_, err := c.Exec(sql, time.Now(), val)
+	return err
+}
+
+// Delete removes a record by its primary key
+func Delete(c Conn, val interface{}, name, pkname string) error {
+	sql := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = ?",
+		c.SQLTable(name),
+		c.SQLColumn(name, pkname),
+	)
+	_, err := c.Exec(sql, val)
+	return err
+}
+
+func DefaultInt(col string) schema.Column {
+	return schema.Column{Name: col, Type: "integer", Length: 10}
+}
+
+func DefaultString(col string) schema.Column {
+	return schema.Column{Name: col, Type: "varchar", Length: 255}
+}
+
+func DefaultBool(col string) schema.Column {
+	return schema.Column{Name: col, Type: "bool"}
+}
+
+func DefaultTime(col string) schema.Column {
+	return schema.Column{Name: col, Type: "timestamp"}
+}
@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Decimal is an exact number for NUMERIC columns. It's kept as the decimal
+// string the database uses, so values are never rounded through a float.
+type Decimal string
+
+// ParseDecimal checks that s is a decimal number
+func ParseDecimal(s string) (Decimal, error) {
+	if _, ok := new(big.Rat).SetString(s); !ok {
+		return "", fmt.Errorf("%q is not a decimal", s)
+	}
+	return Decimal(s), nil
+}
+
+// MustDecimal is ParseDecimal for constants, it panics when s isn't a
+// decimal number
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Rat is the value of the decimal, the zero Decimal is 0
+func (d Decimal) Rat() *big.Rat {
+	r, ok := new(big.Rat).SetString(d.String())
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// Cmp compares the values of two decimals, rather than their strings
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+func (d Decimal) String() string {
+	if d == "" {
+		return "0"
+	}
+	return string(d)
+}
+
+// Scan reads a decimal from the string drivers return for NUMERIC columns,
+// or from the number SQLite stores them as
+func (d *Decimal) Scan(v interface{}) error {
+	switch n := v.(type) {
+	case nil:
+		*d = ""
+		return nil
+	case string:
+		*d = Decimal(n)
+	case []byte:
+		*d = Decimal(n)
+	case int64:
+		*d = Decimal(strconv.FormatInt(n, 10))
+		return nil
+	case float64:
+		*d = Decimal(strconv.FormatFloat(n, 'f', -1, 64))
+		return nil
+	default:
+		return fmt.Errorf("Can't scan %T into Decimal", v)
+	}
+	_, err := ParseDecimal(string(*d))
+	return err
+}
+
+// Value binds the decimal as a string, which databases convert to NUMERIC
+// without rounding
+func (d Decimal) Value() (driver.Value, error) {
+	if _, err := ParseDecimal(d.String()); err != nil {
+		return nil, err
+	}
+	return d.String(), nil
+}
@@ -0,0 +1,164 @@
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acsellers/dr/schema"
+)
+
+// dependent is a has many or has one relation of a table, whose records
+// are deleted, nullified or restrict deletes along with the table's
+type dependent struct {
+	child      *schema.Table
+	column     *schema.Column
+	typeColumn *schema.Column
+	typeName   string
+	action     string
+}
+
+// dependentsOf finds the relations of a table with a Dependent setting
+func dependentsOf(s *schema.Schema, name string) []dependent {
+	if s == nil || s.Tables[name] == nil {
+		return nil
+	}
+	t := s.Tables[name]
+	deps := []dependent{}
+	for _, rel := range t.HasMany {
+		if rel.Dependent != "" && rel.ChildColumn != nil {
+			deps = append(deps, dependent{rel.Child, rel.ChildColumn, rel.TypeColumn, rel.TypeName, rel.Dependent})
+		}
+	}
+	for _, rel := range t.HasOne {
+		if rel.Dependent != "" && rel.ChildColumn != nil {
+			deps = append(deps, dependent{rel.Child, rel.ChildColumn, nil, "", rel.Dependent})
+		}
+	}
+	return deps
+}
+
+// where is the condition matching the records depending on the ids and the
+// values it binds, live leaves out the soft deleted ones
+func (d dependent) where(c Conn, ids []interface{}, live bool) (string, []interface{}) {
+	name := d.child.Name
+	where := fmt.Sprintf("%s IN (%s)", c.SQLColumn(name, d.column.Name), questions(len(ids)))
+	vals := ids
+	if d.typeColumn != nil {
+		where += fmt.Sprintf(" AND %s = ?", c.SQLColumn(name, d.typeColumn.Name))
+		vals = append(append([]interface{}{}, ids...), d.typeName)
+	}
+	if live && d.child.DeletedAt != "" {
+		where += fmt.Sprintf(" AND %s IS NULL", c.SQLColumn(name, d.child.DeletedAt))
+	}
+	return where, vals
+}
+
+// DeleteDependents does what the Dependent settings of the table's has many
+// and has one relations ask for the records depending on the records with
+// the primary keys ids, ahead of deleting them. Dependent records are
+// deleted along with their own dependents, nullified, or they restrict the
+// delete and an error is returned. It's for databases without foreign key
+// actions, and should be run in a transaction with the delete so a
+// restriction found further down undoes the changes made above it.
+func DeleteDependents(c Conn, s *schema.Schema, table string, ids []int64) error {
+	return deleteDependents(c, s, table, ids, map[string]map[int64]bool{})
+}
+
+// deleteDependents skips the ids in seen, which have been deleted already
+// when relations loop back to a table
+func deleteDependents(c Conn, s *schema.Schema, table string, ids []int64, seen map[string]map[int64]bool) error {
+	if seen[table] == nil {
+		seen[table] = map[int64]bool{}
+	}
+	vals := []interface{}{}
+	for _, id := range ids {
+		if !seen[table][id] {
+			seen[table][id] = true
+			vals = append(vals, id)
+		}
+	}
+	deps := dependentsOf(s, table)
+	if len(vals) == 0 || len(deps) == 0 {
+		return nil
+	}
+
+	// restrictions are checked first, so a refused delete changes nothing
+	for _, dep := range deps {
+		if dep.action != "restrict" {
+			continue
+		}
+		where, args := dep.where(c, vals, true)
+		sql := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", c.SQLTable(dep.child.Name), where)
+		var count int64
+		if err := c.QueryRow(sql, args...).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("can't delete from %s, %d %s records depend on it", table, count, dep.child.Name)
+		}
+	}
+
+	for _, dep := range deps {
+		name := dep.child.Name
+		switch dep.action {
+		case "nullify":
+			// polymorphic records lose their type along with the id
+			set := c.SQLColumn(name, dep.column.Name) + " = NULL"
+			if dep.typeColumn != nil {
+				set += ", " + c.SQLColumn(name, dep.typeColumn.Name) + " = NULL"
+			}
+			where, args := dep.where(c, vals, false)
+			sql := fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.SQLTable(name), set, where)
+			if _, err := c.Exec(sql, args...); err != nil {
+				return err
+			}
+		case "delete":
+			pk := c.SQLColumn(name, dep.child.PrimaryKeyColumn().Name)
+			where, args := dep.where(c, vals, true)
+			rows, err := c.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s", pk, c.SQLTable(name), where), args...)
+			if err != nil {
+				return err
+			}
+			children := []int64{}
+			for rows.Next() {
+				var id int64
+				if err = rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				children = append(children, id)
+			}
+			rows.Close()
+			if err = rows.Err(); err != nil {
+				return err
+			}
+			if len(children) == 0 {
+				continue
+			}
+			if err = deleteDependents(c, s, name, children, seen); err != nil {
+				return err
+			}
+			if err = deleteByID(c, dep.child, children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteByID deletes, or soft deletes, the records of a table by their
+// primary keys
+func deleteByID(c Conn, t *schema.Table, ids []int64) error {
+	vals := make([]interface{}, len(ids))
+	for i, id := range ids {
+		vals[i] = id
+	}
+	where := fmt.Sprintf("%s IN (%s)", c.SQLColumn(t.Name, t.PrimaryKeyColumn().Name), questions(len(ids)))
+	if t.DeletedAt != "" {
+		sql := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", c.SQLTable(t.Name), c.SQLColumn(t.Name, t.DeletedAt), where)
+		_, err := c.Exec(sql, append([]interface{}{time.Now()}, vals...)...)
+		return err
+	}
+	_, err := c.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", c.SQLTable(t.Name), where), vals...)
+	return err
+}
@@ -0,0 +1,55 @@
+package runtime
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Enum holds the names of an enum's values, the value is the index of its
+// name. Generated enum types use it for their String, Scan and Value.
+type Enum struct {
+	Type  string
+	Names []string
+}
+
+// Valid is whether i is one of the enum's values
+func (e Enum) Valid(i int) bool {
+	return i >= 0 && i < len(e.Names)
+}
+
+// Name is the name of the value i, or the type and number when it isn't valid
+func (e Enum) Name(i int) string {
+	if !e.Valid(i) {
+		return fmt.Sprintf("%s(%d)", e.Type, i)
+	}
+	return e.Names[i]
+}
+
+// Parse finds the value named name
+func (e Enum) Parse(name string) (int, error) {
+	for i, n := range e.Names {
+		if n == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a %s", name, e.Type)
+}
+
+// Scan reads a value stored by name
+func (e Enum) Scan(v interface{}) (int, error) {
+	switch name := v.(type) {
+	case string:
+		return e.Parse(name)
+	case []byte:
+		return e.Parse(string(name))
+	}
+	return 0, fmt.Errorf("Can't scan %T into %s", v, e.Type)
+}
+
+// Value is the name stored for the value i
+func (e Enum) Value(i int) (driver.Value, error) {
+	if !e.Valid(i) {
+		return nil, fmt.Errorf("%d is not a %s", i, e.Type)
+	}
+	return e.Names[i], nil
+}
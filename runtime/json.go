@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSONValue keeps the value V points to in a JSON column, it marshals the
+// value when written and unmarshals it when scanned
+type JSONValue struct {
+	V interface{}
+}
+
+// JSON wraps a pointer to a struct, map or slice stored as JSON
+func JSON(v interface{}) JSONValue {
+	return JSONValue{v}
+}
+
+func (j JSONValue) Scan(v interface{}) error {
+	switch b := v.(type) {
+	case nil:
+		rv := reflect.ValueOf(j.V).Elem()
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	case string:
+		return json.Unmarshal([]byte(b), j.V)
+	case []byte:
+		return json.Unmarshal(b, j.V)
+	}
+	return fmt.Errorf("Can't scan %T as JSON", v)
+}
+
+// Value is the JSON text of the value, a nil pointer is written as NULL
+func (j JSONValue) Value() (driver.Value, error) {
+	if rv := reflect.ValueOf(j.V).Elem(); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	b, err := json.Marshal(j.V)
+	return string(b), err
+}
@@ -0,0 +1,520 @@
+// Package runtime holds the query building, joining and scanning code shared
+// by every package dr generates. Generated scopes embed a Scope and only add
+// the methods that need the table's own types, like column selection and
+// loading records.
+package runtime
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/acsellers/dr/schema"
+)
+
+// Conn is what the runtime needs from a generated Conn
+type Conn interface {
+	SQLTable(table string) string
+	SQLColumn(table, column string) string
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Dialect() Dialect
+}
+
+// Query is the state of a scope, the SQL it builds is
+//
+//	SELECT (columns) FROM (table) (joins) WHERE (conditions)
+//	GROUP BY (grouping) HAVING (havings)
+//	ORDER BY (orderings) LIMIT (limit) OFFSET (offset)
+type Query struct {
+	conn                        Conn
+	schema                      *schema.Schema
+	table, tableAlias           string
+	columns                     []string
+	order                       []string
+	joins                       []string
+	joined                      []joinedScope
+	conditions                  []condition
+	having                      []string
+	haveVals                    []interface{}
+	groupBy                     []string
+	currentColumn, currentAlias string
+	isDistinct                  bool
+	limit, offset               *int64
+	updates                     map[string]interface{}
+	// shared queries belong to a Conn and are copied before being changed
+	shared bool
+	// distinct selects DISTINCT rows, it's set when plucking a distinct column
+	distinct bool
+}
+
+type joinedScope struct {
+	q    *Query
+	name string
+}
+
+type condition struct {
+	column string
+	cond   string
+	vals   []interface{}
+	// softDelete marks the condition hiding soft deleted rows
+	softDelete bool
+	// variant marks the condition limiting a variant's scope to its rows
+	variant bool
+}
+
+func (c condition) ToSQL() string {
+	if c.column == "" {
+		return c.cond
+	}
+	return c.column + " " + c.cond
+}
+
+// clone copies the query, the slices are capped so appending to the copy
+// never writes into the original.
+func (q *Query) clone() *Query {
+	c := *q
+	c.columns = c.columns[:len(c.columns):len(c.columns)]
+	c.order = c.order[:len(c.order):len(c.order)]
+	c.joins = c.joins[:len(c.joins):len(c.joins)]
+	c.joined = c.joined[:len(c.joined):len(c.joined)]
+	c.conditions = c.conditions[:len(c.conditions):len(c.conditions)]
+	c.having = c.having[:len(c.having):len(c.having)]
+	c.haveVals = c.haveVals[:len(c.haveVals):len(c.haveVals)]
+	c.groupBy = c.groupBy[:len(c.groupBy):len(c.groupBy)]
+	if c.updates != nil {
+		c.updates = make(map[string]interface{}, len(q.updates))
+		for k, v := range q.updates {
+			c.updates[k] = v
+		}
+	}
+	c.shared = false
+	return &c
+}
+
+// tableName is the name the table is referred to by in the query
+func (q *Query) tableName(name string) string {
+	if q.tableAlias != "" {
+		return q.tableAlias
+	}
+	return q.conn.SQLTable(name)
+}
+
+func (q *Query) joinable(name string) string {
+	if q.tableAlias != "" {
+		return fmt.Sprintf("%s AS %s", q.conn.SQLTable(name), q.tableAlias)
+	}
+	return q.conn.SQLTable(name)
+}
+
+func (q *Query) column(name, column string) string {
+	return q.tableName(name) + "." + q.conn.SQLColumn(name, column)
+}
+
+func (q *Query) query() (string, []interface{}) {
+	sql := []string{"SELECT"}
+	vals := []interface{}{}
+	dialect := q.conn.Dialect()
+
+	// SQL Server has no LIMIT, a bare limit becomes TOP while anything with
+	// an offset needs the OFFSET ... FETCH NEXT form after the ORDER BY
+	top := dialect.FetchNext && q.limit != nil && q.offset == nil && len(q.order) == 0
+	if q.distinct {
+		sql = append(sql, "DISTINCT")
+	}
+	if top {
+		sql = append(sql, fmt.Sprintf("TOP %v", *q.limit))
+	}
+	if len(q.columns) == 0 {
+		sql = append(sql, q.table+".*")
+	} else {
+		sql = append(sql, strings.Join(q.columns, ", "))
+	}
+	sql = append(sql, "FROM", q.table)
+	sql = append(sql, q.joins...)
+
+	if len(q.conditions) > 0 {
+		cs, cv := q.conditionSQL()
+		sql = append(sql, "WHERE", cs)
+		vals = append(vals, cv...)
+	}
+
+	if len(q.groupBy) > 0 {
+		sql = append(sql, "GROUP BY", strings.Join(q.groupBy, ", "))
+	}
+
+	if len(q.having) > 0 {
+		sql = append(sql, "HAVING")
+		sql = append(sql, q.having...)
+		vals = append(vals, q.haveVals...)
+	}
+
+	if len(q.order) > 0 {
+		sql = append(sql, "ORDER BY")
+		sql = append(sql, q.order...)
+	}
+
+	if dialect.FetchNext {
+		if top || (q.limit == nil && q.offset == nil) {
+			return strings.Join(sql, " "), vals
+		}
+		if len(q.order) == 0 {
+			sql = append(sql, "ORDER BY (SELECT NULL)")
+		}
+		var offset int64
+		if q.offset != nil {
+			offset = *q.offset
+		}
+		sql = append(sql, fmt.Sprintf("OFFSET %v ROWS", offset))
+		if q.limit != nil {
+			sql = append(sql, fmt.Sprintf("FETCH NEXT %v ROWS ONLY", *q.limit))
+		}
+		return strings.Join(sql, " "), vals
+	}
+
+	if q.limit != nil {
+		sql = append(sql, "LIMIT", fmt.Sprintf("%v", *q.limit))
+	}
+
+	if q.offset != nil {
+		sql = append(sql, "OFFSET", fmt.Sprintf("%v", *q.offset))
+	}
+
+	return strings.Join(sql, " "), vals
+}
+
+func (q *Query) conditionSQL() (string, []interface{}) {
+	var vals []interface{}
+	conds := []string{}
+	for _, condition := range q.conditions {
+		conds = append(conds, condition.ToSQL())
+		vals = append(vals, condition.vals...)
+	}
+	return strings.Join(conds, " AND "), vals
+}
+
+func (q *Query) updateSQL(name string) (string, []interface{}) {
+	sql := fmt.Sprintf("UPDATE %s SET ", q.conn.SQLTable(name))
+
+	updates := []string{}
+	vals := []interface{}{}
+	for col, val := range q.updates {
+		updates = append(updates, col+" = ?")
+		vals = append(vals, val)
+	}
+	sql += strings.Join(updates, ", ")
+
+	if len(q.conditions) > 0 {
+		cs, cv := q.conditionSQL()
+		sql += " WHERE " + cs
+		vals = append(vals, cv...)
+	}
+	return sql, vals
+}
+
+// deleteSQL deletes the rows the query matches, when the query joins other
+// tables the primary keys are looked up first and deleted by themselves.
+func (q *Query) deleteSQL(name, pk, deletedAt string) (string, []interface{}) {
+	del := q
+	if len(q.joins) > 0 || len(q.having) > 0 {
+		sel := q.clone()
+		sel.currentColumn = q.column(name, pk)
+		sel.isDistinct = true
+		ids, err := sel.pluckInt()
+		if err != nil {
+			return "", []interface{}{err}
+		}
+		del = q.clone()
+		del.clearConditions()
+		del.currentColumn = q.conn.SQLTable(name) + "." + q.conn.SQLColumn(name, pk)
+		del.in(ids)
+	}
+	cs, cv := del.conditionSQL()
+
+	if deletedAt != "" {
+		sql := fmt.Sprintf("UPDATE %s SET %s = ?", del.table, q.conn.SQLColumn(name, deletedAt))
+		if cs != "" {
+			sql += " WHERE " + cs
+		}
+		return sql, append([]interface{}{time.Now()}, cv...)
+	}
+	if cs == "" {
+		return fmt.Sprintf("DELETE FROM %s", del.table), []interface{}{}
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", del.table, cs), cv
+}
+
+// conditions
+
+func (q *Query) addCondition(cond string, vals ...interface{}) {
+	q.conditions = append(q.conditions, condition{
+		column: q.currentColumn,
+		cond:   cond,
+		vals:   vals,
+	})
+}
+
+// isNull is whether a value is written as NULL, which is the case for nil,
+// nil pointers and Valuers like sql.NullString that aren't Valid
+func isNull(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(val); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return true
+	}
+	if v, ok := val.(driver.Valuer); ok {
+		dv, err := v.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
+
+func (q *Query) eq(val interface{}) {
+	if isNull(val) {
+		q.addCondition("IS NULL")
+	} else {
+		q.addCondition("= ?", val)
+	}
+}
+
+func (q *Query) neq(val interface{}) {
+	if isNull(val) {
+		q.addCondition("IS NOT NULL")
+	} else {
+		q.addCondition("<> ?", val)
+	}
+}
+
+// in accepts the values, or a single slice holding the values
+func (q *Query) in(vals ...interface{}) {
+	q.addCondition("IN ("+questions(len(expand(vals)))+")", expand(vals)...)
+}
+
+func (q *Query) notIn(vals ...interface{}) {
+	q.addCondition("NOT IN ("+questions(len(expand(vals)))+")", expand(vals)...)
+}
+
+func expand(vals []interface{}) []interface{} {
+	if len(vals) != 1 || vals[0] == nil {
+		return vals
+	}
+	rv := reflect.ValueOf(vals[0])
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return vals
+	}
+	expanded := make([]interface{}, rv.Len())
+	for i := range expanded {
+		expanded[i] = rv.Index(i).Interface()
+	}
+	return expanded
+}
+
+func (q *Query) where(sql string, vals ...interface{}) {
+	q.conditions = append(q.conditions, condition{cond: sql, vals: vals})
+}
+
+// clearConditions removes every condition but the soft delete check
+func (q *Query) clearConditions() {
+	goods := []condition{}
+	for _, cond := range q.conditions {
+		if cond.softDelete || cond.variant {
+			goods = append(goods, cond)
+		}
+	}
+	q.conditions = goods
+}
+
+func (q *Query) or(others []*Query) {
+	c := condition{}
+	ors := []string{}
+	for _, other := range others {
+		cond := []string{}
+		if len(other.conditions) == 1 {
+			c.vals = append(c.vals, other.conditions[0].vals...)
+			ors = append(ors, other.conditions[0].ToSQL())
+		} else {
+			for _, ocond := range other.conditions {
+				c.vals = append(c.vals, ocond.vals...)
+				cond = append(cond, ocond.ToSQL())
+			}
+			ors = append(ors, "("+strings.Join(cond, " AND ")+")")
+		}
+	}
+	c.cond = "(" + strings.Join(ors, " OR ") + ")"
+	q.conditions = append(q.conditions, c)
+}
+
+// joins
+
+func (q *Query) join(kind, name string, others []Scoper) {
+	for _, other := range others {
+		oq, oname := other.scope()
+		oq = oq.clone()
+		oq.conn = q.conn
+
+		joinString, ok := q.joinOn(name, oq, oname)
+		for _, js := range q.joined {
+			if ok {
+				break
+			}
+			joinString, ok = js.q.joinOn(js.name, oq, oname)
+		}
+		if !ok {
+			continue
+		}
+		q.joins = append(q.joins, fmt.Sprintf("%s %s ON %s", kind, oq.joinable(oname), joinString))
+		q.joined = append(q.joined, joinedScope{oq, oname})
+		q.apply(oq)
+	}
+}
+
+func (q *Query) apply(other *Query) {
+	q.conditions = append(q.conditions, other.conditions...)
+	q.joins = append(q.joins, other.joins...)
+	q.joined = append(q.joined, other.joined...)
+	q.having = append(q.having, other.having...)
+	q.haveVals = append(q.haveVals, other.haveVals...)
+	q.groupBy = append(q.groupBy, other.groupBy...)
+}
+
+type relationship struct {
+	parent, child *schema.Table
+	childColumn   *schema.Column
+	alias         string
+	// toParent is set when the joined table is the parent
+	toParent bool
+	// typeColumn and typeName are set for polymorphic relations
+	typeColumn *schema.Column
+	typeName   string
+}
+
+// joinOn finds the relationship between the table name and the joinee in
+// the schema and returns the ON clause for it. An aliased joinee is joined
+// through the relation of that name when there is one, so a table can be
+// joined to itself or to a table it refers to through several columns.
+func (q *Query) joinOn(name string, joinee *Query, joineeName string) (string, bool) {
+	if q.schema == nil || q.schema.Tables[name] == nil {
+		return "", false
+	}
+	ts := q.schema.Tables[name]
+	relationships := []relationship{}
+	for _, r := range ts.HasMany {
+		relationships = append(relationships, relationship{r.Parent, r.Child, r.ChildColumn, r.Alias, false, r.TypeColumn, r.TypeName})
+	}
+	for _, r := range ts.HasOne {
+		relationships = append(relationships, relationship{r.Parent, r.Child, r.ChildColumn, r.Alias, false, nil, ""})
+	}
+	for _, r := range ts.ChildOf {
+		relationships = append(relationships, relationship{r.Parent, r.Child, r.ChildColumn, r.Alias, true, r.TypeColumn, r.TypeName})
+	}
+	for _, r := range ts.BelongsTo {
+		relationships = append(relationships, relationship{r.Parent, r.Child, r.ChildColumn, r.Alias, true, nil, ""})
+	}
+
+	joineeTable := joinee.tableName(joineeName)
+	var found *relationship
+	for i, r := range relationships {
+		other := r.child
+		if r.toParent {
+			other = r.parent
+		}
+		if other.Name != joineeName {
+			continue
+		}
+		if r.alias == joinee.tableAlias {
+			found = &relationships[i]
+			break
+		}
+		if found == nil {
+			found = &relationships[i]
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+
+	parent, child := q.tableName(name), joineeTable
+	if found.toParent {
+		parent, child = joineeTable, q.tableName(name)
+	}
+	on := fmt.Sprintf(
+		"%s.%s = %s.%s",
+		parent,
+		q.conn.SQLColumn(found.parent.Name, found.parent.PrimaryKeyColumn().Name),
+		child,
+		q.conn.SQLColumn(found.child.Name, found.childColumn.Name),
+	)
+	if found.typeColumn != nil {
+		on += fmt.Sprintf(
+			" AND %s.%s = '%s'",
+			child,
+			q.conn.SQLColumn(found.child.Name, found.typeColumn.Name),
+			strings.Replace(found.typeName, "'", "''", -1),
+		)
+	}
+	return on, true
+}
+
+// plucking
+
+func (q *Query) pluckQuery() (string, []interface{}) {
+	p := q.clone()
+	p.distinct = p.isDistinct
+	p.columns = []string{p.currentColumn}
+	return p.query()
+}
+
+func pluck[T any](q *Query) ([]T, error) {
+	ss, vv := q.pluckQuery()
+	rows, err := q.conn.Query(ss, vv...)
+	if err != nil {
+		return []T{}, err
+	}
+	defer rows.Close()
+
+	vals := []T{}
+	for rows.Next() {
+		var temp T
+		err = rows.Scan(&temp)
+		if err != nil {
+			return []T{}, err
+		}
+		vals = append(vals, temp)
+	}
+	return vals, rows.Err()
+}
+
+func (q *Query) pluckInt() ([]int64, error) {
+	return pluck[int64](q)
+}
+
+func (q *Query) countBy(sql string) int64 {
+	c := q.clone()
+	c.columns = []string{sql}
+	ss, sv := c.query()
+	var value int64
+	err := q.conn.QueryRow(ss, sv...).Scan(&value)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func (q *Query) countOf() int64 {
+	if q.isDistinct {
+		return q.countBy(fmt.Sprintf("COUNT(DISTINCT %s)", q.currentColumn))
+	}
+	return q.countBy(fmt.Sprintf("COUNT(%s)", q.currentColumn))
+}
+
+func questions(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
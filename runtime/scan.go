@@ -0,0 +1,233 @@
+package runtime
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Field is a column of a record and where it is scanned to
+type Field struct {
+	Column string
+	Dest   sql.Scanner
+}
+
+// Retrieve loads the first row the scope matches, fields lists the columns
+// of a record and the scanners filling them in.
+func Retrieve[R any](s Scoper, fields func(*R) []Field) (R, error) {
+	var val R
+	q, name := s.scope()
+	fs := fields(&val)
+	ss, vv := q.selecting(name, fs).query()
+
+	dests := make([]interface{}, len(fs))
+	for i, f := range fs {
+		dests[i] = f.Dest
+	}
+	err := q.conn.QueryRow(ss, vv...).Scan(dests...)
+	if err != nil {
+		err = fmt.Errorf("SQL: %s\n%w", ss, err)
+	}
+	return val, err
+}
+
+// RetrieveAll loads every row the scope matches
+func RetrieveAll[R any](s Scoper, fields func(*R) []Field) ([]R, error) {
+	var zero R
+	q, name := s.scope()
+	ss, vv := q.selecting(name, fields(&zero)).query()
+	rows, err := q.conn.Query(ss, vv...)
+	if err != nil {
+		return []R{}, fmt.Errorf("SQL: %s\n%w", ss, err)
+	}
+	defer rows.Close()
+
+	vals := []R{}
+	for rows.Next() {
+		var temp R
+		fs := fields(&temp)
+		dests := make([]interface{}, len(fs))
+		for i, f := range fs {
+			dests[i] = f.Dest
+		}
+		err = rows.Scan(dests...)
+		if err != nil {
+			return []R{}, err
+		}
+		vals = append(vals, temp)
+	}
+	return vals, rows.Err()
+}
+
+// selecting returns a copy of the query selecting the fields' columns
+func (q *Query) selecting(name string, fields []Field) *Query {
+	s := q.clone()
+	s.columns = make([]string, len(fields))
+	for i, f := range fields {
+		s.columns[i] = q.column(name, f.Column)
+	}
+	return s
+}
+
+// Scanner returns a sql.Scanner that stores a column in dest, converting
+// the values drivers return for it. Pointer destinations are left nil for
+// NULL.
+func Scanner[T any](dest *T) sql.Scanner {
+	return scanner{dest}
+}
+
+type scanner struct {
+	dest interface{}
+}
+
+func (s scanner) Scan(v interface{}) error {
+	return convert(s.dest, v)
+}
+
+// convert stores the value v from a driver in dest
+func convert(dest, v interface{}) error {
+	if sc, ok := dest.(sql.Scanner); ok {
+		return sc.Scan(v)
+	}
+
+	switch d := dest.(type) {
+	case *int, *int8, *int16, *int32, *int64, *uint, *uint8, *uint16, *uint32, *uint64:
+		return setInteger(reflect.ValueOf(dest).Elem(), v)
+	case *string:
+		if s, ok := v.(string); ok {
+			*d = s
+		} else if b, ok := v.([]byte); ok {
+			*d = string(b)
+		}
+	case *time.Time:
+		if t, ok := v.(time.Time); ok {
+			*d = t
+		}
+	case *bool:
+		if b, ok := v.(bool); ok {
+			*d = b
+		} else if i, ok := v.(int64); ok {
+			*d = i != 0
+		}
+	case *float64:
+		f, err := toFloat(v, 64)
+		*d = f
+		return err
+	case *float32:
+		f, err := toFloat(v, 32)
+		*d = float32(f)
+		return err
+	case *[]byte:
+		if b, ok := v.([]byte); ok {
+			*d = b
+		}
+	default:
+		rv := reflect.ValueOf(dest)
+		if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Ptr {
+			return fmt.Errorf("Can't scan %T into %T", v, dest)
+		}
+		if v == nil {
+			rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+			return nil
+		}
+		val := reflect.New(rv.Elem().Type().Elem())
+		err := convert(val.Interface(), v)
+		if err != nil {
+			return err
+		}
+		rv.Elem().Set(val)
+	}
+	return nil
+}
+
+// setInteger stores an integer from a driver in an integer of any width,
+// reporting values that don't fit
+func setInteger(rv reflect.Value, v interface{}) error {
+	var text string
+	switch i := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		text = strconv.FormatInt(i, 10)
+	case uint64:
+		text = strconv.FormatUint(i, 10)
+	case []byte:
+		text = string(i)
+	case string:
+		text = i
+	default:
+		return fmt.Errorf("Can't scan %T into %s", v, rv.Type())
+	}
+
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(u)
+	default:
+		i, err := strconv.ParseInt(text, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	}
+	return nil
+}
+
+func toFloat(v interface{}, bits int) (float64, error) {
+	switch f := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return f, nil
+	case int64:
+		return float64(f), nil
+	case []byte:
+		return strconv.ParseFloat(string(f), bits)
+	}
+	return 0, fmt.Errorf("Value not recognized as float%d, received %v", bits, v)
+}
+
+func pluckStruct(q *Query, name string, result interface{}) error {
+	destSlice := reflect.ValueOf(result).Elem()
+	tempSlice := reflect.Zero(destSlice.Type())
+	elem := destSlice.Type().Elem()
+
+	p := q.clone()
+	p.columns = nil
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Field(i)
+		if f.Tag.Get("column") != "" {
+			p.columns = append(p.columns, f.Tag.Get("column"))
+		} else {
+			p.columns = append(p.columns, q.conn.SQLTable(name)+"."+q.conn.SQLColumn(name, f.Name))
+		}
+	}
+
+	ss, sv := p.query()
+	rows, err := q.conn.Query(ss, sv...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := reflect.New(elem).Elem()
+		dests := make([]interface{}, elem.NumField())
+		for i := range dests {
+			dests[i] = scanner{item.Field(i).Addr().Interface()}
+		}
+		err = rows.Scan(dests...)
+		if err != nil {
+			return err
+		}
+		tempSlice = reflect.Append(tempSlice, item)
+	}
+	destSlice.Set(tempSlice)
+
+	return rows.Err()
+}
@@ -0,0 +1,146 @@
+package runtime
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestScanner(t *testing.T) {
+	var (
+		i  int
+		s  string
+		b  bool
+		f  float64
+		f3 float32
+		tm time.Time
+		ip *int
+		sp *string
+	)
+	now := time.Now()
+	scans := []struct {
+		dest  interface{ Scan(interface{}) error }
+		value interface{}
+	}{
+		{Scanner(&i), int64(4)},
+		{Scanner(&s), []byte("text")},
+		{Scanner(&b), int64(1)},
+		{Scanner(&f), []byte("1.5")},
+		{Scanner(&f3), int64(2)},
+		{Scanner(&tm), now},
+		{Scanner(&ip), int64(7)},
+		{Scanner(&sp), nil},
+	}
+	for _, scan := range scans {
+		if err := scan.dest.Scan(scan.value); err != nil {
+			t.Errorf("Scanning %v: %v", scan.value, err)
+		}
+	}
+
+	if i != 4 || s != "text" || !b || f != 1.5 || f3 != 2 || !tm.Equal(now) {
+		t.Error("Unexpected values", i, s, b, f, f3, tm)
+	}
+	if ip == nil || *ip != 7 || sp != nil {
+		t.Error("Unexpected pointers", ip, sp)
+	}
+
+	if err := Scanner(&f).Scan("nope"); err == nil {
+		t.Error("Expected an error scanning a string into a float64")
+	}
+	var c complex64
+	if err := Scanner(&c).Scan(int64(1)); err == nil {
+		t.Error("Expected an error scanning into an unsupported type")
+	}
+}
+
+func TestScanIntegers(t *testing.T) {
+	var (
+		i8  int8
+		i16 int16
+		i64 int64
+		u8  uint8
+		u32 uint32
+		u64 uint64
+	)
+	scans := []struct {
+		dest  sql.Scanner
+		value interface{}
+	}{
+		{Scanner(&i8), int64(-8)},
+		{Scanner(&i16), []byte("-16")},
+		{Scanner(&i64), "9007199254740993"},
+		{Scanner(&u8), int64(255)},
+		{Scanner(&u32), []byte("4294967295")},
+		{Scanner(&u64), uint64(1<<64 - 1)},
+	}
+	for _, scan := range scans {
+		if err := scan.dest.Scan(scan.value); err != nil {
+			t.Errorf("Scanning %v: %v", scan.value, err)
+		}
+	}
+	if i8 != -8 || i16 != -16 || i64 != 9007199254740993 || u8 != 255 || u32 != 4294967295 || u64 != 1<<64-1 {
+		t.Error("Unexpected integers", i8, i16, i64, u8, u32, u64)
+	}
+
+	if err := Scanner(&i8).Scan(int64(128)); err == nil {
+		t.Error("Expected 128 to overflow an int8")
+	}
+	if err := Scanner(&u8).Scan(int64(-1)); err == nil {
+		t.Error("Expected -1 to be an error for a uint8")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var settings map[string]int
+	if err := JSON(&settings).Scan([]byte(`{"a":1}`)); err != nil || settings["a"] != 1 {
+		t.Error("Unexpected JSON scan", settings, err)
+	}
+	if v, err := JSON(&settings).Value(); v != `{"a":1}` || err != nil {
+		t.Error("Unexpected JSON value", v, err)
+	}
+	if err := JSON(&settings).Scan(nil); err != nil || settings != nil {
+		t.Error("Expected NULL to clear the map", settings, err)
+	}
+
+	var tags *[]string
+	if v, err := JSON(&tags).Value(); v != nil || err != nil {
+		t.Error("Expected a nil pointer to be NULL", v, err)
+	}
+	if err := JSON(&tags).Scan("[\"a\"]"); err != nil || tags == nil || (*tags)[0] != "a" {
+		t.Error("Unexpected JSON pointer scan", tags, err)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	levels := Enum{Type: "Level", Names: []string{"Low", "High"}}
+	if i, err := levels.Scan([]byte("High")); i != 1 || err != nil {
+		t.Error("Unexpected enum scan", i, err)
+	}
+	if _, err := levels.Scan("Medium"); err == nil {
+		t.Error("Scanned an unknown name")
+	}
+	if v, err := levels.Value(0); v != "Low" || err != nil {
+		t.Error("Unexpected enum value", v, err)
+	}
+	if _, err := levels.Value(2); err == nil || levels.Name(2) != "Level(2)" {
+		t.Error("Expected 2 to be invalid", levels.Name(2), err)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	var d Decimal
+	for _, v := range []interface{}{"12.50", []byte("12.50"), 12.5, int64(12)} {
+		if err := d.Scan(v); err != nil || d.Cmp("12.5") > 0 || d.Cmp("12") < 0 {
+			t.Error("Unexpected decimal scan", v, d, err)
+		}
+	}
+	if err := d.Scan("twelve"); err == nil {
+		t.Error("Scanned an invalid decimal")
+	}
+	if v, err := Decimal("").Value(); v != "0" || err != nil {
+		t.Error("Expected the zero Decimal to be 0", v, err)
+	}
+	if _, err := ParseDecimal("1,5"); err == nil {
+		t.Error("Parsed an invalid decimal")
+	}
+}
@@ -0,0 +1,475 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acsellers/dr/schema"
+)
+
+// Scoper is implemented by every generated scope, it's what joins and
+// combining conditions accept.
+type Scoper interface {
+	QuerySQL() (string, []interface{})
+	UpdateSQL() (string, []interface{})
+	DeleteSQL() (string, []interface{})
+	scope() (*Query, string)
+}
+
+// Table describes a table to the Scope of a generated scope type S, C is
+// the generated Conn.
+type Table[S any, C Conn] struct {
+	Name       string
+	PrimaryKey string
+	// DeletedAt is the soft delete column, empty when rows are deleted
+	DeletedAt string
+	// Variant is set for the scopes of a table variant, which only see the
+	// rows with Variant stored in TypeColumn
+	TypeColumn, Variant string
+	Schema              *schema.Schema
+	// Wrap makes the generated scope type around a Scope
+	Wrap func(Scope[S, C]) S
+}
+
+// New returns a scope for the table with no conditions
+func (t *Table[S, C]) New(c C) S {
+	q := &Query{
+		conn:          c,
+		schema:        t.Schema,
+		table:         c.SQLTable(t.Name),
+		currentColumn: c.SQLTable(t.Name) + "." + c.SQLColumn(t.Name, t.PrimaryKey),
+	}
+	if t.DeletedAt != "" {
+		q.conditions = []condition{
+			condition{
+				column:     q.table + "." + c.SQLColumn(t.Name, t.DeletedAt),
+				cond:       "IS NULL",
+				softDelete: true,
+			},
+		}
+	}
+	if t.Variant != "" {
+		q.conditions = append(q.conditions, condition{
+			column:  q.table + "." + c.SQLColumn(t.Name, t.TypeColumn),
+			cond:    "= ?",
+			vals:    []interface{}{t.Variant},
+			variant: true,
+		})
+	}
+	return t.Wrap(Scope[S, C]{q, t})
+}
+
+// From makes a scope for the table out of the query of another scope, it is
+// used to follow relations after joining. The scope refers to the table by
+// the alias it was last joined with.
+func (t *Table[S, C]) From(s Scoper) S {
+	q, _ := s.scope()
+	q = q.clone()
+	q.tableAlias = ""
+	for i := len(q.joined) - 1; i >= 0; i-- {
+		if q.joined[i].name == t.Name {
+			q.tableAlias = q.joined[i].q.tableAlias
+			break
+		}
+	}
+	q.currentColumn = q.column(t.Name, t.PrimaryKey)
+	return t.Wrap(Scope[S, C]{q, t})
+}
+
+// Share marks a scope as the one held by a Conn, changing it returns a
+// changed copy and leaves it as it was.
+func Share(s Scoper) {
+	q, _ := s.scope()
+	q.shared = true
+}
+
+// Scope is embedded in the generated scope types and holds every method
+// that doesn't depend on the table's record type.
+type Scope[S any, C Conn] struct {
+	q *Query
+	t *Table[S, C]
+}
+
+func (s Scope[S, C]) scope() (*Query, string) {
+	return s.q, s.t.Name
+}
+
+// edit returns the scope to change, a copy when the scope is shared
+func (s Scope[S, C]) edit(f func(q *Query)) S {
+	if s.q.shared {
+		s.q = s.q.clone()
+	}
+	f(s.q)
+	return s.t.Wrap(s)
+}
+
+func (s Scope[S, C]) Conn() C {
+	return s.q.conn.(C)
+}
+
+func (s Scope[S, C]) SetConn(conn C) S {
+	return s.edit(func(q *Query) {
+		q.conn = conn
+	})
+}
+
+// Column makes column the current column, conditions and ordering apply to
+// it and any values given are added as Eq conditions.
+func (s Scope[S, C]) Column(column string, eq ...interface{}) S {
+	return s.edit(func(q *Query) {
+		q.currentColumn = q.column(s.t.Name, column)
+		q.currentAlias = ""
+		q.isDistinct = false
+		for _, ev := range eq {
+			q.eq(ev)
+		}
+	})
+}
+
+// JSONPath follows keys into the current column, which holds JSON, so the
+// conditions and ordering after it apply to the value found there
+func (s Scope[S, C]) JSONPath(keys ...string) S {
+	return s.edit(func(q *Query) {
+		q.currentColumn = q.conn.Dialect().jsonPath(q.currentColumn, keys)
+	})
+}
+
+// basic conditions
+func (s Scope[S, C]) Eq(val interface{}) S {
+	return s.edit(func(q *Query) { q.eq(val) })
+}
+
+func (s Scope[S, C]) Neq(val interface{}) S {
+	return s.edit(func(q *Query) { q.neq(val) })
+}
+
+func (s Scope[S, C]) Gt(val interface{}) S {
+	return s.edit(func(q *Query) { q.addCondition("> ?", val) })
+}
+
+func (s Scope[S, C]) Gte(val interface{}) S {
+	return s.edit(func(q *Query) { q.addCondition(">= ?", val) })
+}
+
+func (s Scope[S, C]) Lt(val interface{}) S {
+	return s.edit(func(q *Query) { q.addCondition("< ?", val) })
+}
+
+func (s Scope[S, C]) Lte(val interface{}) S {
+	return s.edit(func(q *Query) { q.addCondition("<= ?", val) })
+}
+
+// multi value conditions
+func (s Scope[S, C]) Between(lower, upper interface{}) S {
+	return s.edit(func(q *Query) { q.addCondition("BETWEEN ? AND ?", lower, upper) })
+}
+
+func (s Scope[S, C]) In(vals ...interface{}) S {
+	return s.edit(func(q *Query) { q.in(vals...) })
+}
+
+func (s Scope[S, C]) NotIn(vals ...interface{}) S {
+	return s.edit(func(q *Query) { q.notIn(vals...) })
+}
+
+func (s Scope[S, C]) Like(str string) S {
+	return s.edit(func(q *Query) { q.addCondition("LIKE ?", str) })
+}
+
+func (s Scope[S, C]) Where(sql string, vals ...interface{}) S {
+	return s.edit(func(q *Query) { q.where(sql, vals...) })
+}
+
+func (s Scope[S, C]) And(scopes ...Scoper) S {
+	return s.edit(func(q *Query) {
+		for _, other := range scopes {
+			oq, _ := other.scope()
+			q.conditions = append(q.conditions, oq.conditions...)
+		}
+	})
+}
+
+func (s Scope[S, C]) Or(scopes ...Scoper) S {
+	return s.edit(func(q *Query) {
+		others := make([]*Query, len(scopes))
+		for i, other := range scopes {
+			others[i], _ = other.scope()
+		}
+		q.or(others)
+	})
+}
+
+// ordering conditions
+func (s Scope[S, C]) Order(ordering string) S {
+	return s.edit(func(q *Query) { q.order = append(q.order, ordering) })
+}
+
+func (s Scope[S, C]) Desc() S {
+	return s.edit(func(q *Query) { q.order = append(q.order, q.currentColumn+" DESC") })
+}
+
+func (s Scope[S, C]) Asc() S {
+	return s.edit(func(q *Query) { q.order = append(q.order, q.currentColumn+" ASC") })
+}
+
+// Join funcs
+func (s Scope[S, C]) OuterJoin(things ...Scoper) S {
+	return s.edit(func(q *Query) { q.join("LEFT JOIN", s.t.Name, things) })
+}
+
+func (s Scope[S, C]) InnerJoin(things ...Scoper) S {
+	return s.edit(func(q *Query) { q.join("INNER JOIN", s.t.Name, things) })
+}
+
+// JoinBy allows you to specify the exact join SQL statment for one or more
+// tables. You can also pass the Scope objects that you are manually joining,
+// which are recorded for future Joining to work off of.
+func (s Scope[S, C]) JoinBy(joins string, joinedScopes ...Scoper) S {
+	return s.edit(func(q *Query) {
+		q.joins = append(q.joins, joins)
+		for _, other := range joinedScopes {
+			oq, name := other.scope()
+			q.joined = append(q.joined, joinedScope{oq, name})
+		}
+	})
+}
+
+// aggregation filtering
+func (s Scope[S, C]) Having(sql string, vals ...interface{}) S {
+	return s.edit(func(q *Query) {
+		q.having = append(q.having, sql)
+		q.haveVals = append(q.haveVals, vals...)
+	})
+}
+
+func (s Scope[S, C]) GroupBySQL(cols ...string) S {
+	return s.edit(func(q *Query) { q.groupBy = append(q.groupBy, cols...) })
+}
+
+// Result count filtering
+func (s Scope[S, C]) Limit(limit int64) S {
+	return s.edit(func(q *Query) { q.limit = &limit })
+}
+
+func (s Scope[S, C]) Offset(offset int64) S {
+	return s.edit(func(q *Query) { q.offset = &offset })
+}
+
+// misc scope operations
+
+// Clear removes the conditions on the current column
+func (s Scope[S, C]) Clear() S {
+	return s.edit(func(q *Query) {
+		goods := []condition{}
+		for _, cond := range q.conditions {
+			if cond.softDelete || cond.variant || cond.column != q.currentColumn {
+				goods = append(goods, cond)
+			}
+		}
+		q.conditions = goods
+	})
+}
+
+// ClearAll removes every condition, soft deleted rows and the rows of other
+// variants stay hidden
+func (s Scope[S, C]) ClearAll() S {
+	return s.edit(func(q *Query) { q.clearConditions() })
+}
+
+// WithDeleted lets the scope find soft deleted records
+func (s Scope[S, C]) WithDeleted() S {
+	return s.edit(func(q *Query) {
+		goods := []condition{}
+		for _, cond := range q.conditions {
+			if !cond.softDelete {
+				goods = append(goods, cond)
+			}
+		}
+		q.conditions = goods
+	})
+}
+
+// Base returns a scope for the table with no conditions
+func (s Scope[S, C]) Base() S {
+	return s.t.New(s.Conn())
+}
+
+func (s Scope[S, C]) Clone() S {
+	s.q = s.q.clone()
+	return s.t.Wrap(s)
+}
+
+// As sets a column alias
+func (s Scope[S, C]) As(alias string) S {
+	return s.edit(func(q *Query) { q.currentAlias = alias })
+}
+
+// Alias sets a table alias
+func (s Scope[S, C]) Alias(alias string) S {
+	return s.edit(func(q *Query) {
+		q.tableAlias = alias
+		if s.t.DeletedAt == "" && s.t.Variant == "" {
+			return
+		}
+		// the deleted row and variant checks have to follow the table to
+		// its new name
+		conds := make([]condition, len(q.conditions))
+		for i, cond := range q.conditions {
+			if cond.softDelete {
+				cond.column = alias + "." + q.conn.SQLColumn(s.t.Name, s.t.DeletedAt)
+			}
+			if cond.variant {
+				cond.column = alias + "." + q.conn.SQLColumn(s.t.Name, s.t.TypeColumn)
+			}
+			conds[i] = cond
+		}
+		q.conditions = conds
+	})
+}
+
+func (s Scope[S, C]) Distinct() S {
+	return s.edit(func(q *Query) { q.isDistinct = true })
+}
+
+// Scope attribute updating
+func (s Scope[S, C]) Set(val interface{}) S {
+	return s.edit(func(q *Query) {
+		if q.updates == nil {
+			q.updates = make(map[string]interface{})
+		}
+		colName := strings.TrimPrefix(q.currentColumn, q.conn.SQLTable(s.t.Name)+".")
+		q.updates[colName] = val
+	})
+}
+
+func (s Scope[S, C]) Update() error {
+	sql, vals := s.UpdateSQL()
+	_, err := s.q.conn.Exec(sql, vals...)
+	return err
+}
+
+// subset plucking
+func (s Scope[S, C]) Pick(sql string) S {
+	return s.edit(func(q *Query) {
+		q.isDistinct = false
+		q.currentColumn = sql
+	})
+}
+
+func (s Scope[S, C]) PluckString() ([]string, error) {
+	return pluck[string](s.q)
+}
+
+func (s Scope[S, C]) PluckInt() ([]int64, error) {
+	return s.q.pluckInt()
+}
+
+func (s Scope[S, C]) PluckTime() ([]time.Time, error) {
+	return pluck[time.Time](s.q)
+}
+
+// Pluck loads the current column of a scope as a slice of T, for the
+// column types PluckString, PluckInt and PluckTime don't cover. Values are
+// converted as they are for records, so integers that don't fit in T are
+// an error.
+func Pluck[T any](s Scoper) ([]T, error) {
+	q, _ := s.scope()
+	ss, vv := q.pluckQuery()
+	rows, err := q.conn.Query(ss, vv...)
+	if err != nil {
+		return []T{}, err
+	}
+	defer rows.Close()
+
+	vals := []T{}
+	for rows.Next() {
+		var temp T
+		err = rows.Scan(Scanner(&temp))
+		if err != nil {
+			return []T{}, err
+		}
+		vals = append(vals, temp)
+	}
+	return vals, rows.Err()
+}
+
+// PluckStruct loads the rows into result, a pointer to a slice of structs.
+// Fields are read from the column of the same name, or the SQL in their
+// column tag.
+func (s Scope[S, C]) PluckStruct(result interface{}) error {
+	return pluckStruct(s.q, s.t.Name, result)
+}
+
+// direct sql
+func (s Scope[S, C]) Count() int64 {
+	c := s.q.clone()
+	c.currentColumn = c.column(s.t.Name, s.t.PrimaryKey)
+	c.isDistinct = true
+	return c.countOf()
+}
+
+func (s Scope[S, C]) CountBy(sql string) int64 {
+	return s.q.countBy(sql)
+}
+
+func (s Scope[S, C]) CountOf() int64 {
+	return s.q.countOf()
+}
+
+func (s Scope[S, C]) UpdateBySQL(sql string, vals ...interface{}) error {
+	c := s.q.clone()
+	c.columns = []string{""}
+	ss, sv := c.query()
+	ss = strings.TrimPrefix(ss, "SELECT  FROM "+c.table)
+	ss = fmt.Sprintf("UPDATE %s SET %s %s", c.table, sql, ss)
+	_, err := s.q.conn.Exec(ss, append(vals, sv...)...)
+	return err
+}
+
+// Delete removes the records in the scope. When the table has relations
+// with a Dependent setting, the records depending on them are handled by
+// DeleteDependents first and the records are deleted by their primary keys.
+func (s Scope[S, C]) Delete() error {
+	if len(dependentsOf(s.t.Schema, s.t.Name)) > 0 {
+		sel := s.q.clone()
+		sel.currentColumn = sel.column(s.t.Name, s.t.PrimaryKey)
+		sel.isDistinct = true
+		ids, err := sel.pluckInt()
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err = DeleteDependents(s.q.conn, s.t.Schema, s.t.Name, ids); err != nil {
+			return err
+		}
+		return deleteByID(s.q.conn, s.t.Schema.Tables[s.t.Name], ids)
+	}
+
+	sql, cv := s.DeleteSQL()
+	if sql == "" {
+		if err, ok := cv[0].(error); ok {
+			return err
+		}
+		return fmt.Errorf("Unspecified Error in DeleteSQL()")
+	}
+	_, err := s.q.conn.Exec(sql, cv...)
+	if err != nil {
+		return fmt.Errorf("Encountered error: %v\nSQL: %s %v", err, sql, cv)
+	}
+	return nil
+}
+
+func (s Scope[S, C]) QuerySQL() (string, []interface{}) {
+	return s.q.query()
+}
+
+func (s Scope[S, C]) UpdateSQL() (string, []interface{}) {
+	return s.q.updateSQL(s.t.Name)
+}
+
+func (s Scope[S, C]) DeleteSQL() (string, []interface{}) {
+	return s.q.deleteSQL(s.t.Name, s.t.PrimaryKey, s.t.DeletedAt)
+}
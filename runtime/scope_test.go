@@ -0,0 +1,289 @@
+package runtime
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/acsellers/dr/schema"
+)
+
+type testConn struct {
+	dialect Dialect
+}
+
+func (testConn) SQLTable(table string) string          { return table }
+func (testConn) SQLColumn(table, column string) string { return column }
+func (c testConn) Dialect() Dialect                    { return c.dialect }
+
+func (testConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+func (testConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+func (testConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+type userScope struct {
+	Scope[*userScope, testConn]
+}
+
+type postScope struct {
+	Scope[*postScope, testConn]
+}
+
+var testSchema = func() *schema.Schema {
+	user := &schema.Table{Name: "User", Columns: []schema.Column{{Name: "ID"}}}
+	post := &schema.Table{Name: "Post", Columns: []schema.Column{{Name: "ID"}, {Name: "UserID"}}}
+	rel := schema.ManyRelationship{Parent: user, Child: post, ChildColumn: &post.Columns[1]}
+	user.HasMany = append(user.HasMany, rel)
+	post.ChildOf = append(post.ChildOf, rel)
+	return &schema.Schema{Tables: map[string]*schema.Table{"User": user, "Post": post}}
+}()
+
+var userTable = &Table[*userScope, testConn]{
+	Name:       "User",
+	PrimaryKey: "ID",
+	DeletedAt:  "DeletedAt",
+	Schema:     testSchema,
+	Wrap:       func(s Scope[*userScope, testConn]) *userScope { return &userScope{s} },
+}
+
+var postTable = &Table[*postScope, testConn]{
+	Name:       "Post",
+	PrimaryKey: "ID",
+	Schema:     testSchema,
+	Wrap:       func(s Scope[*postScope, testConn]) *postScope { return &postScope{s} },
+}
+
+func TestScopeSQL(t *testing.T) {
+	users := userTable.New(testConn{})
+	Share(users)
+
+	sql, vals := users.Column("Name", "Cthulhu").Limit(5).QuerySQL()
+	if sql != "SELECT User.* FROM User WHERE User.DeletedAt IS NULL AND User.Name = ? LIMIT 5" || len(vals) != 1 {
+		t.Error("Unexpected query", sql, vals)
+	}
+	if sql, _ := users.QuerySQL(); sql != "SELECT User.* FROM User WHERE User.DeletedAt IS NULL" {
+		t.Error("Shared scope was changed", sql)
+	}
+
+	sql, vals = users.ClearAll().Column("ID").In([]int{1, 2, 3}).QuerySQL()
+	if sql != "SELECT User.* FROM User WHERE User.DeletedAt IS NULL AND User.ID IN (?, ?, ?)" || len(vals) != 3 {
+		t.Error("Unexpected In query", sql, vals)
+	}
+
+	sql, _ = users.WithDeleted().Column("Name").Desc().Offset(10).QuerySQL()
+	if sql != "SELECT User.* FROM User ORDER BY User.Name DESC OFFSET 10" {
+		t.Error("Unexpected WithDeleted query", sql)
+	}
+
+	sql, vals = users.Column("Name").Eq("a").DeleteSQL()
+	if sql != "UPDATE User SET DeletedAt = ? WHERE User.DeletedAt IS NULL AND User.Name = ?" || len(vals) != 2 {
+		t.Error("Unexpected soft delete", sql, vals)
+	}
+}
+
+func TestScopeNull(t *testing.T) {
+	posts := postTable.New(testConn{})
+	var parent *int
+
+	query, vals := posts.Column("ParentID", parent).Column("Title").Neq(sql.NullString{}).QuerySQL()
+	if query != "SELECT Post.* FROM Post WHERE Post.ParentID IS NULL AND Post.Title IS NOT NULL" || len(vals) != 0 {
+		t.Error("Unexpected NULL query", query, vals)
+	}
+}
+
+func TestScopeJoin(t *testing.T) {
+	users := userTable.New(testConn{})
+	posts := postTable.New(testConn{})
+	Share(users)
+	Share(posts)
+
+	sql, _ := users.InnerJoin(posts.Column("Title", "a")).QuerySQL()
+	if sql != "SELECT User.* FROM User INNER JOIN Post ON User.ID = Post.UserID WHERE User.DeletedAt IS NULL AND Post.Title = ?" {
+		t.Error("Unexpected join", sql)
+	}
+
+	sql, _ = postTable.From(posts.OuterJoin(users)).QuerySQL()
+	if sql != "SELECT Post.* FROM Post LEFT JOIN User ON User.ID = Post.UserID WHERE User.DeletedAt IS NULL" {
+		t.Error("Unexpected outer join", sql)
+	}
+}
+
+func TestDialect(t *testing.T) {
+	mssql := DialectFor("mssql")
+	users := userTable.New(testConn{mssql})
+
+	sql, _ := users.WithDeleted().Limit(5).QuerySQL()
+	if sql != "SELECT TOP 5 User.* FROM User" {
+		t.Error("TOP", sql)
+	}
+	q, _ := users.WithDeleted().Column("Name").Distinct().Limit(5).scope()
+	sql, _ = q.pluckQuery()
+	if sql != "SELECT DISTINCT TOP 5 User.Name FROM User" {
+		t.Error("DISTINCT TOP", sql)
+	}
+	sql, _ = users.WithDeleted().Column("Name", "a").Limit(5).Offset(10).QuerySQL()
+	if mssql.FormatQuery(sql) != "SELECT User.* FROM User WHERE User.Name = @p1 ORDER BY (SELECT NULL) OFFSET 10 ROWS FETCH NEXT 5 ROWS ONLY" {
+		t.Error("OFFSET FETCH", mssql.FormatQuery(sql))
+	}
+	paths := map[string]string{
+		"sqlite3":  `json_extract(Post.Meta, '$."a"."it''s"')`,
+		"postgres": `Post.Meta #>> '{a,it''s}'`,
+		"mysql":    `Post.Meta ->> '$."a"."it''s"'`,
+		"mssql":    `JSON_VALUE(Post.Meta, '$."a"."it''s"')`,
+	}
+	for driver, path := range paths {
+		posts := postTable.New(testConn{DialectFor(driver)})
+		sql, _ := posts.Column("Meta").JSONPath("a", "it's").Eq(1).QuerySQL()
+		if sql != "SELECT Post.* FROM Post WHERE "+path+" = ?" {
+			t.Error("JSON path for", driver, sql)
+		}
+	}
+	if DialectFor("postgres").FormatQuery("a = ? AND b = ?") != "a = $1 AND b = $2" {
+		t.Error("Postgres placeholders", DialectFor("postgres").FormatQuery("a = ? AND b = ?"))
+	}
+}
+
+func TestScopeAliasedJoin(t *testing.T) {
+	user := &schema.Table{Name: "User", Columns: []schema.Column{{Name: "ID"}}}
+	post := &schema.Table{Name: "Post", Columns: []schema.Column{{Name: "ID"}, {Name: "UserID"}, {Name: "SponsorID"}, {Name: "ParentID"}}}
+	author := schema.ManyRelationship{Parent: user, Child: post, ChildColumn: &post.Columns[1]}
+	sponsor := schema.ManyRelationship{Parent: user, Child: post, ChildColumn: &post.Columns[2], Alias: "Sponsor"}
+	children := schema.ManyRelationship{Parent: post, Child: post, ChildColumn: &post.Columns[3], Alias: "Children"}
+	parent := schema.ManyRelationship{Parent: post, Child: post, ChildColumn: &post.Columns[3], Alias: "Parent"}
+	user.HasMany = []schema.ManyRelationship{author, sponsor}
+	post.HasMany = []schema.ManyRelationship{children}
+	post.ChildOf = []schema.ManyRelationship{author, sponsor, parent}
+	s := &schema.Schema{Tables: map[string]*schema.Table{"User": user, "Post": post}}
+
+	users := &Table[*userScope, testConn]{Name: "User", PrimaryKey: "ID", Schema: s, Wrap: userTable.Wrap}
+	posts := &Table[*postScope, testConn]{Name: "Post", PrimaryKey: "ID", Schema: s, Wrap: postTable.Wrap}
+
+	sql, _ := posts.New(testConn{}).InnerJoin(users.New(testConn{}).Alias("Sponsor")).QuerySQL()
+	if sql != "SELECT Post.* FROM Post INNER JOIN User AS Sponsor ON Sponsor.ID = Post.SponsorID" {
+		t.Error("Unexpected aliased join", sql)
+	}
+	sql, _ = posts.New(testConn{}).InnerJoin(users.New(testConn{})).QuerySQL()
+	if sql != "SELECT Post.* FROM Post INNER JOIN User ON User.ID = Post.UserID" {
+		t.Error("Unexpected plain join", sql)
+	}
+
+	replies := posts.From(posts.New(testConn{}).Column("ID", 1).InnerJoin(posts.New(testConn{}).Alias("Children")))
+	sql, _ = replies.Column("Title", "a").QuerySQL()
+	if sql != "SELECT Post.* FROM Post INNER JOIN Post AS Children ON Post.ID = Children.ParentID WHERE Post.ID = ? AND Children.Title = ?" {
+		t.Error("Unexpected children join", sql)
+	}
+	sql, _ = posts.New(testConn{}).InnerJoin(posts.New(testConn{}).Alias("Parent")).QuerySQL()
+	if sql != "SELECT Post.* FROM Post INNER JOIN Post AS Parent ON Parent.ID = Post.ParentID" {
+		t.Error("Unexpected parent join", sql)
+	}
+}
+
+func TestScopePolymorphicJoin(t *testing.T) {
+	user := &schema.Table{Name: "User", Columns: []schema.Column{{Name: "ID"}}}
+	comment := &schema.Table{Name: "Comment", Columns: []schema.Column{{Name: "ID"}, {Name: "CommentableID"}, {Name: "CommentableType"}}}
+	rel := schema.ManyRelationship{Parent: user, Child: comment, ChildColumn: &comment.Columns[1], TypeColumn: &comment.Columns[2], TypeName: "User"}
+	user.HasMany = []schema.ManyRelationship{rel}
+	rel.Alias = "Commentable"
+	comment.ChildOf = []schema.ManyRelationship{rel}
+	s := &schema.Schema{Tables: map[string]*schema.Table{"User": user, "Comment": comment}}
+
+	users := &Table[*userScope, testConn]{Name: "User", PrimaryKey: "ID", Schema: s, Wrap: userTable.Wrap}
+	comments := &Table[*postScope, testConn]{Name: "Comment", PrimaryKey: "ID", Schema: s, Wrap: postTable.Wrap}
+
+	sql, _ := users.New(testConn{}).InnerJoin(comments.New(testConn{})).QuerySQL()
+	if sql != "SELECT User.* FROM User INNER JOIN Comment ON User.ID = Comment.CommentableID AND Comment.CommentableType = 'User'" {
+		t.Error("Unexpected polymorphic join", sql)
+	}
+	sql, _ = comments.New(testConn{}).InnerJoin(users.New(testConn{}).Alias("Commentable")).QuerySQL()
+	if sql != "SELECT Comment.* FROM Comment INNER JOIN User AS Commentable ON Commentable.ID = Comment.CommentableID AND Comment.CommentableType = 'User'" {
+		t.Error("Unexpected polymorphic join", sql)
+	}
+}
+
+func TestScopeVariant(t *testing.T) {
+	announcements := &Table[*postScope, testConn]{
+		Name:       "Post",
+		PrimaryKey: "ID",
+		TypeColumn: "Type",
+		Variant:    "Announcement",
+		Schema:     testSchema,
+		Wrap:       postTable.Wrap,
+	}
+	posts := announcements.New(testConn{})
+	Share(posts)
+
+	sql, vals := posts.Column("Title", "a").QuerySQL()
+	if sql != "SELECT Post.* FROM Post WHERE Post.Type = ? AND Post.Title = ?" || len(vals) != 2 || vals[0] != "Announcement" {
+		t.Error("Unexpected variant query", sql, vals)
+	}
+	if sql, _ := posts.ClearAll().QuerySQL(); sql != "SELECT Post.* FROM Post WHERE Post.Type = ?" {
+		t.Error("ClearAll removed the variant", sql)
+	}
+	sql, _ = userTable.New(testConn{}).InnerJoin(posts.Alias("Latest")).QuerySQL()
+	if sql != "SELECT User.* FROM User INNER JOIN Post AS Latest ON User.ID = Latest.UserID WHERE User.DeletedAt IS NULL AND Latest.Type = ?" {
+		t.Error("Unexpected variant join", sql)
+	}
+}
+
+func TestDependents(t *testing.T) {
+	user := &schema.Table{Name: "User", Columns: []schema.Column{{Name: "ID"}}}
+	post := &schema.Table{Name: "Post", Columns: []schema.Column{{Name: "ID"}, {Name: "UserID"}, {Name: "DeletedAt", Null: true}}, DeletedAt: "DeletedAt"}
+	comment := &schema.Table{Name: "Comment", Columns: []schema.Column{{Name: "ID"}, {Name: "CommentableID"}, {Name: "CommentableType"}}}
+	user.HasMany = []schema.ManyRelationship{
+		{Parent: user, Child: post, ChildColumn: &post.Columns[1], Dependent: "restrict"},
+		{Parent: user, Child: comment, ChildColumn: &comment.Columns[1], TypeColumn: &comment.Columns[2], TypeName: "User", Dependent: "delete"},
+		{Parent: user, Child: post, ChildColumn: &post.Columns[1]},
+	}
+	s := &schema.Schema{Tables: map[string]*schema.Table{"User": user, "Post": post, "Comment": comment}}
+
+	deps := dependentsOf(s, "User")
+	if len(deps) != 2 || deps[0].action != "restrict" || deps[1].action != "delete" {
+		t.Fatal("Unexpected dependents", deps)
+	}
+	if where, vals := deps[0].where(testConn{}, []interface{}{1, 2}, true); where != "UserID IN (?, ?) AND DeletedAt IS NULL" || len(vals) != 2 {
+		t.Error("Unexpected restrict condition", where, vals)
+	}
+	if where, _ := deps[0].where(testConn{}, []interface{}{1}, false); where != "UserID IN (?)" {
+		t.Error("Unexpected nullify condition", where)
+	}
+	where, vals := deps[1].where(testConn{}, []interface{}{1}, true)
+	if where != "CommentableID IN (?) AND CommentableType = ?" || len(vals) != 2 || vals[1] != "User" {
+		t.Error("Unexpected polymorphic condition", where, vals)
+	}
+
+	// a nullable DeletedAt column alone doesn't make the table soft deleted
+	post.DeletedAt = ""
+	if where, _ := deps[0].where(testConn{}, []interface{}{1}, true); where != "UserID IN (?)" {
+		t.Error("Unexpected condition without soft delete", where)
+	}
+	if len(dependentsOf(s, "Post")) != 0 || len(dependentsOf(nil, "User")) != 0 {
+		t.Error("Found dependents without a Dependent setting")
+	}
+
+	user.HasMany = []schema.ManyRelationship{
+		{Parent: user, Child: comment, ChildColumn: &comment.Columns[1], TypeColumn: &comment.Columns[2], TypeName: "User", Dependent: "nullify"},
+	}
+	c := &execConn{}
+	if err := DeleteDependents(c, s, "User", []int64{1}); err != nil {
+		t.Fatal("DeleteDependents", err)
+	}
+	expected := "UPDATE Comment SET CommentableID = NULL, CommentableType = NULL WHERE CommentableID IN (?) AND CommentableType = ?"
+	if len(c.statements) != 1 || c.statements[0] != expected {
+		t.Error("Unexpected polymorphic nullify", c.statements)
+	}
+}
+
+// execConn records the statements given to Exec
+type execConn struct {
+	testConn
+	statements []string
+}
+
+func (c *execConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	c.statements = append(c.statements, query)
+	return nil, nil
+}
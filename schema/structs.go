@@ -3,6 +3,7 @@ package schema
 import (
 	"fmt"
 	"log"
+	"strings"
 )
 
 type Schema struct {
@@ -15,6 +16,8 @@ type Table struct {
 	Name    string
 	Columns []Column
 	Index   []Index
+	// DeletedAt is the soft delete column, empty when rows are deleted
+	DeletedAt string
 
 	// One table record has other records in another table relating to it
 	HasMany []ManyRelationship
@@ -55,11 +58,56 @@ type Column struct {
 	Type        string
 	Length      int
 	IncludeName string
+	// Null columns may hold NULL, all others are created NOT NULL
+	Null bool
+	// Unsigned integer columns are UNSIGNED where the database has them,
+	// and the next larger integer type elsewhere
+	Unsigned bool
+	// Enum is the name of the enum an enum column holds, Values are the
+	// names it may be set to
+	Enum   string
+	Values []string
+	// Precision and Scale are the digits in a decimal column, and the
+	// digits of those after the decimal point
+	Precision, Scale int
+	// Default and Check are SQL expressions, a DEFAULT for the column and
+	// a CHECK constraint on it
+	Default, Check string
+	// Unique columns have a UNIQUE constraint
+	Unique bool
+	// Comment is kept on the column by databases with column comments
+	Comment string
 }
 
 type Index struct {
+	// Columns are column names, or SQL expressions using them
 	Columns []string
 	Unique  bool
+	// Desc is whether each of the Columns is sorted descending, it may be
+	// shorter than Columns
+	Desc []bool
+	// Name is the name of the index in the database, one is made from the
+	// table and columns when it's empty
+	Name string
+	// Where is the condition of a partial index
+	Where string
+}
+
+// Expression is whether Columns[n] is an expression rather than the name
+// of a column
+func (i Index) Expression(n int) bool {
+	return strings.ContainsAny(i.Columns[n], "( ")
+}
+
+// Plain is whether the index is only on columns, without expressions or a
+// condition
+func (i Index) Plain() bool {
+	for n := range i.Columns {
+		if i.Expression(n) {
+			return false
+		}
+	}
+	return i.Where == ""
 }
 
 type View struct {
@@ -71,6 +119,20 @@ type ManyRelationship struct {
 	Child       *Table
 	ChildColumn *Column
 	Alias       string
+	// OnDelete and OnUpdate are the actions of the foreign key, CASCADE,
+	// SET NULL, SET DEFAULT, RESTRICT or NO ACTION, the database's default
+	// is used when they're empty
+	OnDelete, OnUpdate string
+	// Deferrable foreign keys are checked when the transaction commits
+	Deferrable bool
+	// Dependent is what deleting a Parent does to its Child records in
+	// the generated code: "delete", "nullify" or "restrict"
+	Dependent string
+	// TypeColumn is set for polymorphic relations, where the child holds
+	// the name of its parent's table in it, TypeName is the name stored
+	// for Parent. These relations have no foreign key.
+	TypeColumn *Column
+	TypeName   string
 }
 
 type OneRelationship struct {
@@ -78,4 +140,13 @@ type OneRelationship struct {
 	Child       *Table
 	ChildColumn *Column
 	Alias       string
+	// OnDelete and OnUpdate are the actions of the foreign key, CASCADE,
+	// SET NULL, SET DEFAULT, RESTRICT or NO ACTION, the database's default
+	// is used when they're empty
+	OnDelete, OnUpdate string
+	// Deferrable foreign keys are checked when the transaction commits
+	Deferrable bool
+	// Dependent is what deleting a Parent does to its Child record in the
+	// generated code: "delete", "nullify" or "restrict"
+	Dependent string
 }